@@ -0,0 +1,363 @@
+// Package accesslog writes a line for every request served by the gateway, once it is
+// answered, in JSON, logfmt, the combined log format or a template.
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/requestid"
+)
+
+// combined is the Apache/nginx combined log format.
+const combined = `$remote_addr - $remote_user [$time_local] "$request" $status $bytes_sent "$referer" "$user_agent"`
+
+// Variables lists the variables of templates, in the order of the JSON and logfmt fields.
+var Variables = []string{
+	"time",
+	"time_local",
+	"request_id",
+	"remote_addr",
+	"remote_user",
+	"host",
+	"method",
+	"uri",
+	"protocol",
+	"request",
+	"status",
+	"bytes_received",
+	"bytes_sent",
+	"latency",
+	"route",
+	"upstream_addr",
+	"upstream_status",
+	"upstream_latency",
+	"upstream_attempts",
+	"cache_status",
+	"user_agent",
+	"referer",
+}
+
+// structured are the fields written by the json and logfmt formats; time_local and
+// request repeat other fields.
+var structured = []string{
+	"time", "request_id", "remote_addr", "remote_user", "host", "method", "uri", "protocol",
+	"status", "bytes_received", "bytes_sent", "latency", "route", "upstream_addr",
+	"upstream_status", "upstream_latency", "upstream_attempts", "cache_status", "user_agent", "referer",
+}
+
+var variablePattern = regexp.MustCompile(`\$\{([a-z_]+)\}|\$([a-z_]+)`)
+
+// Logger writes the access log.
+type Logger struct {
+	cfg    config.AccessLog
+	format func(buf *bytes.Buffer, v *values)
+	// template segments: literals at even indexes, variable names at odd ones
+	segments []string
+
+	mu  sync.Mutex
+	out io.Writer
+	// file of the file output, nil for stdout
+	file io.Closer
+}
+
+// New creates the access log configured by cfg.
+func New(cfg config.AccessLog) (*Logger, error) {
+	l := &Logger{cfg: cfg, out: os.Stdout}
+
+	switch cfg.EffectiveFormat() {
+	case "json":
+		l.format = formatJSON
+	case "logfmt":
+		l.format = formatLogfmt
+	case "combined":
+		l.segments = parseTemplate(combined)
+		l.format = l.formatTemplate
+	case "template":
+		if cfg.Template == "" {
+			return nil, fmt.Errorf("access log: template format requires access_log.template")
+		}
+		segments := parseTemplate(cfg.Template)
+		for i := 1; i < len(segments); i += 2 {
+			if !isVariable(segments[i]) {
+				return nil, fmt.Errorf("access log: unknown template variable: $%s", segments[i])
+			}
+		}
+		l.segments = segments
+		l.format = l.formatTemplate
+	default:
+		return nil, fmt.Errorf("access log: unsupported format: %s", cfg.Format)
+	}
+
+	switch cfg.Output {
+	case "", "stdout":
+	case "file":
+		out, err := newRotatingFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		l.out = out
+		l.file = out
+	default:
+		return nil, fmt.Errorf("access log: unsupported output: %s", cfg.Output)
+	}
+
+	return l, nil
+}
+
+// parseTemplate splits a template into literals and variable names.
+func parseTemplate(template string) []string {
+	segments := []string{}
+	last := 0
+	for _, m := range variablePattern.FindAllStringSubmatchIndex(template, -1) {
+		var name string
+		if m[2] >= 0 {
+			name = template[m[2]:m[3]]
+		} else {
+			name = template[m[4]:m[5]]
+		}
+		segments = append(segments, template[last:m[0]], name)
+		last = m[1]
+	}
+	return append(segments, template[last:])
+}
+
+func isVariable(name string) bool {
+	for _, v := range Variables {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled reports whether requests of a route with the access log settings cfg are logged.
+func (l *Logger) Enabled(cfg route.AccessLog) bool {
+	if cfg.Enable != nil {
+		return *cfg.Enable
+	}
+	return l.cfg.Enable
+}
+
+// Log writes the line of req, answered with status and size bytes of body. Successful
+// requests are sampled.
+func (l *Logger) Log(req *http.Request, status int, size int64) {
+	e := entryOf(req)
+	if e == nil || !l.Enabled(e.config) {
+		return
+	}
+
+	if status < http.StatusBadRequest {
+		rate := l.cfg.EffectiveSampleRate()
+		if e.config.SampleRate > 0 && e.config.SampleRate <= 1 {
+			rate = e.config.SampleRate
+		}
+		if rate < 1 && rand.Float64() >= rate {
+			return
+		}
+	}
+
+	v := &values{req: req, entry: e, status: status, size: size, end: time.Now()}
+	buf := &bytes.Buffer{}
+	l.format(buf, v)
+	buf.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(buf.Bytes())
+}
+
+// Close closes the log file.
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// values computes the variables of a request.
+type values struct {
+	req    *http.Request
+	entry  *Entry
+	status int
+	size   int64
+	end    time.Time
+}
+
+// get returns the value of a variable: a string, an int64 or a float64, or nil when it
+// is not known for the request.
+func (v *values) get(name string) any {
+	req, e := v.req, v.entry
+
+	switch name {
+	case "time":
+		return e.start.Format("2006-01-02T15:04:05.000Z07:00")
+	case "time_local":
+		return e.start.Format("02/Jan/2006:15:04:05 -0700")
+	case "request_id":
+		return optional(requestid.FromRequest(req))
+	case "remote_addr":
+		if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			return host
+		}
+		return req.RemoteAddr
+	case "remote_user":
+		if user, _, ok := req.BasicAuth(); ok {
+			return optional(user)
+		}
+		return nil
+	case "host":
+		return req.Host
+	case "method":
+		return req.Method
+	case "uri":
+		return req.RequestURI
+	case "protocol":
+		return req.Proto
+	case "request":
+		return req.Method + " " + req.RequestURI + " " + req.Proto
+	case "status":
+		return int64(v.status)
+	case "bytes_received":
+		if req.ContentLength < 0 {
+			return nil
+		}
+		return req.ContentLength
+	case "bytes_sent":
+		return v.size
+	case "latency":
+		return v.end.Sub(e.start).Seconds()
+	case "route":
+		return optional(e.route)
+	case "upstream_addr":
+		return optional(e.upstreamAddr)
+	case "upstream_status":
+		if e.attempts == 0 || e.upstreamStatus == 0 {
+			return nil
+		}
+		return int64(e.upstreamStatus)
+	case "upstream_latency":
+		if e.attempts == 0 {
+			return nil
+		}
+		return e.upstreamLatency.Seconds()
+	case "upstream_attempts":
+		return int64(e.attempts)
+	case "cache_status":
+		return optional(e.cacheStatus)
+	case "user_agent":
+		return optional(req.UserAgent())
+	case "referer":
+		return optional(req.Referer())
+	default:
+		return nil
+	}
+}
+
+func optional(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// text formats a value for the logfmt and template formats; unknown values are "-".
+func text(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "-"
+	case string:
+		return value
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		// seconds with millisecond resolution, like nginx
+		return strconv.FormatFloat(value, 'f', 3, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func formatJSON(buf *bytes.Buffer, v *values) {
+	buf.WriteByte('{')
+	first := true
+	for _, name := range structured {
+		value := v.get(name)
+		if value == nil {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		if f, ok := value.(float64); ok {
+			buf.WriteString(strconv.FormatFloat(f, 'f', 6, 64))
+			continue
+		}
+		encoded, _ := json.Marshal(value)
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+}
+
+func formatLogfmt(buf *bytes.Buffer, v *values) {
+	first := true
+	for _, name := range structured {
+		value := v.get(name)
+		if value == nil {
+			continue
+		}
+		if !first {
+			buf.WriteByte(' ')
+		}
+		first = false
+
+		buf.WriteString(name)
+		buf.WriteByte('=')
+		s := text(value)
+		if s == "" || strings.ContainsAny(s, " \"=") || strconv.Quote(s) != `"`+s+`"` {
+			s = strconv.Quote(s)
+		}
+		buf.WriteString(s)
+	}
+}
+
+func (l *Logger) formatTemplate(buf *bytes.Buffer, v *values) {
+	for i, segment := range l.segments {
+		if i%2 == 0 {
+			buf.WriteString(segment)
+		} else {
+			escape(buf, text(v.get(segment)))
+		}
+	}
+}
+
+// escape writes s with quotes, backslashes and control characters escaped as \xHH, like
+// nginx, so values cannot break the line apart.
+func escape(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' || c == '\\' || c < 0x20 || c == 0x7f {
+			fmt.Fprintf(buf, "\\x%02X", c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+}
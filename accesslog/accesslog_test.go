@@ -0,0 +1,169 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/requestid"
+)
+
+func newTestLogger(t *testing.T, cfg config.AccessLog) (*Logger, *bytes.Buffer) {
+	t.Helper()
+
+	l, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	l.out = out
+	return l, out
+}
+
+// served returns a request as the gateway has served it: routed to api and proxied to
+// 10.0.0.1:8080 after a retry.
+func served() *http.Request {
+	req := Start(requestid.WithID(httptest.NewRequest("GET", "/api/users?page=2", nil), "req-1"))
+	req.RemoteAddr = "192.0.2.1:51234"
+	req.Header.Set("User-Agent", `curl/8.0 "test"`)
+
+	SetRoute(req, &route.Route{Name: "api"})
+	SetUpstream(req, "10.0.0.2:8080", 0, 10*time.Millisecond)
+	SetUpstream(req, "10.0.0.1:8080", 200, 25*time.Millisecond)
+	SetCacheStatus(req, "miss")
+	return req
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]config.AccessLog{
+		"unsupported format":   {Format: "xml"},
+		"missing template":     {Format: "template"},
+		"unknown variable":     {Format: "template", Template: "$status $nope"},
+		"unsupported output":   {Output: "syslog"},
+		"missing file path":    {Output: "file"},
+		"unsupported interval": {Output: "file", File: config.AccessLogFile{Path: t.TempDir() + "/access.log", Interval: "weekly"}},
+	}
+	for name, cfg := range cases {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	l, out := newTestLogger(t, config.AccessLog{Enable: true})
+	l.Log(served(), 200, 42)
+
+	var line map[string]any
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("expected a JSON line, got %q: %s", out.String(), err)
+	}
+
+	expected := map[string]any{
+		"request_id":        "req-1",
+		"remote_addr":       "192.0.2.1",
+		"method":            "GET",
+		"uri":               "/api/users?page=2",
+		"status":            float64(200),
+		"bytes_sent":        float64(42),
+		"route":             "api",
+		"upstream_addr":     "10.0.0.1:8080",
+		"upstream_status":   float64(200),
+		"upstream_latency":  0.025,
+		"upstream_attempts": float64(2),
+		"cache_status":      "miss",
+		"user_agent":        `curl/8.0 "test"`,
+	}
+	for key, want := range expected {
+		if line[key] != want {
+			t.Errorf("expected %s to be %v, got %v", key, want, line[key])
+		}
+	}
+	if _, ok := line["referer"]; ok {
+		t.Error("expected unknown fields to be left out")
+	}
+}
+
+func TestLogfmt(t *testing.T) {
+	l, out := newTestLogger(t, config.AccessLog{Enable: true, Format: "logfmt"})
+	l.Log(served(), 200, 42)
+
+	line := out.String()
+	for _, field := range []string{"method=GET ", "status=200 ", "route=api ", "upstream_latency=0.025 ", `user_agent="curl/8.0 \"test\""`} {
+		if !strings.Contains(line, field) {
+			t.Errorf("expected %s in %q", field, line)
+		}
+	}
+}
+
+func TestCombined(t *testing.T) {
+	l, out := newTestLogger(t, config.AccessLog{Enable: true, Format: "combined"})
+	l.Log(served(), 200, 42)
+
+	line := out.String()
+	if !strings.HasPrefix(line, "192.0.2.1 - - [") {
+		t.Errorf("expected the client address first, got %q", line)
+	}
+	if !strings.HasSuffix(line, `] "GET /api/users?page=2 HTTP/1.1" 200 42 "-" "curl/8.0 \x22test\x22"`+"\n") {
+		t.Errorf("expected the combined log format, got %q", line)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	l, out := newTestLogger(t, config.AccessLog{Enable: true, Format: "template", Template: "$route ${upstream_addr}/$upstream_status $cache_status $referer"})
+	l.Log(served(), 200, 42)
+
+	if line := out.String(); line != "api 10.0.0.1:8080/200 miss -\n" {
+		t.Errorf("unexpected line %q", line)
+	}
+}
+
+func TestRouteOverride(t *testing.T) {
+	enabled, disabled := true, false
+
+	l, out := newTestLogger(t, config.AccessLog{})
+	req := Start(httptest.NewRequest("GET", "/", nil))
+	SetRoute(req, &route.Route{Name: "on", AccessLog: route.AccessLog{Enable: &enabled}})
+	l.Log(req, 200, 0)
+	if out.Len() == 0 {
+		t.Error("expected the route to enable the access log")
+	}
+
+	l, out = newTestLogger(t, config.AccessLog{Enable: true})
+	req = Start(httptest.NewRequest("GET", "/", nil))
+	SetRoute(req, &route.Route{Name: "off", AccessLog: route.AccessLog{Enable: &disabled}})
+	l.Log(req, 500, 0)
+	if out.Len() != 0 {
+		t.Errorf("expected the route to disable the access log, got %q", out.String())
+	}
+}
+
+func TestSampling(t *testing.T) {
+	l, out := newTestLogger(t, config.AccessLog{Enable: true, SampleRate: 0.000001})
+
+	for i := 0; i < 10; i++ {
+		l.Log(Start(httptest.NewRequest("GET", "/", nil)), 200, 0)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected successful requests to be sampled, got %q", out.String())
+	}
+
+	l.Log(Start(httptest.NewRequest("GET", "/", nil)), 502, 0)
+	if strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("expected failed requests to be logged, got %q", out.String())
+	}
+
+	// the route samples every request
+	req := Start(httptest.NewRequest("GET", "/", nil))
+	SetRoute(req, &route.Route{Name: "all", AccessLog: route.AccessLog{SampleRate: 1}})
+	l.Log(req, 200, 0)
+	if strings.Count(out.String(), "\n") != 2 {
+		t.Errorf("expected the route sample rate to apply, got %q", out.String())
+	}
+}
@@ -0,0 +1,64 @@
+package accesslog
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/route"
+)
+
+type entryKey struct{}
+
+// Entry is what the gateway learns about a request while serving it, for its access log line.
+type Entry struct {
+	start time.Time
+
+	route  string
+	config route.AccessLog
+
+	// the last attempt of the upstream call, and how many were made
+	upstreamAddr    string
+	upstreamStatus  int
+	upstreamLatency time.Duration
+	attempts        int
+
+	cacheStatus string
+}
+
+// Start returns req prepared for the access log; what the gateway decides about it is
+// recorded with SetRoute, SetUpstream and SetCacheStatus.
+func Start(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), entryKey{}, &Entry{start: time.Now()}))
+}
+
+func entryOf(req *http.Request) *Entry {
+	e, _ := req.Context().Value(entryKey{}).(*Entry)
+	return e
+}
+
+// SetRoute records the route req matched.
+func SetRoute(req *http.Request, r *route.Route) {
+	if e := entryOf(req); e != nil {
+		e.route = r.Name
+		e.config = r.AccessLog
+	}
+}
+
+// SetUpstream records an attempt to call the upstream server addr, answered with status
+// (0 when it failed) after latency.
+func SetUpstream(req *http.Request, addr string, status int, latency time.Duration) {
+	if e := entryOf(req); e != nil {
+		e.upstreamAddr = addr
+		e.upstreamStatus = status
+		e.upstreamLatency = latency
+		e.attempts++
+	}
+}
+
+// SetCacheStatus records the HTTP cache outcome: hit, miss or bypass.
+func SetCacheStatus(req *http.Request, status string) {
+	if e := entryOf(req); e != nil {
+		e.cacheStatus = status
+	}
+}
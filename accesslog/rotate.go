@@ -0,0 +1,214 @@
+package accesslog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/logger"
+)
+
+// backupTimeFormat is appended to the name of rotated files; it sorts chronologically.
+const backupTimeFormat = "20060102T150405.000"
+
+// rotatingFile is a log file rotated by size and time. Rotated files are renamed with
+// the time of the rotation, compressed and pruned in the background.
+type rotatingFile struct {
+	cfg     config.AccessLogFile
+	maxSize int64
+	now     func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period string
+
+	// background compression and pruning of rotated files, one rotation at a time
+	wg       sync.WaitGroup
+	backupMu sync.Mutex
+}
+
+func newRotatingFile(cfg config.AccessLogFile) (*rotatingFile, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("access log: file output requires access_log.file.path")
+	}
+	switch cfg.Interval {
+	case "", "hourly", "daily":
+	default:
+		return nil, fmt.Errorf("access log: unsupported rotation interval: %s", cfg.Interval)
+	}
+
+	f := &rotatingFile{
+		cfg:     cfg,
+		maxSize: cfg.MaxSize * 1024 * 1024,
+		now:     time.Now,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.cfg.Path), 0755); err != nil {
+		return fmt.Errorf("access log: %s", err)
+	}
+	file, err := os.OpenFile(f.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("access log: %s", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("access log: %s", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.period = f.periodOf(f.now())
+	return nil
+}
+
+// periodOf identifies the rotation interval t falls in.
+func (f *rotatingFile) periodOf(t time.Time) string {
+	switch f.cfg.Interval {
+	case "hourly":
+		return t.Format("2006010215")
+	case "daily":
+		return t.Format("20060102")
+	default:
+		return ""
+	}
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	now := f.now()
+	full := f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize
+	if full || f.periodOf(now) != f.period {
+		if err := f.rotate(now); err != nil {
+			logger.Warn("failed to rotate access log: %s", err)
+			if f.file == nil {
+				return 0, err
+			}
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file with the time of the rotation and opens a new one.
+func (f *rotatingFile) rotate(now time.Time) error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	backup := f.cfg.Path + "." + now.Format(backupTimeFormat)
+	if err := os.Rename(f.cfg.Path, backup); err != nil {
+		// keep writing to the current file
+		if openErr := f.open(); openErr != nil {
+			f.file = nil
+			return openErr
+		}
+		return err
+	}
+
+	if err := f.open(); err != nil {
+		f.file = nil
+		return err
+	}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.compressAndPrune(backup)
+	}()
+	return nil
+}
+
+func (f *rotatingFile) compressAndPrune(backup string) {
+	f.backupMu.Lock()
+	defer f.backupMu.Unlock()
+
+	if f.cfg.Compress {
+		if err := compress(backup); err != nil {
+			logger.Warn("failed to compress access log %s: %s", backup, err)
+		}
+	}
+
+	if f.cfg.MaxBackups <= 0 {
+		return
+	}
+	backups, err := filepath.Glob(f.cfg.Path + ".*")
+	if err != nil {
+		return
+	}
+	// the oldest first
+	sort.Strings(backups)
+	for len(backups) > int(f.cfg.MaxBackups) {
+		if err := os.Remove(backups[0]); err != nil {
+			logger.Warn("failed to remove access log %s: %s", backups[0], err)
+		}
+		backups = backups[1:]
+	}
+}
+
+// compress gzips path into path.gz and removes path.
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// Close closes the file once rotated files are compressed.
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.wg.Wait()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,119 @@
+package accesslog
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+)
+
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+
+	names, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotateBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := newRotatingFile(config.AccessLogFile{Path: path, MaxSize: 1, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	f.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	line := []byte(strings.Repeat("x", 1023) + "\n")
+	// 4 files of 1MB: 3 rotations
+	for i := 0; i < 4*1024; i++ {
+		if _, err := f.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := backups(t, path)
+	if len(rotated) != 2 {
+		t.Fatalf("expected 2 backups to be kept, got %v", rotated)
+	}
+	for _, name := range rotated {
+		if !strings.HasSuffix(name, ".gz") {
+			t.Fatalf("expected compressed backups, got %s", name)
+		}
+	}
+	if !strings.HasPrefix(rotated[1], path+".20240102T15") {
+		t.Errorf("expected backups named after the rotation time, got %s", rotated[1])
+	}
+
+	gz, err := os.Open(rotated[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+	r, err := gzip.NewReader(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) != 1024*1024 {
+		t.Errorf("expected 1MB backups, got %d bytes", len(content))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 1024*1024 {
+		t.Errorf("expected the current file to hold the last 1MB, got %d bytes", info.Size())
+	}
+}
+
+func TestRotateByInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := newRotatingFile(config.AccessLogFile{Path: path, Interval: "hourly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	f.Write([]byte("first\n"))
+	f.Write([]byte("second\n"))
+	if rotated := backups(t, path); len(rotated) != 0 {
+		t.Fatalf("expected no rotation within the hour, got %v", rotated)
+	}
+
+	now = now.Add(time.Hour)
+	f.Write([]byte("third\n"))
+
+	rotated := backups(t, path)
+	if len(rotated) != 1 {
+		t.Fatalf("expected a rotation after an hour, got %v", rotated)
+	}
+	if content, _ := os.ReadFile(rotated[0]); string(content) != "first\nsecond\n" {
+		t.Errorf("unexpected backup content %q", content)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "third\n" {
+		t.Errorf("unexpected current content %q", content)
+	}
+}
@@ -38,26 +38,36 @@ func main() {
 			configFilePath = "/etc/api-gateway/config.yaml"
 		}
 
-		var cfg config.Config
+		// the configuration is loaded again on reload (SIGHUP, file changes, admin API)
+		load := func() (*config.Config, error) {
+			var cfg config.Config
 
-		if configFilePath != "" {
-			if !fs.IsExist(configFilePath) {
-				return fmt.Errorf("config file(%s) not found", configFilePath)
+			if configFilePath != "" {
+				if !fs.IsExist(configFilePath) {
+					return nil, fmt.Errorf("config file(%s) not found", configFilePath)
+				}
+
+				if err := gzconfig.Load(&cfg, &gzconfig.LoadOptions{
+					FilePath: configFilePath,
+				}); err != nil {
+					return nil, fmt.Errorf("failed to read config file: %s", err)
+				}
 			}
 
-			if err := gzconfig.Load(&cfg, &gzconfig.LoadOptions{
-				FilePath: configFilePath,
-			}); err != nil {
-				return fmt.Errorf("failed to read config file: %s", err)
+			if c.Int64("port") != 0 {
+				cfg.Port = c.Int64("port")
 			}
-		}
 
-		if c.Int64("port") != 0 {
-			cfg.Port = c.Int64("port")
+			if cfg.Port == 0 {
+				cfg.Port = 8080
+			}
+
+			return &cfg, nil
 		}
 
-		if cfg.Port == 0 {
-			cfg.Port = 8080
+		cfg, err := load()
+		if err != nil {
+			return err
 		}
 
 		// @TODO
@@ -66,10 +76,11 @@ func main() {
 			fmt.PrintJSON("config:", cfg)
 		}
 
-		app, err := core.New(api.Version, &cfg)
+		app, err := core.New(api.Version, cfg)
 		if err != nil {
 			return fmt.Errorf("failed to create core: %s", err)
 		}
+		app.SetConfigSource(configFilePath, load)
 
 		return app.Run()
 	})
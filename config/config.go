@@ -1,7 +1,10 @@
 package config
 
 import (
+	"time"
+
 	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
 )
 
 type Config struct {
@@ -10,8 +13,13 @@ type Config struct {
 	BaseURI string `config:"baseuri"`
 	//
 	Backend route.Backend `config:"backend"`
+	// Hosts are the default backends of requests for a host, used in place of Backend
+	// when no route matches
+	Hosts []Host `config:"hosts"`
 	//
 	Routes []route.Route `config:"routes"`
+	// Router chooses the route of a request when several match
+	Router Router `config:"router"`
 	//
 	Cache Cache `config:"cache"`
 	//
@@ -28,10 +36,68 @@ type Config struct {
 	IPPolicy IPPolicy `config:"ip_policy"`
 	//
 	CORS CORS `config:"cors"`
+	// Timeout is the default upstream timeout for every backend; service and server blocks override it.
+	Timeout Timeout `config:"timeout"`
+	//
+	RetryBudget RetryBudget `config:"retry_budget"`
+	// HTTPS serves TLS on its own port with the certificates in SSL
+	HTTPS HTTPS `config:"https"`
+	// SSL lists the certificates served over HTTPS, selected by SNI
+	SSL []SSL `config:"ssl"`
+	// ClientCert requires TLS client certificates and forwards the client identity upstream
+	ClientCert ClientCert `config:"client_cert"`
+	// Shutdown controls how the gateway drains on SIGTERM / SIGINT
+	Shutdown Shutdown `config:"shutdown"`
+	// Reload picks up configuration changes without a restart
+	Reload Reload `config:"reload"`
+	// Admin serves the admin API on its own port
+	Admin Admin `config:"admin"`
+	// Metrics serves Prometheus metrics
+	Metrics Metrics `config:"metrics"`
+	// Tracing exports OpenTelemetry spans of the requests and their upstream calls
+	Tracing Tracing `config:"tracing"`
+	// AccessLog writes a line for every request once it is answered
+	AccessLog AccessLog `config:"access_log"`
+	// RequestID identifies every request, with the ID sent by the client or a generated one
+	RequestID RequestID `config:"request_id"`
 	//
 	// Match func(path string) (r *route.Route, err error)
 }
 
+// Host is the default backend of the requests for Host, like api.example.com or
+// *.example.com, which matches a single label.
+type Host struct {
+	Host    string        `config:"host"`
+	Backend route.Backend `config:"backend"`
+}
+
+// Router chooses the route of a request when several match it.
+type Router struct {
+	// Mode is first_match (default), where the first matching route in configuration
+	// order wins, or longest_prefix, where exact paths win over regexes, and regexes
+	// over prefixes, the longest prefix first.
+	Mode string `config:"mode,default=first_match"`
+	// Conflicts is what the gateway does about routes that can never match and route names
+	// used more than once: warn (default) logs them, reject refuses the configuration.
+	Conflicts string `config:"conflicts,default=warn"`
+}
+
+// EffectiveMode returns how the route of a request is chosen.
+func (r Router) EffectiveMode() string {
+	if r.Mode == "" {
+		return "first_match"
+	}
+	return r.Mode
+}
+
+// EffectiveConflicts returns what the gateway does about conflicting routes.
+func (r Router) EffectiveConflicts() string {
+	if r.Conflicts == "" {
+		return "warn"
+	}
+	return r.Conflicts
+}
+
 // HTTPCache uses route.HTTPCache to avoid circular imports (same pattern as RateLimit).
 type HTTPCache = route.HTTPCache
 
@@ -68,11 +134,325 @@ type Cache struct {
 	Prefix   string `config:"prefix"`
 }
 
+// RetryBudget caps gateway-wide retries to a share of live traffic, so retries
+// cannot multiply the load on backends during a real outage.
+type RetryBudget struct {
+	// Percent is the allowed retries as a percentage of requests in the window (default 20).
+	Percent float64 `config:"percent,default=20"`
+	// MinRetriesPerSecond is allowed regardless of traffic, so quiet routes can still retry (default 10).
+	MinRetriesPerSecond int64 `config:"min_retries_per_second,default=10"`
+	// Window is the sliding window in seconds over which requests and retries are counted (default 10).
+	Window int64 `config:"window,default=10"`
+}
+
+// EffectivePercent returns the retry share of live traffic in percent.
+func (b RetryBudget) EffectivePercent() float64 {
+	if b.Percent <= 0 {
+		return 20
+	}
+	return b.Percent
+}
+
+// EffectiveMinRetriesPerSecond returns the retries allowed per second regardless of traffic.
+func (b RetryBudget) EffectiveMinRetriesPerSecond() int64 {
+	if b.MinRetriesPerSecond < 0 {
+		return 0
+	}
+	if b.MinRetriesPerSecond == 0 {
+		return 10
+	}
+	return b.MinRetriesPerSecond
+}
+
+// EffectiveWindow returns the sliding window in seconds.
+func (b RetryBudget) EffectiveWindow() int64 {
+	if b.Window <= 0 {
+		return 10
+	}
+	return b.Window
+}
+
+// Shutdown controls the graceful shutdown: the outer health check fails first, so load
+// balancers stop sending traffic, then the listeners close and in-flight work drains.
+type Shutdown struct {
+	// Delay is how long the outer health check reports 503 before the listeners stop
+	// accepting connections, in seconds (default 5; negative for none).
+	Delay int64 `config:"delay,default=5"`
+	// Timeout is how long in-flight requests, WebSockets and audit sinks get to finish
+	// once the listeners are closed, in seconds (default 30).
+	Timeout int64 `config:"timeout,default=30"`
+}
+
+// EffectiveDelay returns how long the gateway reports unavailable before closing its listeners.
+func (s Shutdown) EffectiveDelay() time.Duration {
+	if s.Delay < 0 {
+		return 0
+	}
+	if s.Delay == 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(s.Delay) * time.Second
+}
+
+// EffectiveTimeout returns how long in-flight work gets to drain.
+func (s Shutdown) EffectiveTimeout() time.Duration {
+	if s.Timeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(s.Timeout) * time.Second
+}
+
+// Reload controls configuration reloads. Besides SIGHUP and the admin API, the
+// configuration file can be watched for changes.
+type Reload struct {
+	// Watch reloads the configuration when its file changes.
+	Watch bool `config:"watch"`
+	// Interval is how often the configuration file is checked for changes, in seconds (default 5).
+	Interval int64 `config:"interval,default=5"`
+}
+
+// EffectiveInterval returns how often the configuration file is checked for changes.
+func (r Reload) EffectiveInterval() time.Duration {
+	if r.Interval <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(r.Interval) * time.Second
+}
+
+// Admin serves the admin API on its own port.
+type Admin struct {
+	// Port is the admin listening port; 0 disables the admin API.
+	Port int64 `config:"port"`
+	// Token is accepted as a bearer token (Authorization: Bearer <token>) when set.
+	Token string `config:"token"`
+	// Username and Password are accepted as basic auth credentials when set.
+	Username string `config:"username"`
+	Password string `config:"password"`
+}
+
+// Protected reports whether the admin API requires credentials.
+func (a *Admin) Protected() bool {
+	return a.Token != "" || a.Username != ""
+}
+
+// Metrics serves Prometheus metrics, on the gateway port or on their own.
+type Metrics struct {
+	Enable bool `config:"enable"`
+	// Path is the path metrics are served on (default /metrics).
+	Path string `config:"path,default=/metrics"`
+	// Port serves the metrics on their own listener; 0 serves them on the gateway port.
+	Port int64 `config:"port"`
+}
+
+// EffectivePath returns the path metrics are served on.
+func (m Metrics) EffectivePath() string {
+	if m.Path == "" {
+		return "/metrics"
+	}
+	return m.Path
+}
+
+// Tracing exports OpenTelemetry spans: a server span per request and a client span per
+// upstream call, with the trace context propagated to backends.
+type Tracing struct {
+	Enable bool `config:"enable"`
+	// ServiceName is reported as service.name (default api-gateway).
+	ServiceName string `config:"service_name,default=api-gateway"`
+	// Exporter sends the spans: otlp-http (default), otlp-grpc, stdout or file.
+	Exporter string `config:"exporter,default=otlp-http"`
+	// Endpoint is the OTLP collector, like localhost:4318 or https://collector:4318; when
+	// empty, the OTEL_EXPORTER_OTLP_* environment variables apply.
+	Endpoint string `config:"endpoint"`
+	// Insecure sends OTLP without TLS.
+	Insecure bool `config:"insecure"`
+	// Headers are sent with every OTLP export, like an API key.
+	Headers map[string]string `config:"headers"`
+	// File is where the file exporter appends spans, one JSON object per line.
+	File string `config:"file"`
+	// SampleRatio is the share of new traces recorded, above 0 and up to 1 (default 1);
+	// requests that carry a trace context follow the decision of their caller.
+	SampleRatio float64 `config:"sample_ratio,default=1"`
+	// Propagators are the trace context formats read from requests and sent to backends:
+	// tracecontext, baggage, b3 (single header) and b3multi (default tracecontext and baggage).
+	Propagators []string `config:"propagators"`
+}
+
+// EffectiveServiceName returns the service.name of the spans.
+func (t Tracing) EffectiveServiceName() string {
+	if t.ServiceName == "" {
+		return "api-gateway"
+	}
+	return t.ServiceName
+}
+
+// EffectiveExporter returns where spans are sent.
+func (t Tracing) EffectiveExporter() string {
+	if t.Exporter == "" {
+		return "otlp-http"
+	}
+	return t.Exporter
+}
+
+// EffectiveSampleRatio returns the share of new traces recorded.
+func (t Tracing) EffectiveSampleRatio() float64 {
+	if t.SampleRatio <= 0 || t.SampleRatio > 1 {
+		return 1
+	}
+	return t.SampleRatio
+}
+
+// AccessLog writes a line for every request once it is answered, with its status,
+// latency and upstream. Routes can turn it on or off and sample it differently.
+type AccessLog struct {
+	Enable bool `config:"enable"`
+	// Format is json (default), logfmt, combined (Apache/nginx combined log format) or template.
+	Format string `config:"format,default=json"`
+	// Template is the line written with the template format, with variables like $status
+	// or ${route}.
+	Template string `config:"template"`
+	// Output is stdout (default) or file.
+	Output string `config:"output,default=stdout"`
+	// File configures the file output and its rotation.
+	File AccessLogFile `config:"file"`
+	// SampleRate is the fraction of successful requests (status < 400) logged (0.0–1.0];
+	// values <= 0 are treated as 1.0. Failed requests are always logged.
+	SampleRate float64 `config:"sample_rate,default=1"`
+}
+
+// AccessLogFile configures the access log file. Rotated files are renamed with the time
+// of the rotation, like access.log.20240102T150405.000.
+type AccessLogFile struct {
+	Path string `config:"path"`
+	// MaxSize rotates the file once it reaches this many megabytes (0 = no limit).
+	MaxSize int64 `config:"max_size"`
+	// Interval rotates the file every hour or day: hourly or daily (empty = never).
+	Interval string `config:"interval"`
+	// MaxBackups is the number of rotated files kept (0 = all).
+	MaxBackups int64 `config:"max_backups"`
+	// Compress gzips rotated files.
+	Compress bool `config:"compress"`
+}
+
+// EffectiveFormat returns the format of the access log lines.
+func (a AccessLog) EffectiveFormat() string {
+	if a.Format == "" {
+		return "json"
+	}
+	return a.Format
+}
+
+// EffectiveSampleRate returns the fraction of successful requests logged.
+func (a AccessLog) EffectiveSampleRate() float64 {
+	if a.SampleRate <= 0 || a.SampleRate > 1 {
+		return 1
+	}
+	return a.SampleRate
+}
+
+// RequestID identifies every request with an ID, accepted from the client or generated
+// by the gateway. The ID is forwarded upstream, echoed in the response and attached to
+// the logs, access log, audit records, error bodies and spans of the request.
+type RequestID struct {
+	// Header carries the ID in requests and responses (default X-Request-Id).
+	Header string `config:"header,default=X-Request-Id"`
+	// Generator creates the IDs of requests without a valid one: uuidv7 (default) or ulid.
+	Generator string `config:"generator,default=uuidv7"`
+}
+
+// EffectiveHeader returns the header carrying the request ID.
+func (r RequestID) EffectiveHeader() string {
+	if r.Header == "" {
+		return "X-Request-Id"
+	}
+	return r.Header
+}
+
+// EffectiveGenerator returns how request IDs are generated.
+func (r RequestID) EffectiveGenerator() string {
+	if r.Generator == "" {
+		return "uuidv7"
+	}
+	return r.Generator
+}
+
+// HTTPS configures TLS termination. Certificates are listed in Config.SSL.
+type HTTPS struct {
+	// Port is the HTTPS listening port; 0 disables HTTPS.
+	Port int64 `config:"port"`
+	// MinVersion is the minimum TLS version: 1.0, 1.1, 1.2 (default) or 1.3.
+	MinVersion string `config:"min_version,default=1.2"`
+	// CipherSuites restricts the TLS 1.0-1.2 cipher suites, by Go name
+	// (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256); empty uses Go's defaults.
+	CipherSuites []string `config:"cipher_suites"`
+	// RedirectPort serves plain HTTP on this port, redirecting every request to HTTPS; 0 disables it.
+	RedirectPort int64 `config:"redirect_port"`
+	// ReloadInterval is how often certificate files are checked for changes, in seconds (default 10).
+	ReloadInterval int64 `config:"reload_interval,default=10"`
+	// ACME obtains and renews certificates automatically.
+	ACME ACME `config:"acme"`
+	// ClientCA is a PEM bundle of CAs trusted for client certificates. When set, clients may
+	// present a certificate, which is verified; routes with client_cert require one.
+	ClientCA string `config:"client_ca"`
+	// ClientCertHosts require a verified client certificate during the handshake for these
+	// server names (SNI); wildcards match a single label. Requires ClientCA.
+	ClientCertHosts []string `config:"client_cert_hosts"`
+}
+
+// ACME obtains and renews the certificates of Hosts from an ACME CA (Let's Encrypt by
+// default) with the HTTP-01 or TLS-ALPN-01 challenge.
+type ACME struct {
+	Enable bool `config:"enable"`
+	// Hosts are the domains certificates are obtained for; other names use the ssl certificates.
+	Hosts []string `config:"hosts"`
+	// Email is the contact address of the ACME account (optional).
+	Email string `config:"email"`
+	// DirectoryURL is the ACME directory, Let's Encrypt production by default.
+	DirectoryURL string `config:"directory_url"`
+	// DirectoryCA is a PEM file of extra root CAs trusted when talking to the ACME directory,
+	// e.g. the certificate of a local Pebble server.
+	DirectoryCA string `config:"directory_ca"`
+	// Storage is where certificates and the account key are kept: dir (default), or cache,
+	// shared by replicas through the Redis cache and lost on restart without it.
+	Storage string `config:"storage,default=dir"`
+	// Dir is the directory certificates are stored in when Storage is dir (default acme,
+	// relative to the working directory).
+	Dir string `config:"dir,default=acme"`
+	// RenewBeforeDays is how many days before expiry certificates are renewed (default 30).
+	RenewBeforeDays int64 `config:"renew_before_days,default=30"`
+}
+
+// EffectiveRenewBefore returns how long before expiry certificates are renewed.
+func (a ACME) EffectiveRenewBefore() time.Duration {
+	if a.RenewBeforeDays <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return time.Duration(a.RenewBeforeDays) * 24 * time.Hour
+}
+
+// EffectiveDir returns the directory of the dir storage.
+func (a ACME) EffectiveDir() string {
+	if a.Dir == "" {
+		return "acme"
+	}
+	return a.Dir
+}
+
+// EffectiveReloadInterval returns how often certificate files are checked for changes.
+func (h HTTPS) EffectiveReloadInterval() time.Duration {
+	if h.ReloadInterval <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(h.ReloadInterval) * time.Second
+}
+
+// SSL is a certificate served for Domain. Domain may be a wildcard (*.example.com),
+// which matches a single label; the first certificate is the default when no domain matches.
 type SSL struct {
 	Domain string  `config:"domain"`
 	Cert   SSLCert `config:"cert"`
 }
 
+// SSLCert holds the paths of a PEM certificate (chain) and its private key.
 type SSLCert struct {
 	Certificate    string `config:"certificate"`
 	CertificateKey string `config:"certificate_key"`
@@ -82,3 +462,7 @@ type SSLCert struct {
 type RateLimit = route.RateLimit
 type IPPolicy = route.IPPolicy
 type CORS = route.CORS
+type ClientCert = route.ClientCert
+
+// Timeout uses service.Timeout so the gateway default merges with service and server overrides.
+type Timeout = service.Timeout
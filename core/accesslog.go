@@ -0,0 +1,48 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/go-zoox/api-gateway/accesslog"
+	"github.com/go-zoox/zoox"
+)
+
+// prepareAccessLog opens the access log, which is written until the gateway shuts down.
+func (c *core) prepareAccessLog() error {
+	accessLog, err := accesslog.New(c.cfg.AccessLog)
+	if err != nil {
+		return fmt.Errorf("failed to prepare access log: %s", err)
+	}
+
+	c.accessLog = accessLog
+	return nil
+}
+
+// shouldEnableAccessLog reports whether requests of any route are logged.
+func (c *core) shouldEnableAccessLog() bool {
+	if c.cfg.AccessLog.Enable {
+		return true
+	}
+
+	for _, route := range c.cfg.Routes {
+		if route.AccessLog.Enable != nil && *route.AccessLog.Enable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// logAccess writes the access log line of a request once it is answered.
+func (c *core) logAccess(ctx *zoox.Context) {
+	ctx.Request = accesslog.Start(ctx.Request)
+	defer func() {
+		size := int64(ctx.Writer.Size())
+		if size < 0 {
+			size = 0
+		}
+		c.accessLog.Log(ctx.Request, ctx.Writer.Status(), size)
+	}()
+
+	ctx.Next()
+}
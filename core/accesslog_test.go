@@ -0,0 +1,90 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+)
+
+func TestAccessLog(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+	disabled := false
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	gw, _, url := serveTestGateway(t, &config.Config{
+		AccessLog: config.AccessLog{
+			Enable: true,
+			Output: "file",
+			File:   config.AccessLogFile{Path: path},
+		},
+		Routes: []route.Route{
+			{
+				Name:    "logged",
+				Path:    "/api",
+				Backend: route.Backend{Service: testService(t, upstream)},
+			},
+			{
+				Name:      "quiet",
+				Path:      "/health",
+				Backend:   route.Backend{Service: testService(t, upstream)},
+				AccessLog: route.AccessLog{Enable: &disabled},
+			},
+			{
+				Name:     "denied",
+				Path:     "/denied",
+				Backend:  route.Backend{Service: testService(t, upstream)},
+				IPPolicy: route.IPPolicy{Enable: true, Deny: []string{"127.0.0.1/32"}},
+			},
+		},
+	})
+
+	get(t, url+"/api/users?id=1")
+	get(t, url+"/health")
+	if status, _ := get(t, url+"/denied"); status != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", status)
+	}
+	if err := gw.accessLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", content)
+	}
+
+	var logged, denied map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &logged); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &denied); err != nil {
+		t.Fatal(err)
+	}
+
+	if logged["route"] != "logged" || logged["uri"] != "/api/users?id=1" || logged["status"] != float64(200) {
+		t.Errorf("unexpected line %s", lines[0])
+	}
+	if logged["upstream_addr"] != upstream.Listener.Addr().String() || logged["upstream_status"] != float64(200) {
+		t.Errorf("expected the upstream of the request, got %s", lines[0])
+	}
+	if logged["bytes_sent"] != float64(1) {
+		t.Errorf("expected 1 byte sent, got %v", logged["bytes_sent"])
+	}
+
+	// answered by the IP policy, without reaching the upstream
+	if denied["route"] != "denied" || denied["status"] != float64(403) {
+		t.Errorf("unexpected line %s", lines[1])
+	}
+	if _, ok := denied["upstream_addr"]; ok {
+		t.Errorf("expected no upstream, got %s", lines[1])
+	}
+}
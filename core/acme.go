@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-zoox/api-gateway/config"
+	zc "github.com/go-zoox/cache"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newACMEManager creates the ACME certificate manager. Certificates, the account key
+// and HTTP-01 tokens are kept in storage, so gateway replicas sharing it share them too.
+func newACMEManager(cfg config.ACME, cache zc.Cache) (*autocert.Manager, error) {
+	if len(cfg.Hosts) == 0 {
+		return nil, fmt.Errorf("acme requires at least one host")
+	}
+
+	m := &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		HostPolicy:  autocert.HostWhitelist(cfg.Hosts...),
+		Email:       cfg.Email,
+		RenewBefore: cfg.EffectiveRenewBefore(),
+		Client: &acme.Client{
+			DirectoryURL: cfg.DirectoryURL,
+		},
+	}
+	if m.Client.DirectoryURL == "" {
+		m.Client.DirectoryURL = autocert.DefaultACMEDirectory
+	}
+
+	if cfg.DirectoryCA != "" {
+		pem, err := os.ReadFile(cfg.DirectoryCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read acme directory_ca: %s", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("acme directory_ca %s contains no certificate", cfg.DirectoryCA)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+		m.Client.HTTPClient = &http.Client{Transport: transport}
+	}
+
+	switch cfg.Storage {
+	case "", "dir":
+		dir := cfg.EffectiveDir()
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create acme dir: %s", err)
+		}
+		m.Cache = autocert.DirCache(dir)
+	case "cache":
+		m.Cache = &acmeCache{cache: cache}
+	default:
+		return nil, fmt.Errorf("unsupported acme storage %q (expected dir or cache)", cfg.Storage)
+	}
+
+	return m, nil
+}
+
+// acmeEntry is an ACME storage item, stored in Application.Cache() (Redis JSON or memory KV).
+type acmeEntry struct {
+	Data []byte `json:"data"`
+}
+
+// acmeCache stores ACME data in Application.Cache() under acme:<key>.
+type acmeCache struct {
+	cache zc.Cache
+}
+
+func acmeCacheKey(key string) string {
+	return "acme:" + key
+}
+
+func (c *acmeCache) Get(ctx context.Context, key string) ([]byte, error) {
+	k := acmeCacheKey(key)
+	if !c.cache.Has(k) {
+		return nil, autocert.ErrCacheMiss
+	}
+
+	var entry acmeEntry
+	if err := c.cache.Get(k, &entry); err != nil {
+		return nil, autocert.ErrCacheMiss
+	}
+	return entry.Data, nil
+}
+
+func (c *acmeCache) Put(ctx context.Context, key string, data []byte) error {
+	return c.cache.Set(acmeCacheKey(key), &acmeEntry{Data: data})
+}
+
+func (c *acmeCache) Delete(ctx context.Context, key string) error {
+	return c.cache.Del(acmeCacheKey(key))
+}
+
+// getCertificate serves ACME certificates for the acme hosts and TLS-ALPN-01
+// challenges, and the configured ssl certificates otherwise.
+func (c *core) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if c.acme != nil {
+		if isACMEChallenge(hello) || c.isACMEHost(hello.ServerName) {
+			return c.acme.GetCertificate(hello)
+		}
+	}
+
+	return c.certificates.GetCertificate(hello)
+}
+
+func (c *core) isACMEHost(serverName string) bool {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	for _, host := range c.cfg.HTTPS.ACME.Hosts {
+		if strings.EqualFold(host, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isACMEChallenge reports whether the handshake is a TLS-ALPN-01 validation by the CA.
+func isACMEChallenge(hello *tls.ClientHelloInfo) bool {
+	return len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto
+}
+
+// acmeHTTPHandler answers HTTP-01 challenges on a plain HTTP listener, passing every
+// other request to next.
+func (c *core) acmeHTTPHandler(next http.Handler) http.Handler {
+	if c.acme == nil {
+		return next
+	}
+	return c.acme.HTTPHandler(next)
+}
@@ -0,0 +1,237 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	zc "github.com/go-zoox/cache"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func TestACMECache(t *testing.T) {
+	cache := &acmeCache{cache: zc.New()}
+	ctx := context.Background()
+
+	if _, err := cache.Get(ctx, "example.com"); !errors.Is(err, autocert.ErrCacheMiss) {
+		t.Fatalf("expected a cache miss, got %v", err)
+	}
+
+	if err := cache.Put(ctx, "example.com", []byte("certificate")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := cache.Get(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "certificate" {
+		t.Fatalf("unexpected data %q", data)
+	}
+
+	if err := cache.Delete(ctx, "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Get(ctx, "example.com"); !errors.Is(err, autocert.ErrCacheMiss) {
+		t.Fatalf("expected a cache miss after delete, got %v", err)
+	}
+}
+
+func TestNewACMEManager(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	m, err := newACMEManager(config.ACME{Enable: true, Hosts: []string{"example.com"}}, zc.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Client.DirectoryURL != autocert.DefaultACMEDirectory {
+		t.Fatalf("expected the Let's Encrypt directory, got %s", m.Client.DirectoryURL)
+	}
+	if dir, ok := m.Cache.(autocert.DirCache); !ok || dir != "acme" {
+		t.Fatalf("expected the acme dir storage by default, got %T %v", m.Cache, m.Cache)
+	}
+	if info, err := os.Stat("acme"); err != nil || !info.IsDir() {
+		t.Fatalf("expected the acme dir to be created, got %v", err)
+	}
+
+	m, err = newACMEManager(config.ACME{Enable: true, Hosts: []string{"example.com"}, Storage: "cache"}, zc.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Cache.(*acmeCache); !ok {
+		t.Fatalf("expected the cache storage, got %T", m.Cache)
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []config.ACME{
+		{Enable: true},
+		{Enable: true, Hosts: []string{"example.com"}, Storage: "s3"},
+		{Enable: true, Hosts: []string{"example.com"}, Storage: "dir", Dir: filepath.Join(file, "acme")},
+		{Enable: true, Hosts: []string{"example.com"}, DirectoryCA: "/nonexistent/ca.pem"},
+	}
+	for _, cfg := range invalid {
+		if _, err := newACMEManager(cfg, zc.New()); err == nil {
+			t.Errorf("expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestACMECertificateSelection(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New("test", &config.Config{
+		HTTPS: config.HTTPS{
+			Port: 8443,
+			ACME: config.ACME{Enable: true, Hosts: []string{"acme.example.com"}, Storage: "dir", Dir: dir},
+		},
+		SSL: []config.SSL{writeTestCertificate(t, dir, "static", "static.example.com")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := c.(*core)
+
+	if !gw.isACMEHost("ACME.example.com.") {
+		t.Fatal("expected acme.example.com to be an acme host")
+	}
+	if gw.isACMEHost("static.example.com") {
+		t.Fatal("expected static.example.com not to be an acme host")
+	}
+
+	// names outside the acme hosts keep their configured certificate
+	certificate, err := gw.getCertificate(&tls.ClientHelloInfo{ServerName: "static.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := certificateName(t, certificate); got != "static.example.com" {
+		t.Fatalf("expected the static certificate, got %s", got)
+	}
+
+	if !isACMEChallenge(&tls.ClientHelloInfo{SupportedProtos: []string{acme.ALPNProto}}) {
+		t.Fatal("expected a tls-alpn-01 handshake to be an acme challenge")
+	}
+
+	found := false
+	for _, proto := range gw.tlsConfig.NextProtos {
+		found = found || proto == acme.ALPNProto
+	}
+	if !found {
+		t.Fatalf("expected %s in the negotiated protocols, got %v", acme.ALPNProto, gw.tlsConfig.NextProtos)
+	}
+}
+
+// TestACMEPebble obtains certificates from a local Pebble ACME server
+// (https://github.com/letsencrypt/pebble). It runs when PEBBLE_DIRECTORY_URL is set, e.g.:
+//
+//	pebble-challtestsrv -defaultIPv4 127.0.0.1 &
+//	pebble -config test/config/pebble-config.json -dnsserver 127.0.0.1:8053 &
+//	PEBBLE_DIRECTORY_URL=https://127.0.0.1:14000/dir PEBBLE_CA=test/certs/pebble.minica.pem go test ./core -run Pebble
+//
+// Pebble validates HTTP-01 on port 5002 and TLS-ALPN-01 on port 5001 by default
+// (PEBBLE_HTTP_PORT and PEBBLE_TLS_PORT), where the gateway listens during the test.
+func TestACMEPebble(t *testing.T) {
+	directoryURL := os.Getenv("PEBBLE_DIRECTORY_URL")
+	if directoryURL == "" {
+		t.Skip("PEBBLE_DIRECTORY_URL is not set")
+	}
+
+	host := envOrDefault("PEBBLE_HOST", "gateway.test")
+	httpPort := envOrDefault("PEBBLE_HTTP_PORT", "5002")
+	tlsPort := envOrDefault("PEBBLE_TLS_PORT", "5001")
+
+	cases := []struct {
+		name    string
+		tlsAddr string
+	}{
+		// the CA reaches the HTTPS listener: tls-alpn-01
+		{"tls-alpn-01", "127.0.0.1:" + tlsPort},
+		// the CA cannot reach the HTTPS listener and falls back to http-01
+		{"http-01", "127.0.0.1:0"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := New("test", &config.Config{
+				HTTPS: config.HTTPS{
+					Port: 443,
+					ACME: config.ACME{
+						Enable:       true,
+						Hosts:        []string{host},
+						DirectoryURL: directoryURL,
+						DirectoryCA:  os.Getenv("PEBBLE_CA"),
+						Storage:      "dir",
+						Dir:          t.TempDir(),
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			gw := c.(*core)
+			if err := gw.build(); err != nil {
+				t.Fatal(err)
+			}
+
+			serve(t, gw.newServer(""), "127.0.0.1:"+httpPort, false)
+			tlsAddr := serve(t, gw.newTLSServer(""), tc.tlsAddr, true)
+
+			conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Minute}, "tcp", tlsAddr, &tls.Config{
+				ServerName:         host,
+				InsecureSkipVerify: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			leaf := conn.ConnectionState().PeerCertificates[0]
+			if err := leaf.VerifyHostname(host); err != nil {
+				t.Fatal(err)
+			}
+			if leaf.Issuer.String() == leaf.Subject.String() {
+				t.Fatalf("expected a certificate issued by the ACME CA, got a self-signed %s", leaf.Subject)
+			}
+		})
+	}
+}
+
+func envOrDefault(key, value string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return value
+}
+
+// serve runs server on addr until the test ends and returns the listening address.
+func serve(t *testing.T, server *http.Server, addr string, useTLS bool) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		if useTLS {
+			_ = server.ServeTLS(lis, "", "")
+		} else {
+			_ = server.Serve(lis)
+		}
+	}()
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	return fmt.Sprint(lis.Addr())
+}
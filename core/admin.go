@@ -0,0 +1,348 @@
+package core
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/loadbalancer"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/plugin"
+	"github.com/go-zoox/api-gateway/router"
+	"github.com/go-zoox/logger"
+)
+
+// newAdminServer creates the admin API server, listening on its own port.
+func (c *core) newAdminServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /reload", c.adminReload)
+	mux.HandleFunc("GET /routes", c.adminRoutes)
+	mux.HandleFunc("GET /backends", c.adminBackends)
+	mux.HandleFunc("POST /backends/{backend}/servers/{server}/disable", c.adminSetDisabled(true))
+	mux.HandleFunc("POST /backends/{backend}/servers/{server}/enable", c.adminSetDisabled(false))
+	mux.HandleFunc("POST /backends/{backend}/health-check", c.adminHealthCheck)
+	if c.cfg.Metrics.Enable {
+		mux.Handle("GET /metrics", c.metricsHandler())
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           c.adminAuth(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// adminAuth requires the admin token as a bearer token, or the admin username and
+// password with basic auth, when they are configured.
+func (c *core) adminAuth(next http.Handler) http.Handler {
+	// without credentials every request is refused; validate keeps such a
+	// configuration from starting the admin API at all
+	admin := c.cfg.Admin
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !adminAuthorized(admin, r) {
+			if admin.Token != "" {
+				w.Header().Add("WWW-Authenticate", `Bearer realm="api-gateway admin"`)
+			}
+			if admin.Username != "" {
+				w.Header().Add("WWW-Authenticate", `Basic realm="api-gateway admin"`)
+			}
+			writeAdminJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func adminAuthorized(admin config.Admin, r *http.Request) bool {
+	if admin.Token != "" {
+		if provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureEqual(provided, admin.Token) {
+			return true
+		}
+	}
+
+	if admin.Username != "" {
+		if username, password, ok := r.BasicAuth(); ok {
+			// both are compared, so the time taken does not tell which one is wrong
+			usernameOK := secureEqual(username, admin.Username)
+			passwordOK := secureEqual(password, admin.Password)
+			return usernameOK && passwordOK
+		}
+	}
+
+	return false
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// adminReload reloads the configuration file.
+func (c *core) adminReload(w http.ResponseWriter, r *http.Request) {
+	if err := c.reloadFromSource("admin API"); err != nil {
+		logger.Error("%s", err)
+
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrNoConfigSource) {
+			status = http.StatusConflict
+		}
+		writeAdminJSON(w, status, map[string]any{"error": err.Error()})
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, map[string]any{
+		"generation": c.shared.current.Load().generation,
+	})
+}
+
+// adminRoutes lists the routes of the running configuration, with the default backends of
+// the hosts and the default backend last, and the configuration each plugin applies to them,
+// and the conflicts of the routes.
+func (c *core) adminRoutes(w http.ResponseWriter, r *http.Request) {
+	current := c.shared.current.Load()
+
+	routes := make([]*route.Route, 0, len(current.cfg.Routes)+1)
+	for i := range current.cfg.Routes {
+		routes = append(routes, &current.cfg.Routes[i])
+	}
+	// the routes requests fall back to when no route matches
+	routes = append(routes, router.HostDefaults(current.cfg)...)
+	if current.cfg.Backend.Service.Name != "" {
+		routes = append(routes, &route.Route{Name: "default", Backend: current.cfg.Backend})
+	}
+
+	items := make([]map[string]any, 0, len(routes))
+	for _, rt := range routes {
+		plugins := map[string]any{}
+		for _, p := range current.plugins {
+			if describer, ok := p.(plugin.Describer); ok {
+				if name, cfg := describer.Describe(rt); cfg != nil {
+					plugins[name] = configValue(reflect.ValueOf(cfg))
+				}
+			}
+		}
+
+		item := map[string]any{
+			"name":      rt.Name,
+			"path":      rt.Path,
+			"path_type": rt.PathType,
+			"service":   configValue(reflect.ValueOf(rt.Backend.Service)),
+			"websocket": configValue(reflect.ValueOf(rt.WebSocket)),
+			"plugins":   plugins,
+		}
+		if len(rt.Hosts) > 0 {
+			item["hosts"] = rt.Hosts
+		}
+		if rt.Priority != 0 {
+			item["priority"] = rt.Priority
+		}
+		if backend := rt.Backend.Normalize(); backend != nil {
+			item["backend"] = loadbalancer.BackendID(backend)
+		}
+		if len(rt.Backends) > 0 {
+			splits := make([]map[string]any, 0, len(rt.Backends))
+			for _, split := range rt.Backends {
+				entry := map[string]any{
+					"name":    split.Name,
+					"weight":  split.Weight,
+					"service": configValue(reflect.ValueOf(split.Service)),
+				}
+				backend := split.Backend()
+				if normalized := backend.Normalize(); normalized != nil {
+					entry["backend"] = loadbalancer.BackendID(normalized)
+				}
+				splits = append(splits, entry)
+			}
+			item["backends"] = splits
+			item["sticky"] = configValue(reflect.ValueOf(rt.Sticky))
+		}
+		items = append(items, item)
+	}
+
+	conflicts := []string{}
+	for _, conflict := range current.matcher.Conflicts() {
+		conflicts = append(conflicts, conflict.String())
+	}
+
+	writeAdminJSON(w, http.StatusOK, map[string]any{
+		"generation": current.generation,
+		"routes":     items,
+		"conflicts":  conflicts,
+	})
+}
+
+// adminBackends lists the backends of the running configuration with the state of their servers.
+func (c *core) adminBackends(w http.ResponseWriter, r *http.Request) {
+	current := c.shared.current.Load()
+
+	backends := current.backendGroups()
+	items := make([]map[string]any, 0, len(backends))
+	for _, backend := range backends {
+		items = append(items, current.describeBackend(backend))
+	}
+
+	writeAdminJSON(w, http.StatusOK, map[string]any{
+		"generation": current.generation,
+		"backends":   items,
+	})
+}
+
+// adminSetDisabled disables or enables a server of a backend until the next reload.
+func (c *core) adminSetDisabled(disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current := c.shared.current.Load()
+
+		backend := current.backendGroup(r.PathValue("backend"))
+		if backend == nil {
+			writeAdminJSON(w, http.StatusNotFound, map[string]any{"error": "backend not found"})
+			return
+		}
+		servers := backend.servers(r.PathValue("server"))
+		if len(servers) == 0 {
+			writeAdminJSON(w, http.StatusNotFound, map[string]any{"error": "server not found"})
+			return
+		}
+		// a single service is a new server on every request, with nothing to keep the flag on
+		if len(backend.backends[0].BaseConfig.Servers) == 0 {
+			writeAdminJSON(w, http.StatusConflict, map[string]any{"error": "only servers listed in service.servers can be disabled"})
+			return
+		}
+
+		for _, server := range servers {
+			server.SetDisabled(disabled)
+		}
+
+		action := "disabled"
+		if !disabled {
+			action = "enabled"
+			// health checks mark disabled servers unhealthy; check the server again at once
+			current.lbManager.CheckHealth(backend.id)
+		}
+		logger.Info("Server %s of backend %s %s through the admin API", r.PathValue("server"), backend.id, action)
+
+		writeAdminJSON(w, http.StatusOK, current.describeBackend(backend))
+	}
+}
+
+// adminHealthCheck checks the servers of a backend at once.
+func (c *core) adminHealthCheck(w http.ResponseWriter, r *http.Request) {
+	current := c.shared.current.Load()
+
+	backend := current.backendGroup(r.PathValue("backend"))
+	if backend == nil {
+		writeAdminJSON(w, http.StatusNotFound, map[string]any{"error": "backend not found"})
+		return
+	}
+	if !current.lbManager.CheckHealth(backend.id) {
+		writeAdminJSON(w, http.StatusConflict, map[string]any{"error": "health check is not enabled for the backend"})
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, current.describeBackend(backend))
+}
+
+// describeBackend reports the servers of a backend as load balancing sees them.
+func (c *core) describeBackend(b *backendGroup) map[string]any {
+	backend := b.backends[0]
+	inflight := c.lbManager.GetInFlight()
+	breakers := c.lbManager.GetCircuitBreakers()
+	passive := c.lbManager.GetPassiveHealthChecks()
+
+	healthCheck := backend.BaseConfig.HealthCheck
+	servers := make([]map[string]any, 0, len(backend.Servers))
+	for _, server := range backend.Servers {
+		servers = append(servers, map[string]any{
+			"id":        server.ID(),
+			"name":      server.Name,
+			"port":      server.Port,
+			"weight":    server.Weight,
+			"disabled":  server.IsDisabled(),
+			"healthy":   server.IsHealthy(),
+			"in_flight": inflight.Count(backend, server),
+			"circuit":   breakers.State(backend, server).String(),
+			"ejected":   passive.Ejected(backend, server),
+		})
+	}
+
+	return map[string]any{
+		"id":           b.id,
+		"algorithm":    backend.Algorithm,
+		"routes":       b.routes,
+		"health_check": healthCheck.Enable && !healthCheck.Ok,
+		"servers":      servers,
+	}
+}
+
+// adminSecrets are the configuration keys and header names whose values are not shown.
+var adminSecrets = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"tokens":        true,
+	"secret":        true,
+	"client_secret": true,
+	"dsn":           true,
+	"authorization": true,
+}
+
+// configValue converts a configuration value for JSON, with the keys of the
+// configuration file and secrets masked.
+func configValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return configValue(v.Elem())
+	case reflect.Struct:
+		out := map[string]any{}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("config"), ",")
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			out[name] = maskSecret(name, v.Field(i))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := map[string]any{}
+		for _, key := range v.MapKeys() {
+			name := fmt.Sprint(key.Interface())
+			out[name] = maskSecret(strings.ToLower(name), v.MapIndex(key))
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = configValue(v.Index(i))
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+func maskSecret(name string, v reflect.Value) any {
+	if adminSecrets[name] && !v.IsZero() {
+		return "******"
+	}
+	return configValue(v)
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
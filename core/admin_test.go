@@ -0,0 +1,327 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// adminRequest sends a request to the admin API and decodes its JSON answer.
+func adminRequest(t *testing.T, method, url string, setAuth func(*http.Request)) (int, map[string]any) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setAuth != nil {
+		setAuth(req)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body map[string]any
+	_ = json.NewDecoder(res.Body).Decode(&body)
+	return res.StatusCode, body
+}
+
+// withToken authenticates an admin request with the "secret" token.
+func withToken(r *http.Request) {
+	r.Header.Set("Authorization", "Bearer secret")
+}
+
+func TestAdminAuth(t *testing.T) {
+	gw, _, _ := serveTestGateway(t, &config.Config{
+		Admin: config.Admin{Token: "secret", Username: "admin", Password: "pass"},
+	})
+	admin := newTestServer(t, gw.newAdminServer("").Handler.ServeHTTP)
+
+	cases := []struct {
+		name    string
+		setAuth func(*http.Request)
+		status  int
+	}{
+		{"none", nil, http.StatusUnauthorized},
+		{"token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusOK},
+		{"wrong token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{"basic", func(r *http.Request) { r.SetBasicAuth("admin", "pass") }, http.StatusOK},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		if status, _ := adminRequest(t, http.MethodGet, admin.URL+"/routes", tc.setAuth); status != tc.status {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.status, status)
+		}
+	}
+
+	res, err := http.Get(admin.URL + "/routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if challenges := res.Header.Values("WWW-Authenticate"); len(challenges) != 2 {
+		t.Fatalf("expected bearer and basic challenges, got %v", challenges)
+	}
+}
+
+func TestAdminRequiresCredentials(t *testing.T) {
+	cases := []struct {
+		name  string
+		admin config.Admin
+	}{
+		{"none", config.Admin{Port: 9901}},
+		{"username without password", config.Admin{Port: 9901, Username: "admin"}},
+	}
+	for _, tc := range cases {
+		if _, err := New("test", &config.Config{Admin: tc.admin}); err == nil {
+			t.Errorf("%s: expected the admin API to be refused", tc.name)
+		}
+	}
+
+	if _, err := New("test", &config.Config{Admin: config.Admin{Port: 9901, Token: "secret"}}); err != nil {
+		t.Fatalf("expected a token to be enough, got %s", err)
+	}
+
+	// a handler built without credentials refuses every request
+	gw, _, _ := serveTestGateway(t, &config.Config{})
+	admin := newTestServer(t, gw.newAdminServer("").Handler.ServeHTTP)
+	if status, _ := adminRequest(t, http.MethodGet, admin.URL+"/routes", nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", status)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+
+	svc := testService(t, upstream)
+	svc.Auth = service.Auth{Type: "basic", Username: "user", Password: "hidden"}
+
+	gw, _, _ := serveTestGateway(t, &config.Config{
+		Admin:     config.Admin{Token: "secret"},
+		RateLimit: config.RateLimit{Enable: true, Limit: 100, Window: 60},
+		Backend:   route.Backend{Service: testService(t, upstream)},
+		Routes: []route.Route{
+			{
+				Name:      "api",
+				Path:      "/api",
+				Backend:   route.Backend{Service: svc},
+				RateLimit: route.RateLimit{Enable: true, Limit: 10, Window: 1},
+				CORS:      route.CORS{Enable: true, AllowOrigins: []string{"https://example.com"}},
+			},
+			{
+				Name:    "users",
+				Path:    "/api/users",
+				Backend: route.Backend{Service: svc},
+			},
+		},
+	})
+	admin := newTestServer(t, gw.newAdminServer("").Handler.ServeHTTP)
+
+	status, body := adminRequest(t, http.MethodGet, admin.URL+"/routes", withToken)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	routes := body["routes"].([]any)
+	if len(routes) != 3 {
+		t.Fatalf("expected the routes and the default backend, got %d routes", len(routes))
+	}
+	if conflicts := body["conflicts"].([]any); len(conflicts) != 1 || !strings.Contains(conflicts[0].(string), "route users (#2)") {
+		t.Fatalf("expected the shadowed route, got %v", conflicts)
+	}
+
+	api := routes[0].(map[string]any)
+	plugins := api["plugins"].(map[string]any)
+	if limit := plugins["rate_limit"].(map[string]any)["limit"]; limit != float64(10) {
+		t.Fatalf("expected the route rate limit, got %v", limit)
+	}
+	if origins := plugins["cors"].(map[string]any)["allow_origins"].([]any); len(origins) != 1 || origins[0] != "https://example.com" {
+		t.Fatalf("expected the route CORS origins, got %v", origins)
+	}
+	if api["backend"] == nil || api["backend"] == "" {
+		t.Fatal("expected the backend ID")
+	}
+	auth := api["service"].(map[string]any)["auth"].(map[string]any)
+	if auth["username"] != "user" || auth["password"] != "******" {
+		t.Fatalf("expected the password to be masked, got %v", auth)
+	}
+
+	fallback := routes[2].(map[string]any)
+	if fallback["name"] != "default" {
+		t.Fatalf("expected the default backend last, got %v", fallback["name"])
+	}
+	if limit := fallback["plugins"].(map[string]any)["rate_limit"].(map[string]any)["limit"]; limit != float64(100) {
+		t.Fatalf("expected the global rate limit on the default backend, got %v", limit)
+	}
+}
+
+func TestAdminBackends(t *testing.T) {
+	a := newNamedUpstream(t, "a")
+	b := newNamedUpstream(t, "b")
+
+	gw, _, url := serveTestGateway(t, &config.Config{
+		Admin: config.Admin{Token: "secret"},
+		Routes: []route.Route{
+			{
+				Name: "api",
+				Path: "/api",
+				Backend: route.Backend{
+					Service: service.Service{
+						Protocol: "http",
+						Servers:  []service.Server{*testServer(t, a), *testServer(t, b)},
+					},
+				},
+			},
+		},
+	})
+	admin := newTestServer(t, gw.newAdminServer("").Handler.ServeHTTP)
+
+	status, body := adminRequest(t, http.MethodGet, admin.URL+"/backends", withToken)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	backends := body["backends"].([]any)
+	if len(backends) != 1 {
+		t.Fatalf("expected 1 backend, got %d", len(backends))
+	}
+	backend := backends[0].(map[string]any)
+	if routes := backend["routes"].([]any); len(routes) != 1 || routes[0] != "api" {
+		t.Fatalf("expected the backend of route api, got %v", routes)
+	}
+	servers := backend["servers"].([]any)
+	if len(servers) != 2 {
+		t.Fatalf("expected 2 servers, got %d", len(servers))
+	}
+	for _, s := range servers {
+		server := s.(map[string]any)
+		if server["healthy"] != true || server["disabled"] != false || server["in_flight"] != float64(0) {
+			t.Fatalf("expected an idle healthy server, got %v", server)
+		}
+	}
+
+	id := backend["id"].(string)
+	serverA := testServer(t, a).ID()
+
+	status, body = adminRequest(t, http.MethodPost, admin.URL+"/backends/"+id+"/servers/"+serverA+"/disable", withToken)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d %v", status, body)
+	}
+	for i := 0; i < 4; i++ {
+		if _, body := get(t, url+"/api"); body != "b" {
+			t.Fatalf("expected the disabled server to be skipped, got %q", body)
+		}
+	}
+
+	if status, _ := adminRequest(t, http.MethodPost, admin.URL+"/backends/"+id+"/servers/"+serverA+"/enable", withToken); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		_, body := get(t, url+"/api")
+		seen[body] = true
+	}
+	if !seen["a"] || !seen["b"] {
+		t.Fatalf("expected both servers after enabling, got %v", seen)
+	}
+
+	if status, _ := adminRequest(t, http.MethodPost, admin.URL+"/backends/"+id+"/servers/unknown:1/disable", withToken); status != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown server, got %d", status)
+	}
+	if status, _ := adminRequest(t, http.MethodPost, admin.URL+"/backends/unknown/health-check", withToken); status != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown backend, got %d", status)
+	}
+	if status, _ := adminRequest(t, http.MethodPost, admin.URL+"/backends/"+id+"/health-check", withToken); status != http.StatusConflict {
+		t.Fatalf("expected 409 without health check, got %d", status)
+	}
+}
+
+func TestAdminBackendsInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "slow")
+	})
+
+	gw, _, url := serveTestGateway(t, &config.Config{
+		Admin: config.Admin{Token: "secret"},
+		Routes: []route.Route{
+			{Name: "api", Path: "/api", Backend: route.Backend{Service: testService(t, slow)}},
+		},
+	})
+	admin := newTestServer(t, gw.newAdminServer("").Handler.ServeHTTP)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		get(t, url+"/api")
+	}()
+	<-started
+
+	inFlight := func() any {
+		_, body := adminRequest(t, http.MethodGet, admin.URL+"/backends", withToken)
+		server := body["backends"].([]any)[0].(map[string]any)["servers"].([]any)[0]
+		return server.(map[string]any)["in_flight"]
+	}
+	if n := inFlight(); n != float64(1) {
+		t.Fatalf("expected 1 request in flight, got %v", n)
+	}
+
+	close(release)
+	<-done
+	if n := inFlight(); n != float64(0) {
+		t.Fatalf("expected no request in flight, got %v", n)
+	}
+}
+
+func TestAdminHealthCheck(t *testing.T) {
+	var unhealthy atomic.Bool
+	upstream := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/health") && unhealthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	})
+
+	gw, _, _ := serveTestGateway(t, &config.Config{
+		Admin: config.Admin{Token: "secret"},
+		Routes: []route.Route{
+			{
+				Name: "api",
+				Path: "/api",
+				Backend: route.Backend{
+					Service: service.Service{
+						Protocol:    "http",
+						Servers:     []service.Server{*testServer(t, upstream)},
+						HealthCheck: service.HealthCheck{Enable: true, Interval: 3600},
+					},
+				},
+			},
+		},
+	})
+	admin := newTestServer(t, gw.newAdminServer("").Handler.ServeHTTP)
+
+	_, body := adminRequest(t, http.MethodGet, admin.URL+"/backends", withToken)
+	id := body["backends"].([]any)[0].(map[string]any)["id"].(string)
+
+	// the next scheduled check is an hour away
+	unhealthy.Store(true)
+	status, body := adminRequest(t, http.MethodPost, admin.URL+"/backends/"+id+"/health-check", withToken)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	server := body["servers"].([]any)[0].(map[string]any)
+	if server["healthy"] != false {
+		t.Fatalf("expected the server to be found unhealthy at once, got %v", server)
+	}
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+func TestAuth(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+
+	basic := testService(t, upstream)
+	basic.Auth = service.Auth{Type: "basic", Realm: "admin", Username: "ops", Password: "secret"}
+
+	// the server overrides the auth of its service
+	svc := testService(t, upstream)
+	perServer := service.Service{
+		Protocol: "http",
+		Auth:     service.Auth{Type: "basic", Username: "ops", Password: "secret"},
+		Servers: []service.Server{
+			{Name: svc.Name, Port: svc.Port, Auth: &service.Auth{Type: "bearer", Token: "server-token"}},
+		},
+	}
+
+	// oauth2 is accepted but not enforced yet
+	reserved := testService(t, upstream)
+	reserved.Auth = service.Auth{Type: "oauth2", Provider: "github"}
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Metrics:   config.Metrics{Enable: true},
+		HTTPCache: config.HTTPCache{Enable: true, CacheAuthorizedRequests: true},
+		Routes: []route.Route{
+			{Name: "auth-basic", Path: "/basic", Backend: route.Backend{Service: basic}},
+			{Name: "auth-server", Path: "/server", Backend: route.Backend{Service: perServer}},
+			{Name: "auth-open", Path: "/open", Backend: route.Backend{Service: testService(t, upstream)}},
+			{Name: "auth-reserved", Path: "/oauth2", Backend: route.Backend{Service: reserved}},
+		},
+	})
+
+	do := func(path, authorization string) (*http.Response, string) {
+		req, _ := http.NewRequest(http.MethodGet, url+path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res, string(body)
+	}
+
+	// a cached response of an authenticated request is not served without credentials
+	if res, body := do("/basic", "Basic b3BzOnNlY3JldA=="); res.StatusCode != http.StatusOK || body != "a" {
+		t.Fatalf("expected the credentials to be accepted, got %d %q", res.StatusCode, body)
+	}
+	res, _ := do("/basic", "")
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", res.StatusCode)
+	}
+	if challenge := res.Header.Get("WWW-Authenticate"); challenge != `Basic realm="admin", charset="UTF-8"` {
+		t.Errorf("unexpected challenge %q", challenge)
+	}
+	if res, _ := do("/basic", "Basic b3BzOndyb25n"); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a wrong password to be denied, got %d", res.StatusCode)
+	}
+
+	if res, _ := do("/server", "Basic b3BzOnNlY3JldA=="); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the auth of the server to replace the auth of the service, got %d", res.StatusCode)
+	}
+	if res, body := do("/server", "Bearer server-token"); res.StatusCode != http.StatusOK || body != "a" {
+		t.Errorf("expected the token of the server to be accepted, got %d %q", res.StatusCode, body)
+	}
+	res, _ = do("/server", "Bearer wrong")
+	if challenge := res.Header.Get("WWW-Authenticate"); res.StatusCode != http.StatusUnauthorized || challenge != `Bearer realm="api-gateway", error="invalid_token", error_description="invalid token"` {
+		t.Errorf("expected an invalid_token challenge, got %d %q", res.StatusCode, challenge)
+	}
+
+	if res, body := do("/open", ""); res.StatusCode != http.StatusOK || body != "a" {
+		t.Errorf("expected services without auth to stay open, got %d %q", res.StatusCode, body)
+	}
+	if res, body := do("/oauth2", ""); res.StatusCode != http.StatusOK || body != "a" {
+		t.Errorf("expected oauth2 requests to pass through, got %d %q", res.StatusCode, body)
+	}
+
+	_, metrics := get(t, url+"/metrics")
+	if metricValue(metrics, `api_gateway_auth_requests_total{result="denied",route="auth-basic",type="basic"}`) != 2 {
+		t.Errorf("expected the denied requests to be counted, got\n%s", metrics)
+	}
+}
+
+func TestAuthInvalid(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+
+	cases := map[string]service.Auth{
+		"unsupported type": {Type: "digest"},
+		"no credentials":   {Type: "basic"},
+		"missing htpasswd": {Type: "basic", HTPasswd: filepath.Join(t.TempDir(), "htpasswd")},
+		"no jwt key":       {Type: "jwt"},
+	}
+	for name, auth := range cases {
+		svc := testService(t, upstream)
+		svc.Auth = auth
+		_, err := New("test", &config.Config{
+			Routes: []route.Route{{Name: "auth-invalid", Path: "/api", Backend: route.Backend{Service: svc}}},
+		})
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -3,13 +3,17 @@ package core
 import (
 	"fmt"
 	"net/http"
-	"sync"
 
+	"github.com/go-zoox/api-gateway/accesslog"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+	"github.com/go-zoox/api-gateway/metrics"
 	"github.com/go-zoox/api-gateway/plugin/httpcache"
-	"github.com/go-zoox/logger"
+	"github.com/go-zoox/api-gateway/router"
+	"github.com/go-zoox/api-gateway/tracing"
 	"github.com/go-zoox/proxy"
 	"github.com/go-zoox/zoox"
-	"github.com/go-zoox/zoox/middleware"
+	"github.com/gorilla/websocket"
 )
 
 func (c *core) build() error {
@@ -17,6 +21,12 @@ func (c *core) build() error {
 	c.app.Use(func(ctx *zoox.Context) {
 		if c.cfg.HealthCheck.Outer.Enable {
 			if ctx.Path == c.cfg.HealthCheck.Outer.Path {
+				// load balancers stop sending traffic while the gateway drains
+				if c.shared.draining.Load() {
+					ctx.String(http.StatusServiceUnavailable, "shutting down")
+					return
+				}
+
 				if c.cfg.HealthCheck.Outer.Ok {
 					ctx.String(http.StatusOK, "ok")
 					return
@@ -28,13 +38,13 @@ func (c *core) build() error {
 	})
 
 	// services (core plugin)
-	c.app.Use(middleware.Proxy(func(ctx *zoox.Context, cfg *middleware.ProxyConfig) (next, stop bool, err error) {
+	c.app.Use(proxyMiddleware(func(ctx *zoox.Context, cfg *proxyConfig) (next, stop bool, err error) {
 		method := ctx.Method
 		path := ctx.Path
 
-		r, err := c.match(ctx, path)
+		r, params, err := c.match(ctx)
 		if err != nil {
-			logger.Errorf("[build][path: %s] failed to match route: %s", ctx.Path, err)
+			ctx.Logger.Errorf("[build][path: %s] failed to match route: %s", ctx.Path, err)
 			//
 			return false, false, proxy.NewHTTPError(404, err.Error())
 		}
@@ -42,11 +52,32 @@ func (c *core) build() error {
 		if r == nil {
 			return true, false, nil
 		}
+		if router.FromRequest(ctx.Request) != r {
+			ctx.Request = router.WithRoute(ctx.Request, r, params)
+		}
+		metrics.SetRoute(ctx.Request, r.Name)
+		tracing.SetRoute(ctx.Request, r.Name, r.Path)
+		accesslog.SetRoute(ctx.Request, r)
+
+		// Split the route between its backends by weight
+		backend := r.Backend
+		var split *route.SplitBackend
+		var setStickyCookie bool
+		if len(r.Backends) > 0 {
+			split, setStickyCookie = splitBackend(ctx.Request, r)
+			if split == nil {
+				ctx.Logger.Errorf("[build][path: %s] no backend with a weight above 0", ctx.Path)
+				return false, false, proxy.NewHTTPError(503, "no backend configured")
+			}
+			backend = split.Backend()
+			metrics.SplitRequests.WithLabelValues(r.Name, split.Name).Inc()
+			tracing.SetAttributes(ctx.Request, tracing.BackendKey.String(split.Name))
+		}
 
 		// Normalize backend (handles both single-server and multi-server modes)
-		normalizedBackend := r.Backend.Normalize()
+		normalizedBackend := backend.Normalize()
 		if normalizedBackend == nil {
-			logger.Errorf("[build][path: %s] no backend configured", ctx.Path)
+			ctx.Logger.Errorf("[build][path: %s] no backend configured", ctx.Path)
 			return false, false, proxy.NewHTTPError(503, "no backend configured")
 		}
 
@@ -56,63 +87,59 @@ func (c *core) build() error {
 		// Select server using load balancer
 		server, err := lb.Select(ctx.Request, normalizedBackend)
 		if err != nil {
-			logger.Errorf("[build][path: %s] failed to select server: %s", ctx.Path, err)
+			ctx.Logger.Errorf("[build][path: %s] failed to select server: %s", ctx.Path, err)
 			return false, false, proxy.NewHTTPError(503, err.Error())
 		}
 
-		// Get effective configuration (merges base config with server-specific overrides)
-		effectiveService := server.GetEffectiveConfig(normalizedBackend.BaseConfig)
+		metrics.Selections.WithLabelValues(r.Name, server.ID(), normalizedBackend.Algorithm).Inc()
+		tracing.SetAttributes(ctx.Request, tracing.ServerKey.String(server.ID()), tracing.AlgorithmKey.String(normalizedBackend.Algorithm))
 
 		// DNS check (optional, can be skipped based on health check)
-		if _, err := effectiveService.CheckDNS(); err != nil {
-			logger.Errorf("check dns error: %s", err)
+		if _, err := server.CheckDNS(); err != nil {
+			ctx.Logger.Errorf("check dns error: %s", err)
 			return false, false, proxy.NewHTTPError(503, ErrServiceUnavailable.Error())
 		}
 
-		// Track connection for least-connections algorithm
-		var cleanupOnce *sync.Once
-		if normalizedBackend.Algorithm == "least-connections" {
-			lb.OnRequestStart(normalizedBackend, server)
-
-			// Ensure OnRequestEnd is called even if proxy fails
-			// Use sync.Once to ensure it's only called once
-			cleanupOnce = &sync.Once{}
-			cleanup := func() {
-				cleanupOnce.Do(func() {
-					lb.OnRequestEnd(normalizedBackend, server)
-				})
+		// Effective configuration (merges base config with server-specific overrides) and
+		// upstream timeouts (server overrides service, service overrides the gateway default)
+		u := newUpstream(c, r.Name, normalizedBackend, lb, server)
+		u.params = params
+		cfg.Transport = u
+		// plugins (auth, ...) read the effective service configuration of the server
+		ctx.Request = service.WithService(ctx.Request, u.service)
+		cfg.Timeout = u.timeout.TotalTimeout()
+
+		c.retryBudget.RecordRequest()
+
+		// Retries replay a buffered body, so large bodies are sent once; gRPC calls
+		// stream their body and are never buffered
+		if retry := u.service.Retry; retry.Enabled() && retry.MethodAllowed(method) && !isGRPCRequest(ctx.Request) {
+			if body, ok := bufferRequestBody(ctx.Request, retry.EffectiveMaxBodyBytes()); ok {
+				cfg.Transport = &retryTransport{
+					budget:   c.retryBudget,
+					upstream: u,
+					policy:   retry,
+					body:     body,
+				}
 			}
-
-			// Monitor request context to ensure cleanup on failure
-			go func() {
-				<-ctx.Request.Context().Done()
-				cleanup()
-			}()
 		}
 
-		cfg.OnRequest = func(req, inReq *http.Request) error {
-			req.URL.Scheme = effectiveService.Protocol
-			req.URL.Host = server.Host()
-			req.Host = req.URL.Host
-
-			// apply path rewrite using effective service config
-			req.URL.Path = effectiveService.Rewrite(req.URL.Path)
+		// Copies of the request for the mirror backend, sent once the plugins let it through
+		mirror := c.mirror(ctx, r)
 
-			// apply headers
-			if effectiveService.Request.Headers != nil {
-				for k, v := range effectiveService.Request.Headers {
-					req.Header.Set(k, v)
-				}
-			}
+		// Track in-flight requests (least-connections); ensure the count is released
+		// even if the proxy fails before OnResponse
+		u.begin()
+		done := ctx.Request.Context().Done()
+		go func() {
+			<-done
+			u.release()
+		}()
 
-			// apply query
-			if effectiveService.Request.Query != nil {
-				originQuery := req.URL.Query()
-				for k, v := range effectiveService.Request.Query {
-					originQuery.Set(k, v)
-				}
-				req.URL.RawQuery = originQuery.Encode()
-			}
+		cfg.OnRequest = func(req, inReq *http.Request) error {
+			u.path = req.URL.Path
+			u.rawQuery = req.URL.RawQuery
+			u.apply(req)
 
 			for _, plugin := range c.plugins {
 				if err := plugin.OnRequest(ctx, ctx.Request); err != nil {
@@ -120,15 +147,30 @@ func (c *core) build() error {
 				}
 			}
 
-			ctx.Logger.Infof("[route: %s] %s %s => %s (path: %s, algorithm: %s)", r.Name, method, path, server.Target(), req.URL.Path, normalizedBackend.Algorithm)
+			if mirror != nil {
+				mirror.send(req, u)
+			}
+
+			ctx.Logger.Infof("[route: %s] %s %s => %s (path: %s, algorithm: %s)", r.Name, method, path, u.server.Target(), req.URL.Path, normalizedBackend.Algorithm)
 
 			return nil
 		}
 
 		cfg.OnResponse = func(res *http.Response, inReq *http.Request) error {
+			if mirror != nil {
+				mirror.respond(res.StatusCode)
+			}
+
+			// The gateway echoes the request ID itself
+			res.Header.Del(c.cfg.RequestID.EffectiveHeader())
+
+			if setStickyCookie {
+				res.Header.Add("Set-Cookie", stickyCookie(r, split).String())
+			}
+
 			// Apply response headers from effective service config
-			if effectiveService.Response.Headers != nil {
-				for k, v := range effectiveService.Response.Headers {
+			if u.service.Response.Headers != nil {
+				for k, v := range u.service.Response.Headers {
 					res.Header.Set(k, v)
 				}
 			}
@@ -141,18 +183,30 @@ func (c *core) build() error {
 
 			res.Header.Set("X-Powered-By", fmt.Sprintf("gozoox-api-gateway/%s", c.version))
 
-			// Decrement connection count for least-connections algorithm
-			// This ensures cleanup happens when OnResponse is called (success case)
-			if normalizedBackend.Algorithm == "least-connections" && cleanupOnce != nil {
-				cleanupOnce.Do(func() {
-					lb.OnRequestEnd(normalizedBackend, server)
-				})
+			// Trailers can only follow a body of unknown length (chunked on HTTP/1.1),
+			// so an HTTP/2 upstream's Content-Length must not be passed on with them
+			if len(res.Trailer) > 0 && res.ContentLength > 0 {
+				res.Header.Del("Content-Length")
+				res.ContentLength = -1
+			}
+
+			// Release the in-flight count once the upstream has answered; upgraded
+			// connections and gRPC streams stay in flight until they are closed
+			if res.StatusCode != http.StatusSwitchingProtocols && !isGRPCRequest(inReq) {
+				u.release()
 			}
 
 			return nil
 		}
 
-		httpcache.AttachTerminalAwareProxyOnError(cfg)
+		cfg.OnError = proxyOnError
+		httpcache.AttachTerminalAwareProxyOnError(&cfg.ProxyConfig)
+
+		// WebSocket upgrades are relayed by the gateway itself
+		if websocket.IsWebSocketUpgrade(ctx.Request) {
+			c.serveWebSocket(ctx, cfg, r, u)
+			return false, true, nil
+		}
 
 		return
 	}))
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+func TestCircuitBreakerEjectsFailingServer(t *testing.T) {
+	var failed, ok int32
+	bad := newCountingUpstream(t, http.StatusInternalServerError, &failed)
+	good := newCountingUpstream(t, http.StatusOK, &ok)
+
+	badServer, goodServer := testServer(t, bad), testServer(t, good)
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name: "breaker",
+				Path: "/breaker",
+				Backend: route.Backend{
+					Service: service.Service{
+						Protocol:  "http",
+						Algorithm: "round-robin",
+						Servers: []service.Server{
+							{Name: badServer.Name, Port: badServer.Port},
+							{Name: goodServer.Name, Port: goodServer.Port},
+						},
+						CircuitBreaker: service.CircuitBreaker{
+							Enable:              true,
+							ConsecutiveFailures: 2,
+							OpenDuration:        60,
+						},
+					},
+				},
+			},
+		},
+	})
+
+	for i := 0; i < 10; i++ {
+		res, err := http.Get(gw.URL + "/breaker")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	if hits := atomic.LoadInt32(&failed); hits != 2 {
+		t.Fatalf("expected the failing server to be ejected after 2 failures, got %d hits", hits)
+	}
+	if hits := atomic.LoadInt32(&ok); hits != 8 {
+		t.Fatalf("expected the healthy server to take the remaining traffic, got %d hits", hits)
+	}
+}
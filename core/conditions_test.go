@@ -0,0 +1,91 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+)
+
+func TestMatchConditions(t *testing.T) {
+	stable := newNamedUpstream(t, "stable")
+	canary := newNamedUpstream(t, "canary")
+	beta := newNamedUpstream(t, "beta")
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "conditions-canary",
+				Path:    "/api",
+				Backend: route.Backend{Service: testService(t, canary)},
+				Match: route.Match{
+					Headers: []route.MatchCondition{{Name: "X-Canary", Value: "true"}},
+				},
+			},
+			{
+				Name:    "conditions-beta",
+				Path:    "/api",
+				Backend: route.Backend{Service: testService(t, beta)},
+				Match: route.Match{
+					Methods: []string{http.MethodPost},
+					Cookies: []route.MatchCondition{{Name: "beta", Type: "present"}},
+				},
+			},
+			{
+				Name:    "conditions-stable",
+				Path:    "/api",
+				Backend: route.Backend{Service: testService(t, stable)},
+			},
+		},
+	})
+
+	do := func(method string, header http.Header) string {
+		req, _ := http.NewRequest(method, url+"/api/users", nil)
+		req.Header = header
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return string(body)
+	}
+
+	cases := []struct {
+		method string
+		header http.Header
+		want   string
+	}{
+		{http.MethodGet, http.Header{}, "stable"},
+		{http.MethodGet, http.Header{"X-Canary": {"true"}}, "canary"},
+		{http.MethodPost, http.Header{"Cookie": {"beta=1"}}, "beta"},
+		{http.MethodGet, http.Header{"Cookie": {"beta=1"}}, "stable"},
+	}
+	for _, c := range cases {
+		if got := do(c.method, c.header); got != c.want {
+			t.Errorf("%s %v: expected %s, got %q", c.method, c.header, c.want, got)
+		}
+	}
+}
+
+func TestMatchConditionsInvalid(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+
+	_, err := New("test", &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "conditions-invalid",
+				Path:    "/api",
+				Backend: route.Backend{Service: testService(t, upstream)},
+				Match: route.Match{
+					Headers: []route.MatchCondition{{Name: "X-Version", Type: "regex", Value: "("}},
+				},
+			},
+		},
+	})
+	if err == nil {
+		t.Error("expected an invalid regex to be rejected")
+	}
+}
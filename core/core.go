@@ -6,18 +6,31 @@ package core
 //   https://segmentfault.com/a/1190000039778241
 
 import (
+	"crypto/tls"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
+	"github.com/go-zoox/api-gateway/accesslog"
 	"github.com/go-zoox/api-gateway/config"
 	"github.com/go-zoox/api-gateway/core/loadbalancer"
+	"github.com/go-zoox/api-gateway/core/service"
 	"github.com/go-zoox/api-gateway/plugin"
+	"github.com/go-zoox/api-gateway/router"
+	"github.com/go-zoox/api-gateway/tracing"
 	"github.com/go-zoox/zoox"
 	"github.com/go-zoox/zoox/defaults"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 type Core interface {
 	Version() string
 	Run() error
+	// Reload validates cfg and swaps it in; requests in flight finish on the previous configuration.
+	Reload(cfg *config.Config) error
+	// SetConfigSource sets the configuration file and how it is loaded, for reloads on
+	// SIGHUP, file changes (reload.watch) and the admin API.
+	SetConfigSource(path string, load func() (*config.Config, error))
 }
 
 type core struct {
@@ -27,23 +40,89 @@ type core struct {
 	cfg     *config.Config
 
 	plugins []plugin.Plugin
-	
+
 	// Load balancer manager
 	lbManager *loadbalancer.Manager
+
+	// Upstream transports, shared by requests with the same settings
+	transports *transportPool
+
+	// Client TLS configs of the upstreams, read again by every generation
+	upstreamTLS *service.TLSConfigs
+
+	// Gateway-wide retry budget
+	retryBudget *retryBudget
+
+	// Open WebSocket connections per route
+	websockets *websocketCounter
+
+	// Mirrored requests in flight per route
+	mirrors *mirrorCounter
+
+	// HTTPS certificates and listener config, nil unless https is enabled
+	certificates *certificateStore
+	tlsConfig    *tls.Config
+	// ACME certificate manager, nil unless acme is enabled
+	acme *autocert.Manager
+
+	// Spans of the requests and upstream calls; records nothing unless tracing is enabled
+	tracer *tracing.Tracer
+	// Access log output, written for the routes that enable it
+	accessLog *accesslog.Logger
+
+	// Routes of the configuration, compiled by each generation
+	matcher *router.Matcher
+
+	// generation counts the configurations loaded, starting at 1
+	generation uint64
+	// requests being served by this generation
+	inflight atomic.Int64
+
+	// state shared by every generation
+	shared *shared
+}
+
+// shared is what the generations of the gateway have in common: the one serving new
+// requests, and how a new one is loaded.
+type shared struct {
+	current atomic.Pointer[core]
+	// reloads are serialized
+	reloadMu sync.Mutex
+
+	// configuration file and its loader, set by SetConfigSource
+	path string
+	load func() (*config.Config, error)
+
+	// plugins attached with Plugin()
+	plugins []plugin.Plugin
+
+	// draining is set once a graceful shutdown starts; the outer health check then fails
+	draining atomic.Bool
 }
 
 func New(version string, cfg *config.Config) (Core, error) {
+	upstreamTLS := service.NewTLSConfigs()
 	c := &core{
 		app: defaults.Default(),
 		//
 		version: version,
 		//
 		cfg: cfg,
+		//
+		transports:  newTransportPool(upstreamTLS),
+		upstreamTLS: upstreamTLS,
+		retryBudget: newRetryBudget(cfg.RetryBudget),
+		websockets:  newWebSocketCounter(),
+		mirrors:     newMirrorCounter(),
+		//
+		generation: 1,
+		shared:     &shared{},
 	}
 
 	if err := c.prepare(); err != nil {
 		return nil, fmt.Errorf("failed to prepare: %s", err)
 	}
+	c.shared.current.Store(c)
 
 	return c, nil
 }
@@ -51,3 +130,11 @@ func New(version string, cfg *config.Config) (Core, error) {
 func (c *core) Version() string {
 	return c.version
 }
+
+func (c *core) SetConfigSource(path string, load func() (*config.Config, error)) {
+	c.shared.reloadMu.Lock()
+	defer c.shared.reloadMu.Unlock()
+
+	c.shared.path = path
+	c.shared.load = load
+}
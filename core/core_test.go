@@ -0,0 +1,103 @@
+package core
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// newTestGateway builds the gateway for cfg and serves it on a local test server.
+func newTestGateway(t *testing.T, cfg *config.Config) *httptest.Server {
+	t.Helper()
+
+	c, err := New("test", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gw := c.(*core)
+	if err := gw.build(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		gw.lbManager.StopAllHealthChecks()
+	})
+
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = gw.newServer("")
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// serveTestGateway builds the gateway for cfg and serves it on a local listener, returning
+// the core and its server so tests can reload or shut it down.
+func serveTestGateway(t *testing.T, cfg *config.Config) (*core, *http.Server, string) {
+	t.Helper()
+
+	c, err := New("test", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := c.(*core)
+	if err := gw.build(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		gw.lbManager.StopAllHealthChecks()
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := gw.newServer(ln.Addr().String())
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+
+	return gw, server, "http://" + ln.Addr().String()
+}
+
+// newTestServer serves handler on a local test server, closed when the test ends.
+func newTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newNamedUpstream answers every request with its name.
+func newNamedUpstream(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+
+	return newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, name)
+	})
+}
+
+// testService returns a single-server service pointing at the test upstream.
+func testService(t *testing.T, upstream *httptest.Server) service.Service {
+	t.Helper()
+
+	host, port, err := net.SplitHostPort(upstream.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.ParseInt(port, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return service.Service{
+		Protocol: "http",
+		Name:     host,
+		Port:     p,
+	}
+}
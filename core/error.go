@@ -1,12 +1,42 @@
 package core
 
 import (
+	"context"
 	"errors"
+	"net"
+	"net/http"
 
+	"github.com/go-zoox/api-gateway/plugin/httpcache"
+	"github.com/go-zoox/api-gateway/requestid"
 	"github.com/go-zoox/api-gateway/router"
+	"github.com/go-zoox/proxy"
 )
 
 // ErrRouteNotFound is re-exported from router for stable errors.Is usage.
 var ErrRouteNotFound = router.ErrRouteNotFound
 var ErrServiceNotFound = errors.New("service not found")
 var ErrServiceUnavailable = errors.New("service unavailable")
+var ErrGatewayTimeout = errors.New("gateway timeout")
+var ErrTooManyWebSocketConnections = errors.New("too many websocket connections")
+
+// isTimeout reports whether err comes from an upstream timeout: connect, TLS handshake,
+// response header or the total request deadline.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// proxyOnError answers upstream timeouts with 504 Gateway Timeout and leaves every
+// other error to httpcache.DefaultProxyOnError.
+func proxyOnError(err error, rw http.ResponseWriter, req *http.Request) {
+	if isTimeout(err) {
+		requestid.Logger(req).Warnf("[proxy] upstream timeout: %s (%s %s)", err, req.Method, req.URL.String())
+		err = proxy.NewHTTPError(http.StatusGatewayTimeout, ErrGatewayTimeout.Error())
+	}
+
+	httpcache.DefaultProxyOnError(err, rw, req)
+}
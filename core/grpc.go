@@ -0,0 +1,167 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-zoox/zoox"
+	"google.golang.org/grpc/codes"
+)
+
+// maxGRPCMessageSize caps the grpc-message built from a gateway error body.
+const maxGRPCMessageSize = 1024
+
+// isGRPCRequest reports whether req is a gRPC call.
+func isGRPCRequest(req *http.Request) bool {
+	return req.ProtoMajor == 2 && isGRPCContentType(req.Header.Get("Content-Type"))
+}
+
+func isGRPCContentType(contentType string) bool {
+	return contentType == "application/grpc" || strings.HasPrefix(contentType, "application/grpc+") || strings.HasPrefix(contentType, "application/grpc;")
+}
+
+// grpcCode maps the HTTP status of a gateway-generated response to a gRPC status code.
+// It follows https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md,
+// except that rate limited calls are RESOURCE_EXHAUSTED and timeouts DEADLINE_EXCEEDED.
+func grpcCode(status int) codes.Code {
+	switch status {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// encodeGRPCMessage percent-encodes a grpc-message value as required by the gRPC
+// HTTP/2 protocol: every byte outside printable ASCII, and '%' itself.
+func encodeGRPCMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// grpcErrors answers gRPC calls that the gateway itself rejects (route not found, ip
+// policy, rate limit, unavailable or timed out upstreams, ...) with a gRPC status
+// instead of a plain HTTP error, which gRPC clients could only report as a protocol error.
+func grpcErrors(ctx *zoox.Context) {
+	if !isGRPCRequest(ctx.Request) {
+		ctx.Next()
+		return
+	}
+
+	w := &grpcErrorWriter{ResponseWriter: ctx.Writer}
+	ctx.Writer = w
+	defer func() {
+		ctx.Writer = w.ResponseWriter
+		w.finish()
+	}()
+
+	ctx.Next()
+}
+
+// grpcErrorWriter passes gRPC responses through untouched, and holds back any other
+// non-200 response so it can be rewritten into a trailers-only gRPC response.
+type grpcErrorWriter struct {
+	zoox.ResponseWriter
+
+	decided    bool
+	converting bool
+	message    bytes.Buffer
+}
+
+// decide inspects the response once its status and headers are final.
+func (w *grpcErrorWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	w.converting = w.Status() != http.StatusOK && !isGRPCContentType(w.Header().Get("Content-Type"))
+}
+
+func (w *grpcErrorWriter) Write(b []byte) (int, error) {
+	w.decide()
+	if !w.converting {
+		return w.ResponseWriter.Write(b)
+	}
+
+	if remaining := maxGRPCMessageSize - w.message.Len(); remaining > 0 {
+		if len(b) > remaining {
+			w.message.Write(b[:remaining])
+		} else {
+			w.message.Write(b)
+		}
+	}
+	return len(b), nil
+}
+
+func (w *grpcErrorWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *grpcErrorWriter) WriteHeaderNow() {
+	w.decide()
+	if !w.converting {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *grpcErrorWriter) Flush() {
+	w.decide()
+	if !w.converting {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// finish writes the held back error as a gRPC status, and makes sure responses without
+// a body end with their headers.
+func (w *grpcErrorWriter) finish() {
+	if w.Written() {
+		return
+	}
+	w.decide()
+	if !w.converting {
+		// zoox flushes responses without a body, which would send the headers of a
+		// trailers-only response (grpc-status in the headers) without ending the stream
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+
+	status := w.Status()
+	message := strings.TrimSpace(w.message.String())
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	h := w.Header()
+	for _, key := range []string{"Content-Length", "Content-Encoding", "Content-Type", "Trailer"} {
+		h.Del(key)
+	}
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Grpc-Status", strconv.Itoa(int(grpcCode(status))))
+	h.Set("Grpc-Message", encodeGRPCMessage(message))
+
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+	w.ResponseWriter.WriteHeaderNow()
+}
@@ -0,0 +1,273 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// newGRPCHealthUpstream serves the standard grpc.health.v1 service and returns a
+// service pointing at it.
+func newGRPCHealthUpstream(t *testing.T) (*health.Server, service.Service) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("echo", healthpb.HealthCheckResponse_SERVING)
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return hs, service.Service{
+		Protocol: "grpc",
+		Name:     "127.0.0.1",
+		Port:     int64(lis.Addr().(*net.TCPAddr).Port),
+	}
+}
+
+// dialGRPC connects a gRPC client to the gateway over h2c.
+func dialGRPC(t *testing.T, gw *httptest.Server) healthpb.HealthClient {
+	t.Helper()
+
+	conn, err := grpc.NewClient("passthrough:///"+strings.TrimPrefix(gw.URL, "http://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return healthpb.NewHealthClient(conn)
+}
+
+func grpcContext(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestGRPCProxy(t *testing.T) {
+	hs, svc := newGRPCHealthUpstream(t)
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "grpc",
+				Path:    "/grpc.health.v1.Health",
+				Backend: route.Backend{Service: svc},
+			},
+		},
+	})
+	client := dialGRPC(t, gw)
+	ctx := grpcContext(t)
+
+	res, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "echo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected SERVING, got %s", res.GetStatus())
+	}
+
+	// errors returned by the upstream travel in trailers
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected the upstream NOT_FOUND status, got %v", err)
+	}
+
+	// server streaming
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "echo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected SERVING, got %s", first.GetStatus())
+	}
+
+	hs.SetServingStatus("echo", healthpb.HealthCheckResponse_NOT_SERVING)
+	second, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("expected the streamed NOT_SERVING update, got %s", second.GetStatus())
+	}
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	_, svc := newGRPCHealthUpstream(t)
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "grpc",
+				Path:    "/grpc.health.v1.Health",
+				Backend: route.Backend{Service: svc},
+				RateLimit: route.RateLimit{
+					Enable:    true,
+					Algorithm: "fixed-window",
+					KeyType:   "ip",
+					Limit:     1,
+					Window:    60,
+				},
+			},
+		},
+	})
+	client := dialGRPC(t, gw)
+	ctx := grpcContext(t)
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected RESOURCE_EXHAUSTED, got %v", err)
+	}
+}
+
+func TestGRPCIPPolicy(t *testing.T) {
+	_, svc := newGRPCHealthUpstream(t)
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "grpc",
+				Path:    "/grpc.health.v1.Health",
+				Backend: route.Backend{Service: svc},
+				IPPolicy: route.IPPolicy{
+					Enable:  true,
+					Deny:    []string{"127.0.0.1/32"},
+					Message: "go away",
+				},
+			},
+		},
+	})
+	client := dialGRPC(t, gw)
+
+	_, err := client.Check(grpcContext(t), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PERMISSION_DENIED, got %v", err)
+	}
+	if msg := status.Convert(err).Message(); !strings.HasPrefix(msg, "go away (request_id: ") {
+		t.Fatalf("expected the ip policy message and the request id, got %q", msg)
+	}
+}
+
+func TestGRPCUnavailable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name: "grpc",
+				Path: "/grpc.health.v1.Health",
+				Backend: route.Backend{
+					Service: service.Service{Protocol: "grpc", Name: "127.0.0.1", Port: int64(port)},
+				},
+			},
+		},
+	})
+	client := dialGRPC(t, gw)
+
+	_, err = client.Check(grpcContext(t), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected UNAVAILABLE, got %v", err)
+	}
+}
+
+func TestH2CProxyTrailers(t *testing.T) {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		_, _ = io.WriteString(w, r.Proto)
+		w.Header().Set("X-Checksum", "42")
+	}))
+	upstream.Config.Protocols = protocols
+	upstream.Start()
+	t.Cleanup(upstream.Close)
+
+	svc := testService(t, upstream)
+	svc.Protocol = "h2c"
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "h2c",
+				Path:    "/",
+				Backend: route.Backend{Service: svc},
+			},
+		},
+	})
+
+	res, err := http.Get(gw.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "HTTP/2.0" {
+		t.Fatalf("expected the upstream to be reached over HTTP/2, got %q", body)
+	}
+	if res.Trailer.Get("X-Checksum") != "42" {
+		t.Fatalf("expected the upstream trailer, got %v", res.Trailer)
+	}
+}
+
+func TestGRPCCode(t *testing.T) {
+	cases := map[int]codes.Code{
+		http.StatusTooManyRequests:     codes.ResourceExhausted,
+		http.StatusForbidden:           codes.PermissionDenied,
+		http.StatusUnauthorized:        codes.Unauthenticated,
+		http.StatusNotFound:            codes.Unimplemented,
+		http.StatusServiceUnavailable:  codes.Unavailable,
+		http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+		http.StatusInternalServerError: codes.Unknown,
+	}
+	for httpStatus, code := range cases {
+		if got := grpcCode(httpStatus); got != code {
+			t.Errorf("%d: expected %s, got %s", httpStatus, code, got)
+		}
+	}
+
+	if got := encodeGRPCMessage("100% sure: ok ✓"); got != "100%25 sure: ok %E2%9C%93" {
+		t.Errorf("unexpected encoded message %q", got)
+	}
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+)
+
+func TestVirtualHosts(t *testing.T) {
+	api := newNamedUpstream(t, "api")
+	admin := newNamedUpstream(t, "admin")
+	site := newNamedUpstream(t, "site")
+	fallback := newNamedUpstream(t, "fallback")
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Backend: route.Backend{Service: testService(t, fallback)},
+		Hosts: []config.Host{
+			{Host: "*.example.com", Backend: route.Backend{Service: testService(t, site)}},
+		},
+		Routes: []route.Route{
+			{
+				Name:    "hosts-api",
+				Path:    "/v1",
+				Hosts:   []string{"api.example.com"},
+				Backend: route.Backend{Service: testService(t, api)},
+			},
+			{
+				Name:     "hosts-admin",
+				Path:     "/v1",
+				Hosts:    []string{"admin.example.com"},
+				Backend:  route.Backend{Service: testService(t, admin)},
+				IPPolicy: route.IPPolicy{Enable: true, Deny: []string{"127.0.0.1/32"}},
+			},
+		},
+	})
+
+	do := func(host, path string) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, url+path, nil)
+		req.Host = host
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	// twice, so cached matches are checked too
+	for i := 0; i < 2; i++ {
+		if status, body := do("api.example.com", "/v1/users"); status != http.StatusOK || body != "api" {
+			t.Errorf("expected api, got %d %q", status, body)
+		}
+		// the admin route has its own plugin settings
+		if status, _ := do("admin.example.com", "/v1/users"); status != http.StatusForbidden {
+			t.Errorf("expected the admin route to deny the request, got %d", status)
+		}
+		if status, body := do("www.example.com:8080", "/v1/users"); status != http.StatusOK || body != "site" {
+			t.Errorf("expected the host default backend, got %d %q", status, body)
+		}
+		if status, body := do("example.org", "/v1/users"); status != http.StatusOK || body != "fallback" {
+			t.Errorf("expected the default backend, got %d %q", status, body)
+		}
+	}
+}
+
+func TestVirtualHostsInvalid(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+
+	cases := map[string]*config.Config{
+		"duplicate host": {
+			Hosts: []config.Host{
+				{Host: "api.example.com", Backend: route.Backend{Service: testService(t, upstream)}},
+				{Host: "API.example.com", Backend: route.Backend{Service: testService(t, upstream)}},
+			},
+		},
+		"host without backend": {
+			Hosts: []config.Host{{Host: "api.example.com"}},
+		},
+		"invalid route host": {
+			Routes: []route.Route{
+				{
+					Name:    "hosts-invalid",
+					Path:    "/api",
+					Hosts:   []string{"api.*.com"},
+					Backend: route.Backend{Service: testService(t, upstream)},
+				},
+			},
+		},
+	}
+	for name, cfg := range cases {
+		if _, err := New("test", cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package loadbalancer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+	"github.com/go-zoox/logger"
+)
+
+// CircuitState is the state of a server's circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets traffic through and counts failures
+	CircuitClosed CircuitState = iota
+	// CircuitOpen takes the server out of load balancing
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of trial requests through
+	CircuitHalfOpen
+)
+
+// String returns the state name
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreakers tracks a circuit breaker for every server of every backend.
+// Breakers are configured by the backend's circuit_breaker block; backends without
+// it enabled are never broken.
+type CircuitBreakers struct {
+	breakers map[string]map[string]*circuitBreaker // backend ID -> server ID -> breaker
+	now      func() time.Time
+	mu       sync.Mutex
+}
+
+// circuitBreaker is the state of a single server's breaker
+type circuitBreaker struct {
+	state CircuitState
+	since time.Time
+
+	// generation changes on every transition, so outcomes of requests started
+	// before a transition are ignored
+	generation uint64
+
+	// closed: failures in a row and the rolling window
+	consecutive int64
+	buckets     []circuitBucket
+
+	// half-open: trial requests in flight and trial requests that succeeded
+	trials    int64
+	successes int64
+}
+
+type circuitBucket struct {
+	second   int64
+	requests int64
+	failures int64
+	slow     int64
+}
+
+// NewCircuitBreakers creates a new circuit breaker registry
+func NewCircuitBreakers() *CircuitBreakers {
+	return &CircuitBreakers{
+		breakers: make(map[string]map[string]*circuitBreaker),
+		now:      time.Now,
+	}
+}
+
+// Allow reports whether the server's breaker lets a request through
+func (cbs *CircuitBreakers) Allow(backend *route.NormalizedBackend, server *service.Server) bool {
+	cfg := backend.BaseConfig.CircuitBreaker
+	if !cfg.Enable {
+		return true
+	}
+
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	cb := cbs.get(backend, server)
+	cbs.refresh(cb, cfg, server)
+
+	switch cb.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		return cb.trials+cb.successes < cfg.EffectiveHalfOpenRequests()
+	default:
+		return true
+	}
+}
+
+// Begin marks the start of a request to the server. The returned call must be ended with
+// the outcome of the request once the upstream has answered, or aborted.
+func (cbs *CircuitBreakers) Begin(backend *route.NormalizedBackend, server *service.Server) *CircuitCall {
+	cfg := backend.BaseConfig.CircuitBreaker
+	if !cfg.Enable {
+		return &CircuitCall{}
+	}
+
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	cb := cbs.get(backend, server)
+	cbs.refresh(cb, cfg, server)
+
+	trial := cb.state == CircuitHalfOpen
+	if trial {
+		cb.trials++
+	}
+	generation := cb.generation
+
+	return &CircuitCall{
+		end: func(failed, aborted bool, elapsed time.Duration) {
+			cbs.record(backend, server, cfg, trial, generation, failed, aborted, elapsed)
+		},
+	}
+}
+
+// CircuitCall is a request to a server, reported to the server's breaker once it ends
+type CircuitCall struct {
+	once sync.Once
+	end  func(failed, aborted bool, elapsed time.Duration)
+}
+
+// Done reports the outcome of the request
+func (c *CircuitCall) Done(failed bool, elapsed time.Duration) {
+	c.finish(failed, false, elapsed)
+}
+
+// Abort reports a request the client gave up on: it gives back its half-open trial
+// without counting as a success or a failure
+func (c *CircuitCall) Abort() {
+	c.finish(false, true, 0)
+}
+
+func (c *CircuitCall) finish(failed, aborted bool, elapsed time.Duration) {
+	c.once.Do(func() {
+		if c.end != nil {
+			c.end(failed, aborted, elapsed)
+		}
+	})
+}
+
+// State returns the current state of the server's breaker
+func (cbs *CircuitBreakers) State(backend *route.NormalizedBackend, server *service.Server) CircuitState {
+	cfg := backend.BaseConfig.CircuitBreaker
+	if !cfg.Enable {
+		return CircuitClosed
+	}
+
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	cb := cbs.get(backend, server)
+	cbs.refresh(cb, cfg, server)
+	return cb.state
+}
+
+// States returns the breaker state of every server of the backend, keyed by server ID
+func (cbs *CircuitBreakers) States(backend *route.NormalizedBackend) map[string]CircuitState {
+	states := make(map[string]CircuitState, len(backend.Servers))
+	for _, server := range backend.Servers {
+		states[server.ID()] = cbs.State(backend, server)
+	}
+	return states
+}
+
+// Reset closes the server's breaker and clears its statistics
+func (cbs *CircuitBreakers) Reset(backend *route.NormalizedBackend, server *service.Server) {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	if servers := cbs.breakers[BackendID(backend)]; servers != nil {
+		delete(servers, server.ID())
+	}
+}
+
+func (cbs *CircuitBreakers) record(backend *route.NormalizedBackend, server *service.Server, cfg service.CircuitBreaker, trial bool, generation uint64, failed, aborted bool, elapsed time.Duration) {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	cb := cbs.get(backend, server)
+
+	// the breaker changed state since the request started; the outcome is stale
+	if cb.generation != generation {
+		return
+	}
+
+	// the client went away: the request says nothing about the server
+	if aborted {
+		if cb.state == CircuitHalfOpen && trial {
+			cb.trials--
+		}
+		return
+	}
+
+	switch cb.state {
+	case CircuitHalfOpen:
+		if !trial {
+			return
+		}
+		cb.trials--
+		if failed {
+			cbs.transition(cb, server, CircuitOpen, "trial request failed")
+			return
+		}
+		cb.successes++
+		if cb.successes >= cfg.EffectiveHalfOpenRequests() {
+			cbs.transition(cb, server, CircuitClosed, "trial requests succeeded")
+		}
+
+	case CircuitClosed:
+		window := cfg.EffectiveWindow()
+		if int64(len(cb.buckets)) != window {
+			cb.buckets = make([]circuitBucket, window)
+		}
+
+		now := cbs.now().Unix()
+		bucket := &cb.buckets[now%window]
+		if bucket.second != now {
+			*bucket = circuitBucket{second: now}
+		}
+		bucket.requests++
+
+		slow := elapsed >= cfg.SlowCallThreshold()
+		if slow {
+			bucket.slow++
+		}
+		if failed {
+			bucket.failures++
+			cb.consecutive++
+		} else {
+			cb.consecutive = 0
+		}
+
+		if threshold := cfg.EffectiveConsecutiveFailures(); threshold > 0 && cb.consecutive >= threshold {
+			cbs.transition(cb, server, CircuitOpen, "consecutive failures reached threshold")
+			return
+		}
+
+		var requests, failures, slowCalls int64
+		for _, b := range cb.buckets {
+			if b.second > now-window {
+				requests += b.requests
+				failures += b.failures
+				slowCalls += b.slow
+			}
+		}
+		if requests < cfg.EffectiveMinRequests() {
+			return
+		}
+
+		if cfg.ErrorRatio > 0 && float64(failures)/float64(requests) >= cfg.ErrorRatio {
+			cbs.transition(cb, server, CircuitOpen, "error ratio reached threshold")
+			return
+		}
+		if cfg.SlowCallRatio > 0 && float64(slowCalls)/float64(requests) >= cfg.SlowCallRatio {
+			cbs.transition(cb, server, CircuitOpen, "slow call ratio reached threshold")
+		}
+	}
+}
+
+// refresh moves an open breaker to half-open once the open duration has elapsed
+func (cbs *CircuitBreakers) refresh(cb *circuitBreaker, cfg service.CircuitBreaker, server *service.Server) {
+	if cb.state == CircuitOpen && cbs.now().Sub(cb.since) >= cfg.OpenTimeout() {
+		cbs.transition(cb, server, CircuitHalfOpen, "open duration elapsed")
+	}
+}
+
+func (cbs *CircuitBreakers) transition(cb *circuitBreaker, server *service.Server, state CircuitState, reason string) {
+	logger.Warnf("[circuitbreaker] server %s: %s -> %s (%s)", server.ID(), cb.state, state, reason)
+
+	cb.state = state
+	cb.since = cbs.now()
+	cb.generation++
+	cb.consecutive = 0
+	cb.trials = 0
+	cb.successes = 0
+	for i := range cb.buckets {
+		cb.buckets[i] = circuitBucket{}
+	}
+}
+
+func (cbs *CircuitBreakers) get(backend *route.NormalizedBackend, server *service.Server) *circuitBreaker {
+	backendID := BackendID(backend)
+	if cbs.breakers[backendID] == nil {
+		cbs.breakers[backendID] = make(map[string]*circuitBreaker)
+	}
+
+	cb, exists := cbs.breakers[backendID][server.ID()]
+	if !exists {
+		cb = &circuitBreaker{state: CircuitClosed}
+		cbs.breakers[backendID][server.ID()] = cb
+	}
+	return cb
+}
+
+// serverFilter selects the servers a load balancer may choose from. It is embedded by
+// every load balancer so they all skip disabled, unhealthy and circuit-broken servers.
+type serverFilter struct {
+	breakers *CircuitBreakers
+}
+
+// setCircuitBreakers wires the manager's circuit breakers into the load balancer
+func (f *serverFilter) setCircuitBreakers(breakers *CircuitBreakers) {
+	f.breakers = breakers
+}
+
+// available returns the servers of the backend that can receive traffic
+func (f *serverFilter) available(backend *route.NormalizedBackend) []*service.Server {
+	servers := make([]*service.Server, 0, len(backend.Servers))
+	for _, server := range backend.Servers {
+		if server.IsDisabled() || !server.IsHealthy() {
+			continue
+		}
+		if f.breakers != nil && !f.breakers.Allow(backend, server) {
+			continue
+		}
+		servers = append(servers, server)
+	}
+	return servers
+}
@@ -0,0 +1,241 @@
+package loadbalancer
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+func newBreakerBackend(cfg service.CircuitBreaker) *route.NormalizedBackend {
+	cfg.Enable = true
+	backend := &route.NormalizedBackend{
+		Algorithm: "round-robin",
+		Servers: []*service.Server{
+			{Name: "server1.com", Port: 8080},
+			{Name: "server2.com", Port: 8080},
+		},
+		BaseConfig: &service.Service{CircuitBreaker: cfg},
+	}
+
+	// 设置所有服务器为健康
+	for _, server := range backend.Servers {
+		server.SetHealthy(true)
+	}
+	return backend
+}
+
+func newTestBreakers(now *time.Time) *CircuitBreakers {
+	cbs := NewCircuitBreakers()
+	cbs.now = func() time.Time { return *now }
+	return cbs
+}
+
+func TestCircuitBreakerConsecutiveFailures(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cbs := newTestBreakers(&now)
+	backend := newBreakerBackend(service.CircuitBreaker{ConsecutiveFailures: 3, OpenDuration: 10})
+	server := backend.Servers[0]
+
+	for i := 0; i < 2; i++ {
+		cbs.Begin(backend, server).Done(true, time.Millisecond)
+	}
+	// 成功请求会重置连续失败计数
+	cbs.Begin(backend, server).Done(false, time.Millisecond)
+	for i := 0; i < 2; i++ {
+		cbs.Begin(backend, server).Done(true, time.Millisecond)
+	}
+	if state := cbs.State(backend, server); state != CircuitClosed {
+		t.Fatalf("连续失败未达到阈值时应该保持 closed, 实际: %s", state)
+	}
+
+	cbs.Begin(backend, server).Done(true, time.Millisecond)
+	if state := cbs.State(backend, server); state != CircuitOpen {
+		t.Fatalf("连续失败达到阈值时应该 open, 实际: %s", state)
+	}
+	if cbs.Allow(backend, server) {
+		t.Error("open 状态不应该放行请求")
+	}
+
+	// 另一台服务器不受影响
+	if state := cbs.State(backend, backend.Servers[1]); state != CircuitClosed {
+		t.Errorf("其他服务器应该保持 closed, 实际: %s", state)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cbs := newTestBreakers(&now)
+	backend := newBreakerBackend(service.CircuitBreaker{ConsecutiveFailures: 1, OpenDuration: 10, HalfOpenRequests: 2})
+	server := backend.Servers[0]
+
+	cbs.Begin(backend, server).Done(true, time.Millisecond)
+
+	now = now.Add(10 * time.Second)
+	if state := cbs.State(backend, server); state != CircuitHalfOpen {
+		t.Fatalf("open 时长结束后应该 half-open, 实际: %s", state)
+	}
+
+	// half-open 只放行有限的试探请求
+	trial1 := cbs.Begin(backend, server)
+	if !cbs.Allow(backend, server) {
+		t.Fatal("试探请求未用完时应该放行")
+	}
+	trial2 := cbs.Begin(backend, server)
+	if cbs.Allow(backend, server) {
+		t.Fatal("试探请求用完时不应该放行")
+	}
+
+	trial1.Done(false, time.Millisecond)
+	if state := cbs.State(backend, server); state != CircuitHalfOpen {
+		t.Fatalf("试探请求未全部成功时应该保持 half-open, 实际: %s", state)
+	}
+	trial2.Done(false, time.Millisecond)
+	if state := cbs.State(backend, server); state != CircuitClosed {
+		t.Fatalf("试探请求全部成功时应该 closed, 实际: %s", state)
+	}
+
+	// 再次失败后试探失败会重新 open
+	cbs.Begin(backend, server).Done(true, time.Millisecond)
+	now = now.Add(10 * time.Second)
+	cbs.Begin(backend, server).Done(true, time.Millisecond)
+	if state := cbs.State(backend, server); state != CircuitOpen {
+		t.Fatalf("试探请求失败时应该重新 open, 实际: %s", state)
+	}
+}
+
+func TestCircuitBreakerAbort(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cbs := newTestBreakers(&now)
+	backend := newBreakerBackend(service.CircuitBreaker{ConsecutiveFailures: 2, OpenDuration: 10, HalfOpenRequests: 1})
+	server := backend.Servers[0]
+
+	// 客户端中止的请求不计为成功，不会重置连续失败计数
+	cbs.Begin(backend, server).Done(true, time.Millisecond)
+	cbs.Begin(backend, server).Abort()
+	cbs.Begin(backend, server).Done(true, time.Millisecond)
+	if state := cbs.State(backend, server); state != CircuitOpen {
+		t.Fatalf("中止的请求不应该重置连续失败计数, 实际: %s", state)
+	}
+
+	// 中止的试探请求归还名额，但不会关闭熔断器
+	now = now.Add(10 * time.Second)
+	trial := cbs.Begin(backend, server)
+	if cbs.Allow(backend, server) {
+		t.Fatal("试探请求用完时不应该放行")
+	}
+	trial.Abort()
+	if state := cbs.State(backend, server); state != CircuitHalfOpen {
+		t.Fatalf("中止的试探请求不应该关闭熔断器, 实际: %s", state)
+	}
+	if !cbs.Allow(backend, server) {
+		t.Fatal("中止的试探请求应该归还名额")
+	}
+
+	// 结束后再报告结果不会生效
+	trial.Done(false, time.Millisecond)
+	if state := cbs.State(backend, server); state != CircuitHalfOpen {
+		t.Fatalf("已中止的请求不应该再报告结果, 实际: %s", state)
+	}
+}
+
+func TestCircuitBreakerErrorRatio(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cbs := newTestBreakers(&now)
+	backend := newBreakerBackend(service.CircuitBreaker{ErrorRatio: 0.5, MinRequests: 4, Window: 10})
+	server := backend.Servers[0]
+
+	// 交替成功失败，不会触发连续失败
+	for i := 0; i < 3; i++ {
+		cbs.Begin(backend, server).Done(i%2 == 0, time.Millisecond)
+	}
+	if state := cbs.State(backend, server); state != CircuitClosed {
+		t.Fatalf("请求数未达到 min_requests 时应该保持 closed, 实际: %s", state)
+	}
+
+	cbs.Begin(backend, server).Done(false, time.Millisecond)
+	if state := cbs.State(backend, server); state != CircuitOpen {
+		t.Fatalf("错误率达到阈值时应该 open, 实际: %s", state)
+	}
+}
+
+func TestCircuitBreakerErrorRatioWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cbs := newTestBreakers(&now)
+	backend := newBreakerBackend(service.CircuitBreaker{ErrorRatio: 0.5, MinRequests: 4, Window: 10})
+	server := backend.Servers[0]
+
+	cbs.Begin(backend, server).Done(true, time.Millisecond)
+	cbs.Begin(backend, server).Done(false, time.Millisecond)
+
+	// 旧的失败移出窗口
+	now = now.Add(10 * time.Second)
+	cbs.Begin(backend, server).Done(true, time.Millisecond)
+	cbs.Begin(backend, server).Done(false, time.Millisecond)
+	cbs.Begin(backend, server).Done(false, time.Millisecond)
+	cbs.Begin(backend, server).Done(false, time.Millisecond)
+
+	if state := cbs.State(backend, server); state != CircuitClosed {
+		t.Fatalf("窗口外的失败不应该计入错误率, 实际: %s", state)
+	}
+}
+
+func TestCircuitBreakerSlowCallRatio(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cbs := newTestBreakers(&now)
+	backend := newBreakerBackend(service.CircuitBreaker{SlowCallRatio: 0.5, SlowCallMs: 100, MinRequests: 2})
+	server := backend.Servers[0]
+
+	cbs.Begin(backend, server).Done(false, 200*time.Millisecond)
+	cbs.Begin(backend, server).Done(false, 10*time.Millisecond)
+
+	if state := cbs.State(backend, server); state != CircuitOpen {
+		t.Fatalf("慢调用率达到阈值时应该 open, 实际: %s", state)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	cbs := NewCircuitBreakers()
+	backend := newBreakerBackend(service.CircuitBreaker{})
+	backend.BaseConfig.CircuitBreaker.Enable = false
+	server := backend.Servers[0]
+
+	for i := 0; i < 10; i++ {
+		cbs.Begin(backend, server).Done(true, time.Millisecond)
+	}
+	if !cbs.Allow(backend, server) {
+		t.Error("未启用熔断时应该始终放行")
+	}
+}
+
+func TestManagerSkipsOpenServers(t *testing.T) {
+	manager := NewManager()
+	backend := newBreakerBackend(service.CircuitBreaker{ConsecutiveFailures: 1})
+
+	open := backend.Servers[0]
+	manager.GetCircuitBreakers().Begin(backend, open).Done(true, time.Millisecond)
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	req.RemoteAddr = "192.168.1.1:1234"
+
+	for _, algorithm := range []string{"round-robin", "weighted", "least-connections", "ip-hash"} {
+		lb := manager.GetLoadBalancer(algorithm)
+		for i := 0; i < 4; i++ {
+			selected, err := lb.Select(req, backend)
+			if err != nil {
+				t.Fatalf("[%s] Select 不应该返回错误: %v", algorithm, err)
+			}
+			if selected.ID() == open.ID() {
+				t.Fatalf("[%s] 不应该选择熔断的服务器", algorithm)
+			}
+		}
+	}
+
+	// 所有服务器都熔断时返回错误
+	manager.GetCircuitBreakers().Begin(backend, backend.Servers[1]).Done(true, time.Millisecond)
+	if _, err := manager.GetLoadBalancer("round-robin").Select(req, backend); err == nil {
+		t.Error("所有服务器熔断时应该返回错误")
+	}
+}
@@ -10,13 +10,16 @@ import (
 	"github.com/go-zoox/api-gateway/core/route"
 	"github.com/go-zoox/api-gateway/core/service"
 	"github.com/go-zoox/logger"
+	"google.golang.org/grpc"
 )
 
 // HealthCheckManager manages health checks for backend servers
 type HealthCheckManager struct {
 	checkers map[string]*healthChecker
-	client   *http.Client
-	mu       sync.RWMutex
+	// clients per upstream protocol and TLS settings
+	clients *healthCheckClients
+	passive *PassiveHealthChecks
+	mu      sync.RWMutex
 }
 
 // healthChecker represents a health check for a backend
@@ -26,15 +29,20 @@ type healthChecker struct {
 	cancel   context.CancelFunc
 	interval time.Duration
 	timeout  time.Duration
+	passive  *PassiveHealthChecks
+
+	clients *healthCheckClients
+
+	// grpc connections used by grpc health checks, keyed by server ID
+	conns   map[string]*grpc.ClientConn
+	connsMu sync.Mutex
 }
 
 // NewHealthCheckManager creates a new health check manager
 func NewHealthCheckManager() *HealthCheckManager {
 	return &HealthCheckManager{
 		checkers: make(map[string]*healthChecker),
-		client: &http.Client{
-			Timeout: 5 * time.Second,
-		},
+		clients:  newHealthCheckClients(5 * time.Second),
 	}
 }
 
@@ -58,7 +66,7 @@ func (hcm *HealthCheckManager) Start(backend *route.NormalizedBackend) error {
 		timeout = 5 * time.Second
 	}
 
-	backendID := getBackendID(backend)
+	backendID := BackendID(backend)
 
 	hcm.mu.Lock()
 	defer hcm.mu.Unlock()
@@ -75,12 +83,16 @@ func (hcm *HealthCheckManager) Start(backend *route.NormalizedBackend) error {
 		cancel:   cancel,
 		interval: interval,
 		timeout:  timeout,
+		passive:  hcm.passive,
+
+		clients: hcm.clients,
+		conns:   make(map[string]*grpc.ClientConn),
 	}
 
 	hcm.checkers[backendID] = checker
 
 	// Start health check goroutine
-	go checker.run(hcm.client)
+	go checker.run()
 
 	return nil
 }
@@ -101,6 +113,21 @@ func (hcm *HealthCheckManager) Stop(backendID string) error {
 	return nil
 }
 
+// Check checks the servers of a backend at once, without waiting for the next interval,
+// and returns when the checks are done. It reports false when the backend has no health check.
+func (hcm *HealthCheckManager) Check(backendID string) bool {
+	hcm.mu.RLock()
+	checker, exists := hcm.checkers[backendID]
+	hcm.mu.RUnlock()
+
+	if !exists {
+		return false
+	}
+
+	checker.checkAll()
+	return true
+}
+
 // StopAll stops all health checks
 func (hcm *HealthCheckManager) StopAll() error {
 	hcm.mu.Lock()
@@ -115,38 +142,111 @@ func (hcm *HealthCheckManager) StopAll() error {
 	return nil
 }
 
+// Sync makes backends the set of checked backends, e.g. after a configuration reload.
+// Checkers of backends no longer listed are stopped; listed backends are (re)started with
+// their new configuration, and their servers keep the health observed by the previous
+// checker until they are checked again.
+func (hcm *HealthCheckManager) Sync(backends []*route.NormalizedBackend) error {
+	listed := make(map[string]*route.NormalizedBackend, len(backends))
+	order := make([]string, 0, len(backends))
+	for _, backend := range backends {
+		backendID := BackendID(backend)
+		// like Start, the first backend with a set of servers is the one checked
+		if _, exists := listed[backendID]; exists {
+			continue
+		}
+		listed[backendID] = backend
+		order = append(order, backendID)
+	}
+
+	hcm.mu.RLock()
+	previous := make(map[string]*healthChecker, len(hcm.checkers))
+	for backendID, checker := range hcm.checkers {
+		previous[backendID] = checker
+	}
+	hcm.mu.RUnlock()
+
+	for backendID := range previous {
+		if _, exists := listed[backendID]; !exists {
+			if err := hcm.Stop(backendID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, backendID := range order {
+		backend := listed[backendID]
+		if checker, exists := previous[backendID]; exists {
+			if checker.backend == backend {
+				continue
+			}
+
+			copyHealth(checker.backend, backend)
+			if err := hcm.Stop(backendID); err != nil {
+				return err
+			}
+		}
+
+		if err := hcm.Start(backend); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyHealth carries the health of servers from one backend to the same servers of another.
+func copyHealth(from, to *route.NormalizedBackend) {
+	healthy := make(map[string]bool, len(from.Servers))
+	for _, server := range from.Servers {
+		healthy[server.ID()] = server.IsHealthy()
+	}
+	for _, server := range to.Servers {
+		if h, exists := healthy[server.ID()]; exists {
+			server.SetHealthy(h)
+		}
+	}
+}
+
 // run executes the health check loop
-func (hc *healthChecker) run(client *http.Client) {
+func (hc *healthChecker) run() {
 	ticker := time.NewTicker(hc.interval)
 	defer ticker.Stop()
+	defer hc.closeConns()
 
 	// Perform initial check immediately
-	hc.checkAll(client)
+	hc.checkAll()
 
 	for {
 		select {
 		case <-hc.ctx.Done():
 			return
 		case <-ticker.C:
-			hc.checkAll(client)
+			hc.checkAll()
 		}
 	}
 }
 
-// checkAll checks all servers in the backend
-func (hc *healthChecker) checkAll(client *http.Client) {
+// checkAll checks all servers in the backend in parallel, returning once every check is done
+func (hc *healthChecker) checkAll() {
+	var wg sync.WaitGroup
 	for _, server := range hc.backend.Servers {
-		if server.Disabled {
+		if server.IsDisabled() {
 			server.SetHealthy(false) // Disabled servers are considered unhealthy
 			continue
 		}
 
-		go hc.checkServer(server, client)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hc.checkServer(server)
+		}()
 	}
+	wg.Wait()
 }
 
 // checkServer checks the health of a single server
-func (hc *healthChecker) checkServer(server *service.Server, client *http.Client) {
+func (hc *healthChecker) checkServer(server *service.Server) {
 	// Get health check configuration (server-level or backend-level)
 	// Merge server override with base config to preserve base values when override has zero values
 	healthCheck := hc.backend.BaseConfig.HealthCheck
@@ -157,6 +257,12 @@ func (hc *healthChecker) checkServer(server *service.Server, client *http.Client
 		if override.Enable {
 			healthCheck.Enable = override.Enable
 		}
+		if override.Type != "" {
+			healthCheck.Type = override.Type
+		}
+		if override.GRPCService != "" {
+			healthCheck.GRPCService = override.GRPCService
+		}
 		if override.Method != "" {
 			healthCheck.Method = override.Method
 		}
@@ -179,19 +285,42 @@ func (hc *healthChecker) checkServer(server *service.Server, client *http.Client
 
 	// Skip if health check is disabled or always OK
 	if !healthCheck.Enable || healthCheck.Ok {
-		server.SetHealthy(true)
+		hc.setHealthy(server, true)
 		return
 	}
 
-	// Build health check URL
-	protocol := server.Protocol
-	if protocol == "" {
-		protocol = hc.backend.BaseConfig.Protocol
+	// Resolve the protocol and TLS settings (server-level or backend-level)
+	upstream := server.GetEffectiveConfig(hc.backend.BaseConfig)
+
+	checkType := healthCheck.Type
+	if checkType == "" {
+		checkType = service.HealthCheckTypeHTTP
+		if upstream.IsGRPC() {
+			checkType = service.HealthCheckTypeGRPC
+		}
 	}
-	if protocol == "" {
-		protocol = "http"
+
+	// Create request with timeout
+	ctx, cancel := context.WithTimeout(hc.ctx, hc.timeout)
+	defer cancel()
+
+	switch checkType {
+	case service.HealthCheckTypeGRPC:
+		hc.checkGRPC(ctx, server, upstream, healthCheck)
+	default:
+		client, err := hc.clients.Get(upstream)
+		if err != nil {
+			logger.Errorf("[healthcheck] failed to create client for %s: %v", server.ID(), err)
+			hc.setHealthy(server, false)
+			return
+		}
+		hc.checkHTTP(ctx, server, upstream, healthCheck, client)
 	}
+}
 
+// checkHTTP checks a server with an HTTP request, expecting one of the configured statuses
+func (hc *healthChecker) checkHTTP(ctx context.Context, server *service.Server, upstream *service.Service, healthCheck service.HealthCheck, client *http.Client) {
+	// Build health check URL
 	path := healthCheck.Path
 	if path == "" {
 		path = "/health"
@@ -202,16 +331,12 @@ func (hc *healthChecker) checkServer(server *service.Server, client *http.Client
 		method = "GET"
 	}
 
-	url := fmt.Sprintf("%s://%s%s", protocol, server.Host(), path)
-
-	// Create request with timeout
-	ctx, cancel := context.WithTimeout(hc.ctx, hc.timeout)
-	defer cancel()
+	url := fmt.Sprintf("%s://%s%s", upstream.Scheme(), server.Host(), path)
 
 	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		logger.Errorf("[healthcheck] failed to create request for %s: %v", server.ID(), err)
-		server.SetHealthy(false)
+		hc.setHealthy(server, false)
 		return
 	}
 
@@ -219,7 +344,7 @@ func (hc *healthChecker) checkServer(server *service.Server, client *http.Client
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Debugf("[healthcheck] server %s is unhealthy: %v", server.ID(), err)
-		server.SetHealthy(false)
+		hc.setHealthy(server, false)
 		return
 	}
 	defer resp.Body.Close()
@@ -239,5 +364,16 @@ func (hc *healthChecker) checkServer(server *service.Server, client *http.Client
 		logger.Debugf("[healthcheck] server %s is unhealthy (status: %d, expected: %v)", server.ID(), resp.StatusCode, healthCheck.Status)
 	}
 
+	hc.setHealthy(server, healthy)
+}
+
+// setHealthy records an active check result; servers ejected by passive checks
+// stay unhealthy until their ejection ends, then get the last result back
+func (hc *healthChecker) setHealthy(server *service.Server, healthy bool) {
+	if hc.passive != nil && hc.passive.Checked(hc.backend, server, healthy) {
+		logger.Debugf("[healthcheck] server %s is ejected by passive health check, the result applies once the ejection ends", server.ID())
+		return
+	}
+
 	server.SetHealthy(healthy)
 }
@@ -0,0 +1,97 @@
+package loadbalancer
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// healthCheckClients shares the HTTP clients of health checks between upstreams with
+// the same protocol and TLS settings. Clients built with the TLS configs of a previous
+// configuration generation are rebuilt.
+type healthCheckClients struct {
+	timeout time.Duration
+
+	clients    map[healthCheckClientKey]*healthCheckClient
+	tlsConfigs *service.TLSConfigs
+	mu         sync.Mutex
+}
+
+// healthCheckClient is a client and the TLS config it was built with
+type healthCheckClient struct {
+	client    *http.Client
+	tlsConfig *tls.Config
+}
+
+type healthCheckClientKey struct {
+	// http2 checks h2c and grpcs upstreams over HTTP/2
+	http2 bool
+	tls   service.TLS
+}
+
+func newHealthCheckClients(timeout time.Duration) *healthCheckClients {
+	return &healthCheckClients{
+		timeout:    timeout,
+		clients:    make(map[healthCheckClientKey]*healthCheckClient),
+		tlsConfigs: service.NewTLSConfigs(),
+	}
+}
+
+// use switches the clients to the TLS configs of a new generation
+func (p *healthCheckClients) use(tlsConfigs *service.TLSConfigs) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.tlsConfigs = tlsConfigs
+}
+
+// Get returns the client for HTTP health checks against the upstream
+func (p *healthCheckClients) Get(upstream *service.Service) (*http.Client, error) {
+	key := healthCheckClientKey{
+		http2: upstream.IsHTTP2(),
+		tls:   upstream.TLS,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tlsConfig, err := p.tlsConfigs.Get(key.tls)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached, ok := p.clients[key]; ok {
+		if cached.tlsConfig == tlsConfig {
+			return cached.client, nil
+		}
+		cached.client.CloseIdleConnections()
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+	if key.http2 {
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+		transport.Protocols.SetHTTP2(true)
+	}
+
+	client := &http.Client{
+		Timeout:   p.timeout,
+		Transport: transport,
+	}
+	p.clients[key] = &healthCheckClient{client: client, tlsConfig: tlsConfig}
+	return client, nil
+}
+
+// TLSConfig returns the client TLS config of the upstream, nil when none is configured
+func (p *healthCheckClients) TLSConfig(settings service.TLS) (*tls.Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.tlsConfigs.Get(settings)
+}
@@ -0,0 +1,146 @@
+package loadbalancer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// writeClientCertificate 生成自签名的客户端证书，返回证书和私钥文件路径
+func writeClientCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "healthcheck"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestHealthCheckMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeClientCertificate(t, dir)
+
+	clientCA, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(clientCA)
+
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	upstream.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	upstream.StartTLS()
+	defer upstream.Close()
+
+	ca := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: upstream.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	port := int64(upstream.Listener.Addr().(*net.TCPAddr).Port)
+	withCert := &service.Server{
+		Name: "127.0.0.1",
+		Port: port,
+		// 服务器级别覆盖客户端证书
+		TLS: &service.TLS{Cert: certFile, Key: keyFile},
+	}
+	withoutCert := &service.Server{Name: "localhost", Port: port}
+	withoutCert.SetHealthy(true)
+
+	backend := &route.NormalizedBackend{
+		Algorithm: "round-robin",
+		Servers:   []*service.Server{withCert, withoutCert},
+		BaseConfig: &service.Service{
+			Protocol: "https",
+			TLS:      service.TLS{CA: ca, SNI: "example.com"},
+			HealthCheck: service.HealthCheck{
+				Enable:   true,
+				Path:     "/health",
+				Status:   []int64{200},
+				Interval: 1,
+			},
+		},
+	}
+
+	hcm := NewHealthCheckManager()
+	defer hcm.StopAll()
+	if err := hcm.Start(backend); err != nil {
+		t.Fatal(err)
+	}
+
+	waitHealthy(t, withCert, true)
+	// 没有客户端证书的服务器握手失败，应该被标记为不健康
+	waitHealthy(t, withoutCert, false)
+}
+
+func TestHealthCheckClientsReload(t *testing.T) {
+	certFile, keyFile := writeClientCertificate(t, t.TempDir())
+	upstream := &service.Service{
+		Protocol: "https",
+		TLS:      service.TLS{Cert: certFile, Key: keyFile},
+	}
+
+	clients := newHealthCheckClients(time.Second)
+	client, err := clients.Get(upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := clients.Get(upstream); again != client {
+		t.Fatal("相同的 TLS 配置应该复用客户端")
+	}
+
+	// 新的配置代重新读取证书，客户端也随之重建
+	clients.use(service.NewTLSConfigs())
+	if again, _ := clients.Get(upstream); again == client {
+		t.Fatal("重新加载配置后应该重建客户端")
+	}
+}
@@ -0,0 +1,93 @@
+package loadbalancer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+
+	"github.com/go-zoox/api-gateway/core/service"
+	"github.com/go-zoox/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// errHealthCheckStopped is returned when a check outlives its health checker
+var errHealthCheckStopped = errors.New("health check stopped")
+
+// checkGRPC checks a server with the standard grpc.health.v1 Health/Check call
+func (hc *healthChecker) checkGRPC(ctx context.Context, server *service.Server, upstream *service.Service, healthCheck service.HealthCheck) {
+	conn, err := hc.conn(server, upstream)
+	if err != nil {
+		logger.Errorf("[healthcheck] failed to create grpc client for %s: %v", server.ID(), err)
+		hc.setHealthy(server, false)
+		return
+	}
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: healthCheck.GRPCService,
+	})
+	if err != nil {
+		logger.Debugf("[healthcheck] server %s is unhealthy: %v", server.ID(), err)
+		hc.setHealthy(server, false)
+		return
+	}
+
+	healthy := resp.GetStatus() == healthpb.HealthCheckResponse_SERVING
+	if healthy {
+		logger.Debugf("[healthcheck] server %s is healthy (grpc status: %s)", server.ID(), resp.GetStatus())
+	} else {
+		logger.Debugf("[healthcheck] server %s is unhealthy (grpc status: %s)", server.ID(), resp.GetStatus())
+	}
+
+	hc.setHealthy(server, healthy)
+}
+
+// conn returns the grpc connection to the server, reusing it across checks
+func (hc *healthChecker) conn(server *service.Server, upstream *service.Service) (*grpc.ClientConn, error) {
+	hc.connsMu.Lock()
+	defer hc.connsMu.Unlock()
+
+	if hc.conns == nil {
+		return nil, errHealthCheckStopped
+	}
+	if conn, ok := hc.conns[server.ID()]; ok {
+		return conn, nil
+	}
+
+	creds := insecure.NewCredentials()
+	if upstream.Scheme() == "https" {
+		tlsConfig, err := hc.clients.TLSConfig(upstream.TLS)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		creds = credentials.NewTLS(tlsConfig.Clone())
+	}
+
+	// passthrough: the server host is dialed as is, like the HTTP health checks do
+	conn, err := grpc.NewClient(
+		"passthrough:///"+server.Host(),
+		grpc.WithTransportCredentials(creds),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hc.conns[server.ID()] = conn
+	return conn, nil
+}
+
+// closeConns closes the grpc connections once the health checker stops
+func (hc *healthChecker) closeConns() {
+	hc.connsMu.Lock()
+	defer hc.connsMu.Unlock()
+
+	for _, conn := range hc.conns {
+		conn.Close()
+	}
+	hc.conns = nil
+}
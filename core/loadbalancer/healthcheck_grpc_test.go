@@ -0,0 +1,102 @@
+package loadbalancer
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func newGRPCHealthServer(t *testing.T) (*health.Server, *service.Server) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := health.NewServer()
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return hs, &service.Server{Name: "127.0.0.1", Port: int64(lis.Addr().(*net.TCPAddr).Port)}
+}
+
+func waitHealthy(t *testing.T, server *service.Server, healthy bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for server.IsHealthy() != healthy {
+		if time.Now().After(deadline) {
+			t.Fatalf("服务器 %s 的健康状态应该变为 %v", server.ID(), healthy)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	hs, server := newGRPCHealthServer(t)
+	hs.SetServingStatus("echo", healthpb.HealthCheckResponse_SERVING)
+
+	backend := &route.NormalizedBackend{
+		Algorithm: "round-robin",
+		Servers:   []*service.Server{server},
+		BaseConfig: &service.Service{
+			// grpc 协议默认使用 grpc.health.v1 健康检查
+			Protocol: "grpc",
+			HealthCheck: service.HealthCheck{
+				Enable:      true,
+				GRPCService: "echo",
+				Interval:    1,
+			},
+		},
+	}
+
+	hcm := NewHealthCheckManager()
+	defer hcm.StopAll()
+	if err := hcm.Start(backend); err != nil {
+		t.Fatal(err)
+	}
+
+	waitHealthy(t, server, true)
+
+	hs.SetServingStatus("echo", healthpb.HealthCheckResponse_NOT_SERVING)
+	waitHealthy(t, server, false)
+
+	hs.SetServingStatus("echo", healthpb.HealthCheckResponse_SERVING)
+	waitHealthy(t, server, true)
+}
+
+func TestGRPCHealthCheckUnknownService(t *testing.T) {
+	_, server := newGRPCHealthServer(t)
+	server.SetHealthy(true)
+
+	backend := &route.NormalizedBackend{
+		Algorithm: "round-robin",
+		Servers:   []*service.Server{server},
+		BaseConfig: &service.Service{
+			Protocol: "h2c",
+			HealthCheck: service.HealthCheck{
+				Enable:      true,
+				Type:        "grpc",
+				GRPCService: "unknown",
+			},
+		},
+	}
+
+	hcm := NewHealthCheckManager()
+	defer hcm.StopAll()
+	if err := hcm.Start(backend); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未注册的服务返回 NOT_FOUND，应该被标记为不健康
+	waitHealthy(t, server, false)
+}
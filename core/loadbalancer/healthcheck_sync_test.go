@@ -0,0 +1,77 @@
+package loadbalancer
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+func newSyncBackend(port int64, path string) *route.NormalizedBackend {
+	server := &service.Server{Name: "127.0.0.1", Port: port}
+	server.SetHealthy(true)
+
+	return &route.NormalizedBackend{
+		Algorithm: "round-robin",
+		Servers:   []*service.Server{server},
+		BaseConfig: &service.Service{
+			Protocol: "http",
+			HealthCheck: service.HealthCheck{
+				Enable:   true,
+				Path:     path,
+				Status:   []int64{200},
+				Interval: 60,
+			},
+		},
+	}
+}
+
+func TestHealthCheckSync(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+	port := int64(upstream.Listener.Addr().(*net.TCPAddr).Port)
+
+	unhealthy := newSyncBackend(port, "/down")
+	removed := newSyncBackend(port, "/up")
+	removed.Servers[0].Name = "localhost"
+
+	hcm := NewHealthCheckManager()
+	defer hcm.StopAll()
+	if err := hcm.Sync([]*route.NormalizedBackend{unhealthy, removed}); err != nil {
+		t.Fatal(err)
+	}
+	waitHealthy(t, unhealthy.Servers[0], false)
+
+	// 重新加载配置：同一组服务器的新后端，另一个后端被移除
+	reloaded := newSyncBackend(port, "/down")
+	if err := hcm.Sync([]*route.NormalizedBackend{reloaded}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新的服务器对象沿用之前的健康状态
+	if reloaded.Servers[0].IsHealthy() {
+		t.Fatal("重新加载后的服务器应该保持不健康状态")
+	}
+
+	hcm.mu.RLock()
+	defer hcm.mu.RUnlock()
+	if len(hcm.checkers) != 1 {
+		t.Fatalf("被移除后端的健康检查应该停止，剩余 %d 个", len(hcm.checkers))
+	}
+	checker := hcm.checkers[BackendID(reloaded)]
+	if checker == nil || checker.backend != reloaded {
+		t.Fatal("健康检查应该使用新的后端配置")
+	}
+	if checker.ctx.Err() != nil {
+		t.Fatal("新的健康检查不应该被停止")
+	}
+}
@@ -0,0 +1,53 @@
+package loadbalancer
+
+import (
+	"sync"
+
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// InFlight counts the requests in flight to each server, whatever the load balancing
+// algorithm of its backend
+type InFlight struct {
+	counts map[string]map[string]int64 // backend ID -> server ID -> requests in flight
+	mu     sync.RWMutex
+}
+
+// NewInFlight creates a new in-flight request counter
+func NewInFlight() *InFlight {
+	return &InFlight{
+		counts: make(map[string]map[string]int64),
+	}
+}
+
+// Start counts a request sent to the server
+func (f *InFlight) Start(backend *route.NormalizedBackend, server *service.Server) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	backendID := BackendID(backend)
+	if f.counts[backendID] == nil {
+		f.counts[backendID] = make(map[string]int64)
+	}
+	f.counts[backendID][server.ID()]++
+}
+
+// End stops counting a request sent to the server
+func (f *InFlight) End(backend *route.NormalizedBackend, server *service.Server) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	backendID := BackendID(backend)
+	if f.counts[backendID][server.ID()] > 0 {
+		f.counts[backendID][server.ID()]--
+	}
+}
+
+// Count returns the number of requests in flight to the server
+func (f *InFlight) Count(backend *route.NormalizedBackend, server *service.Server) int64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.counts[BackendID(backend)][server.ID()]
+}
@@ -13,7 +13,9 @@ import (
 )
 
 // IPHash implements IP hash load balancing
-type IPHash struct{}
+type IPHash struct {
+	serverFilter
+}
 
 // NewIPHash creates a new IP hash load balancer
 func NewIPHash() *IPHash {
@@ -22,13 +24,8 @@ func NewIPHash() *IPHash {
 
 // Select chooses a server based on client IP hash
 func (ih *IPHash) Select(req *http.Request, backend *route.NormalizedBackend) (*service.Server, error) {
-		// Filter healthy and enabled servers
-		healthyServers := make([]*service.Server, 0)
-		for _, server := range backend.Servers {
-			if !server.Disabled && server.IsHealthy() {
-				healthyServers = append(healthyServers, server)
-			}
-		}
+	// Filter healthy, enabled and circuit-closed servers
+	healthyServers := ih.available(backend)
 
 	if len(healthyServers) == 0 {
 		return nil, fmt.Errorf("no healthy servers available")
@@ -11,6 +11,8 @@ import (
 
 // LeastConnections implements least-connections load balancing
 type LeastConnections struct {
+	serverFilter
+
 	connections map[string]map[string]int64 // backend ID -> server ID -> connection count
 	mu          sync.RWMutex
 }
@@ -24,13 +26,8 @@ func NewLeastConnections() *LeastConnections {
 
 // Select chooses a server with the least number of active connections
 func (lc *LeastConnections) Select(req *http.Request, backend *route.NormalizedBackend) (*service.Server, error) {
-	// Filter healthy and enabled servers
-	healthyServers := make([]*service.Server, 0)
-	for _, server := range backend.Servers {
-		if !server.Disabled && server.IsHealthy() {
-			healthyServers = append(healthyServers, server)
-		}
-	}
+	// Filter healthy, enabled and circuit-closed servers
+	healthyServers := lc.available(backend)
 
 	if len(healthyServers) == 0 {
 		return nil, fmt.Errorf("no healthy servers available")
@@ -39,7 +36,7 @@ func (lc *LeastConnections) Select(req *http.Request, backend *route.NormalizedB
 	lc.mu.Lock()
 	defer lc.mu.Unlock()
 
-	backendID := getBackendID(backend)
+	backendID := BackendID(backend)
 
 	// Initialize connection counts if needed
 	if lc.connections[backendID] == nil {
@@ -79,7 +76,7 @@ func (lc *LeastConnections) OnRequestStart(backend *route.NormalizedBackend, ser
 	lc.mu.Lock()
 	defer lc.mu.Unlock()
 
-	backendID := getBackendID(backend)
+	backendID := BackendID(backend)
 	if lc.connections[backendID] == nil {
 		lc.connections[backendID] = make(map[string]int64)
 	}
@@ -91,7 +88,7 @@ func (lc *LeastConnections) OnRequestEnd(backend *route.NormalizedBackend, serve
 	lc.mu.Lock()
 	defer lc.mu.Unlock()
 
-	backendID := getBackendID(backend)
+	backendID := BackendID(backend)
 	if lc.connections[backendID] != nil {
 		if lc.connections[backendID][server.ID()] > 0 {
 			lc.connections[backendID][server.ID()]--
@@ -23,18 +23,29 @@ type LoadBalancer interface {
 	OnRequestEnd(backend *route.NormalizedBackend, server *service.Server)
 }
 
-// Manager manages load balancers and health checks
+// Manager manages load balancers, health checks and circuit breakers
 type Manager struct {
 	loadbalancers map[string]LoadBalancer
 	healthManager *HealthCheckManager
+	passiveHealth *PassiveHealthChecks
+	breakers      *CircuitBreakers
+	inflight      *InFlight
 	mu            sync.RWMutex
 }
 
 // NewManager creates a new load balancer manager
 func NewManager() *Manager {
+	passiveHealth := NewPassiveHealthChecks()
+
+	healthManager := NewHealthCheckManager()
+	healthManager.passive = passiveHealth
+
 	return &Manager{
 		loadbalancers: make(map[string]LoadBalancer),
-		healthManager: NewHealthCheckManager(),
+		healthManager: healthManager,
+		passiveHealth: passiveHealth,
+		breakers:      NewCircuitBreakers(),
+		inflight:      NewInFlight(),
 	}
 }
 
@@ -71,6 +82,11 @@ func (m *Manager) GetLoadBalancer(algorithm string) LoadBalancer {
 		lb = NewRoundRobin()
 	}
 
+	// Skip servers with an open circuit
+	if aware, ok := lb.(interface{ setCircuitBreakers(*CircuitBreakers) }); ok {
+		aware.setCircuitBreakers(m.breakers)
+	}
+
 	m.loadbalancers[algorithm] = lb
 	return lb
 }
@@ -85,8 +101,22 @@ func (m *Manager) StopHealthCheck(backendID string) error {
 	return m.healthManager.Stop(backendID)
 }
 
-// StopAllHealthChecks stops all health checks
+// SyncHealthChecks checks exactly the given backends, stopping the checks of the others,
+// with the upstream TLS configs of their generation
+func (m *Manager) SyncHealthChecks(backends []*route.NormalizedBackend, tlsConfigs *service.TLSConfigs) error {
+	m.healthManager.clients.use(tlsConfigs)
+	return m.healthManager.Sync(backends)
+}
+
+// CheckHealth checks the servers of a backend at once; it reports false when the
+// backend has no health check
+func (m *Manager) CheckHealth(backendID string) bool {
+	return m.healthManager.Check(backendID)
+}
+
+// StopAllHealthChecks stops all health checks, including pending passive ejections
 func (m *Manager) StopAllHealthChecks() error {
+	m.passiveHealth.StopAll()
 	return m.healthManager.StopAll()
 }
 
@@ -94,3 +124,18 @@ func (m *Manager) StopAllHealthChecks() error {
 func (m *Manager) GetHealthCheckManager() *HealthCheckManager {
 	return m.healthManager
 }
+
+// GetPassiveHealthChecks returns the passive health checks (outlier detection)
+func (m *Manager) GetPassiveHealthChecks() *PassiveHealthChecks {
+	return m.passiveHealth
+}
+
+// GetCircuitBreakers returns the circuit breakers of the backend servers
+func (m *Manager) GetCircuitBreakers() *CircuitBreakers {
+	return m.breakers
+}
+
+// GetInFlight returns the counts of requests in flight to the backend servers
+func (m *Manager) GetInFlight() *InFlight {
+	return m.inflight
+}
@@ -0,0 +1,211 @@
+package loadbalancer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+	"github.com/go-zoox/logger"
+)
+
+// PassiveHealthChecks ejects servers that fail live traffic (outlier detection).
+// It works with or without active health checks: an ejected server is marked
+// unhealthy until its ejection ends, and active checks do not bring it back early.
+// When the ejection ends, the server gets back the result of the last active check.
+type PassiveHealthChecks struct {
+	outliers map[string]map[string]*outlier // backend ID -> server ID -> state
+	mu       sync.Mutex
+}
+
+// outlier is the passive health state of a single server
+type outlier struct {
+	server *service.Server
+
+	// failures in a row
+	consecutive int64
+
+	// ejections counts ejections since the last completed probation
+	ejections int64
+	ejected   bool
+	until     time.Time
+	timer     *time.Timer
+
+	// probation: the server is back after an ejection and must prove itself
+	probation bool
+	successes int64
+
+	// the result of the last active health check, restored when the ejection ends
+	checked       bool
+	activeHealthy bool
+}
+
+// NewPassiveHealthChecks creates a new passive health check registry
+func NewPassiveHealthChecks() *PassiveHealthChecks {
+	return &PassiveHealthChecks{
+		outliers: make(map[string]map[string]*outlier),
+	}
+}
+
+// Record reports the outcome of a request proxied to the server
+func (phc *PassiveHealthChecks) Record(backend *route.NormalizedBackend, server *service.Server, failed bool) {
+	cfg := backend.BaseConfig.HealthCheck.Passive
+	if !cfg.Enable {
+		return
+	}
+
+	phc.mu.Lock()
+	defer phc.mu.Unlock()
+
+	o := phc.get(backend, server)
+	if o.ejected {
+		// in-flight requests finishing after the ejection
+		return
+	}
+
+	if !failed {
+		o.consecutive = 0
+		if o.probation {
+			o.successes++
+			if o.successes >= cfg.EffectiveProbationSuccesses() {
+				logger.Infof("[healthcheck][passive] server %s passed probation", server.ID())
+				o.probation = false
+				o.successes = 0
+				o.ejections = 0
+			}
+		}
+		return
+	}
+
+	o.consecutive++
+	if !o.probation && o.consecutive < cfg.EffectiveConsecutiveFailures() {
+		return
+	}
+
+	// keep enough servers in the pool
+	backendID := BackendID(backend)
+	ejected := 0
+	for _, other := range phc.outliers[backendID] {
+		if other.ejected {
+			ejected++
+		}
+	}
+	if int64(ejected+1)*100 > int64(len(backend.Servers))*cfg.EffectiveMaxEjectionPercent() {
+		logger.Warnf("[healthcheck][passive] server %s failed %d request(s) in a row, but max_ejection_percent is reached", server.ID(), o.consecutive)
+		return
+	}
+
+	phc.eject(o, cfg)
+}
+
+// Checked records the result of an active health check of the server and reports
+// whether the server is ejected, in which case the result is applied once the ejection ends
+func (phc *PassiveHealthChecks) Checked(backend *route.NormalizedBackend, server *service.Server, healthy bool) bool {
+	if !backend.BaseConfig.HealthCheck.Passive.Enable {
+		return false
+	}
+
+	phc.mu.Lock()
+	defer phc.mu.Unlock()
+
+	o := phc.get(backend, server)
+	o.checked = true
+	o.activeHealthy = healthy
+	return o.ejected
+}
+
+// Ejected reports whether the server is currently ejected
+func (phc *PassiveHealthChecks) Ejected(backend *route.NormalizedBackend, server *service.Server) bool {
+	phc.mu.Lock()
+	defer phc.mu.Unlock()
+
+	servers := phc.outliers[BackendID(backend)]
+	if servers == nil {
+		return false
+	}
+	o, exists := servers[server.ID()]
+	return exists && o.ejected
+}
+
+// EjectedUntil returns when the server's ejection ends, or the zero time if it is not ejected
+func (phc *PassiveHealthChecks) EjectedUntil(backend *route.NormalizedBackend, server *service.Server) time.Time {
+	phc.mu.Lock()
+	defer phc.mu.Unlock()
+
+	servers := phc.outliers[BackendID(backend)]
+	if servers == nil {
+		return time.Time{}
+	}
+	if o, exists := servers[server.ID()]; exists && o.ejected {
+		return o.until
+	}
+	return time.Time{}
+}
+
+// StopAll cancels pending ejections and forgets all passive health state
+func (phc *PassiveHealthChecks) StopAll() {
+	phc.mu.Lock()
+	defer phc.mu.Unlock()
+
+	for _, servers := range phc.outliers {
+		for _, o := range servers {
+			if o.timer != nil {
+				o.timer.Stop()
+			}
+		}
+	}
+
+	phc.outliers = make(map[string]map[string]*outlier)
+}
+
+func (phc *PassiveHealthChecks) eject(o *outlier, cfg service.PassiveHealthCheck) {
+	o.ejections++
+	duration := cfg.Ejection(o.ejections)
+
+	logger.Warnf("[healthcheck][passive] server %s ejected for %s after %d failure(s) in a row (ejection #%d)", o.server.ID(), duration, o.consecutive, o.ejections)
+
+	o.ejected = true
+	o.until = time.Now().Add(duration)
+	o.consecutive = 0
+	o.probation = false
+	o.successes = 0
+	o.server.SetHealthy(false)
+
+	o.timer = time.AfterFunc(duration, func() {
+		phc.restore(o)
+	})
+}
+
+// restore brings the server back on probation once its ejection ends
+func (phc *PassiveHealthChecks) restore(o *outlier) {
+	phc.mu.Lock()
+	defer phc.mu.Unlock()
+
+	if !o.ejected {
+		return
+	}
+
+	logger.Infof("[healthcheck][passive] server %s is back on probation", o.server.ID())
+
+	o.ejected = false
+	o.until = time.Time{}
+	o.timer = nil
+	o.probation = true
+	o.server.SetHealthy(!o.checked || o.activeHealthy)
+}
+
+func (phc *PassiveHealthChecks) get(backend *route.NormalizedBackend, server *service.Server) *outlier {
+	backendID := BackendID(backend)
+	if phc.outliers[backendID] == nil {
+		phc.outliers[backendID] = make(map[string]*outlier)
+	}
+
+	o, exists := phc.outliers[backendID][server.ID()]
+	if !exists {
+		o = &outlier{server: server}
+		phc.outliers[backendID][server.ID()] = o
+	}
+	// the backend may have been normalized into new server instances
+	o.server = server
+	return o
+}
@@ -0,0 +1,185 @@
+package loadbalancer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+func newPassiveBackend(cfg service.PassiveHealthCheck, servers int) *route.NormalizedBackend {
+	cfg.Enable = true
+	backend := &route.NormalizedBackend{
+		Algorithm:  "round-robin",
+		BaseConfig: &service.Service{HealthCheck: service.HealthCheck{Passive: cfg}},
+	}
+	for i := 0; i < servers; i++ {
+		server := &service.Server{Name: "server.com", Port: int64(8080 + i)}
+		server.SetHealthy(true)
+		backend.Servers = append(backend.Servers, server)
+	}
+	return backend
+}
+
+func TestPassiveHealthEject(t *testing.T) {
+	phc := NewPassiveHealthChecks()
+	defer phc.StopAll()
+
+	backend := newPassiveBackend(service.PassiveHealthCheck{ConsecutiveFailures: 3, BaseEjection: 60}, 2)
+	server := backend.Servers[0]
+
+	phc.Record(backend, server, true)
+	phc.Record(backend, server, true)
+	// 成功请求会重置连续失败计数
+	phc.Record(backend, server, false)
+	phc.Record(backend, server, true)
+	phc.Record(backend, server, true)
+	if !server.IsHealthy() {
+		t.Fatal("连续失败未达到阈值时应该保持健康")
+	}
+
+	phc.Record(backend, server, true)
+	if server.IsHealthy() || !phc.Ejected(backend, server) {
+		t.Fatal("连续失败达到阈值时应该被摘除")
+	}
+
+	until := phc.EjectedUntil(backend, server)
+	if d := time.Until(until); d < 59*time.Second || d > 60*time.Second {
+		t.Errorf("摘除时长应该是 60s, 实际: %s", d)
+	}
+}
+
+func TestPassiveHealthMaxEjectionPercent(t *testing.T) {
+	phc := NewPassiveHealthChecks()
+	defer phc.StopAll()
+
+	backend := newPassiveBackend(service.PassiveHealthCheck{ConsecutiveFailures: 1, MaxEjectionPercent: 50}, 2)
+
+	phc.Record(backend, backend.Servers[0], true)
+	phc.Record(backend, backend.Servers[1], true)
+
+	if !phc.Ejected(backend, backend.Servers[0]) {
+		t.Fatal("第一台服务器应该被摘除")
+	}
+	if phc.Ejected(backend, backend.Servers[1]) || !backend.Servers[1].IsHealthy() {
+		t.Fatal("超过 max_ejection_percent 时不应该继续摘除")
+	}
+}
+
+func TestPassiveHealthSingleServerNeverEjected(t *testing.T) {
+	phc := NewPassiveHealthChecks()
+	defer phc.StopAll()
+
+	backend := newPassiveBackend(service.PassiveHealthCheck{ConsecutiveFailures: 1}, 1)
+
+	phc.Record(backend, backend.Servers[0], true)
+	if !backend.Servers[0].IsHealthy() {
+		t.Fatal("只有一台服务器时不应该被摘除")
+	}
+}
+
+func TestPassiveHealthProbation(t *testing.T) {
+	phc := NewPassiveHealthChecks()
+	defer phc.StopAll()
+
+	backend := newPassiveBackend(service.PassiveHealthCheck{ConsecutiveFailures: 2, BaseEjection: 1, ProbationSuccesses: 2}, 2)
+	server := backend.Servers[0]
+
+	phc.Record(backend, server, true)
+	phc.Record(backend, server, true)
+	if server.IsHealthy() {
+		t.Fatal("应该被摘除")
+	}
+
+	time.Sleep(1200 * time.Millisecond)
+	if !server.IsHealthy() || phc.Ejected(backend, server) {
+		t.Fatal("摘除时间结束后应该恢复")
+	}
+
+	// 观察期内一次失败就会再次摘除，并且摘除时间翻倍
+	phc.Record(backend, server, true)
+	if server.IsHealthy() {
+		t.Fatal("观察期内失败应该再次被摘除")
+	}
+	if d := time.Until(phc.EjectedUntil(backend, server)); d < 1500*time.Millisecond {
+		t.Errorf("第二次摘除时长应该翻倍, 实际: %s", d)
+	}
+}
+
+func TestPassiveHealthProbationPassed(t *testing.T) {
+	phc := NewPassiveHealthChecks()
+	defer phc.StopAll()
+
+	backend := newPassiveBackend(service.PassiveHealthCheck{ConsecutiveFailures: 1, ProbationSuccesses: 2}, 2)
+	server := backend.Servers[0]
+
+	phc.Record(backend, server, true)
+	phc.restore(phc.get(backend, server))
+
+	phc.Record(backend, server, false)
+	phc.Record(backend, server, false)
+
+	// 观察期结束后，需要重新达到连续失败阈值，摘除时长也重新计算
+	o := phc.get(backend, server)
+	if o.probation || o.ejections != 0 {
+		t.Fatalf("观察期应该结束, probation: %v, ejections: %d", o.probation, o.ejections)
+	}
+}
+
+func TestActiveHealthCheckKeepsEjectedServer(t *testing.T) {
+	phc := NewPassiveHealthChecks()
+	defer phc.StopAll()
+
+	backend := newPassiveBackend(service.PassiveHealthCheck{ConsecutiveFailures: 1, BaseEjection: 60}, 2)
+	server := backend.Servers[0]
+
+	phc.Record(backend, server, true)
+
+	hc := &healthChecker{backend: backend, passive: phc}
+	hc.setHealthy(server, true)
+	if server.IsHealthy() {
+		t.Fatal("主动健康检查不应该提前恢复被摘除的服务器")
+	}
+
+	hc.setHealthy(backend.Servers[1], false)
+	if backend.Servers[1].IsHealthy() {
+		t.Fatal("主动健康检查失败应该标记为不健康")
+	}
+}
+
+func TestPassiveHealthRestoresActiveResult(t *testing.T) {
+	phc := NewPassiveHealthChecks()
+	defer phc.StopAll()
+
+	backend := newPassiveBackend(service.PassiveHealthCheck{ConsecutiveFailures: 1, BaseEjection: 60}, 2)
+	server := backend.Servers[0]
+	hc := &healthChecker{backend: backend, passive: phc}
+
+	phc.Record(backend, server, true)
+	hc.setHealthy(server, false)
+
+	// 摘除期间主动健康检查失败，摘除结束后应该保持不健康
+	phc.restore(phc.get(backend, server))
+	if server.IsHealthy() {
+		t.Fatal("摘除结束后应该恢复最后一次主动健康检查的结果")
+	}
+
+	hc.setHealthy(server, true)
+	if !server.IsHealthy() {
+		t.Fatal("主动健康检查通过后应该恢复健康")
+	}
+}
+
+func TestPassiveHealthDisabled(t *testing.T) {
+	phc := NewPassiveHealthChecks()
+	defer phc.StopAll()
+
+	backend := newPassiveBackend(service.PassiveHealthCheck{ConsecutiveFailures: 1}, 2)
+	backend.BaseConfig.HealthCheck.Passive.Enable = false
+
+	phc.Record(backend, backend.Servers[0], true)
+	if !backend.Servers[0].IsHealthy() {
+		t.Fatal("未启用被动健康检查时不应该摘除")
+	}
+}
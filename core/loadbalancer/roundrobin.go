@@ -15,6 +15,8 @@ import (
 
 // RoundRobin implements round-robin load balancing
 type RoundRobin struct {
+	serverFilter
+
 	indices map[string]*atomic.Uint64
 	mu      sync.RWMutex
 }
@@ -28,20 +30,15 @@ func NewRoundRobin() *RoundRobin {
 
 // Select chooses a server using round-robin algorithm
 func (rr *RoundRobin) Select(req *http.Request, backend *route.NormalizedBackend) (*service.Server, error) {
-	// Filter healthy and enabled servers
-	healthyServers := make([]*service.Server, 0)
-	for _, server := range backend.Servers {
-		if !server.Disabled && server.IsHealthy() {
-			healthyServers = append(healthyServers, server)
-		}
-	}
+	// Filter healthy, enabled and circuit-closed servers
+	healthyServers := rr.available(backend)
 
 	if len(healthyServers) == 0 {
 		return nil, fmt.Errorf("no healthy servers available")
 	}
 
 	// Get or create index for this backend
-	backendID := getBackendID(backend)
+	backendID := BackendID(backend)
 	rr.mu.Lock()
 	idx, exists := rr.indices[backendID]
 	if !exists {
@@ -72,8 +69,9 @@ func (rr *RoundRobin) OnRequestEnd(backend *route.NormalizedBackend, server *ser
 	// Round-robin doesn't track connections
 }
 
-// getBackendID generates a unique ID for a backend based on all servers
-func getBackendID(backend *route.NormalizedBackend) string {
+// BackendID generates a unique ID for a backend based on all servers, shared by the
+// backends of every route with the same set of servers
+func BackendID(backend *route.NormalizedBackend) string {
 	if len(backend.Servers) == 0 {
 		return "empty"
 	}
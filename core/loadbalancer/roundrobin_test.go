@@ -147,9 +147,9 @@ func TestGetBackendIDUniqueness(t *testing.T) {
 		BaseConfig: &service.Service{},
 	}
 
-	id1 := getBackendID(backend1)
-	id2 := getBackendID(backend2)
-	id3 := getBackendID(backend3)
+	id1 := BackendID(backend1)
+	id2 := BackendID(backend2)
+	id3 := BackendID(backend3)
 
 	// Backend1 and Backend2 should have different IDs (different server sets)
 	if id1 == id2 {
@@ -11,6 +11,8 @@ import (
 
 // WeightedRoundRobin implements smooth weighted round-robin load balancing
 type WeightedRoundRobin struct {
+	serverFilter
+
 	weights map[string]map[string]int64 // backend ID -> server ID -> current weight
 	mu      sync.RWMutex
 }
@@ -24,15 +26,12 @@ func NewWeightedRoundRobin() *WeightedRoundRobin {
 
 // Select chooses a server using weighted round-robin algorithm
 func (wrr *WeightedRoundRobin) Select(req *http.Request, backend *route.NormalizedBackend) (*service.Server, error) {
-	// Filter healthy and enabled servers
-	healthyServers := make([]*service.Server, 0)
+	// Filter healthy, enabled and circuit-closed servers
+	healthyServers := wrr.available(backend)
 	totalWeight := int64(0)
 
-	for _, server := range backend.Servers {
-		if !server.Disabled && server.IsHealthy() {
-			healthyServers = append(healthyServers, server)
-			totalWeight += server.Weight
-		}
+	for _, server := range healthyServers {
+		totalWeight += server.Weight
 	}
 
 	if len(healthyServers) == 0 {
@@ -42,7 +41,7 @@ func (wrr *WeightedRoundRobin) Select(req *http.Request, backend *route.Normaliz
 	wrr.mu.Lock()
 	defer wrr.mu.Unlock()
 
-	backendID := getBackendID(backend)
+	backendID := BackendID(backend)
 
 	// Initialize weights if needed
 	if wrr.weights[backendID] == nil {
@@ -1,11 +1,6 @@
 package core
 
 import (
-	"errors"
-	"time"
-
-	"github.com/go-zoox/core-utils/fmt"
-
 	"github.com/go-zoox/api-gateway/core/route"
 	"github.com/go-zoox/api-gateway/core/service"
 	"github.com/go-zoox/api-gateway/router"
@@ -16,24 +11,22 @@ type Matcher struct {
 	Service service.Service
 }
 
-func (c *core) match(ctx *zoox.Context, path string) (s *route.Route, err error) {
-	key := fmt.Sprintf("match.path:%s", path)
-	matcher := &route.Route{}
-	if err := ctx.Cache().Get(key, matcher); err == nil {
-		return matcher, nil
+// matchRoute attaches the route of the request to it, so the plugins and the proxy share
+// a single match.
+func (c *core) matchRoute(ctx *zoox.Context) {
+	if r, params, err := c.matcher.Match(ctx.Request); err == nil {
+		ctx.Request = router.WithRoute(ctx.Request, r, params)
 	}
 
-	s, err = router.RouteForPath(c.cfg, path)
-	if err != nil {
-		if errors.Is(err, ErrRouteNotFound) {
-			return nil, err
-		}
-		return nil, err
-	}
+	ctx.Next()
+}
 
-	ctx.Cache().Set(key, s, 60*time.Second)
+func (c *core) match(ctx *zoox.Context) (*route.Route, router.Params, error) {
+	if r := router.FromRequest(ctx.Request); r != nil {
+		return r, router.ParamsFromRequest(ctx.Request), nil
+	}
 
-	return s, nil
+	return c.matcher.Match(ctx.Request)
 }
 
 // MatchPath delegates to [router.MatchPath] for the same path matching rules as the gateway.
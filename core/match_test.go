@@ -1,8 +1,10 @@
 package core
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/go-zoox/api-gateway/config"
 	"github.com/go-zoox/api-gateway/core/route"
 	"github.com/go-zoox/api-gateway/core/service"
 )
@@ -110,3 +112,85 @@ func TestMatchPath(t *testing.T) {
 		t.Fatalf("expected http, got %s", s.Backend.Service.Protocol)
 	}
 }
+
+func TestRouterMode(t *testing.T) {
+	api := newNamedUpstream(t, "api")
+	users := newNamedUpstream(t, "users")
+
+	routes := []route.Route{
+		{Name: "mode-api", Path: "/api", Backend: route.Backend{Service: testService(t, api)}},
+		{Name: "mode-users", Path: "/api/users", Backend: route.Backend{Service: testService(t, users)}},
+	}
+
+	_, _, url := serveTestGateway(t, &config.Config{Routes: routes})
+	if _, body := get(t, url+"/api/users/1"); body != "api" {
+		t.Errorf("expected the first matching route, got %q", body)
+	}
+
+	_, _, url = serveTestGateway(t, &config.Config{Routes: routes, Router: config.Router{Mode: "longest_prefix"}})
+	if _, body := get(t, url+"/api/users/1"); body != "users" {
+		t.Errorf("expected the longest prefix, got %q", body)
+	}
+	if _, body := get(t, url+"/api/posts"); body != "api" {
+		t.Errorf("expected the shorter prefix, got %q", body)
+	}
+
+	if _, err := New("test", &config.Config{Routes: routes, Router: config.Router{Mode: "random"}}); err == nil {
+		t.Error("expected an unsupported mode to be rejected")
+	}
+}
+
+func TestRoutePriority(t *testing.T) {
+	v1 := newNamedUpstream(t, "v1")
+	user := newNamedUpstream(t, "user")
+
+	routes := []route.Route{
+		{Name: "priority-v1", Path: "/v1", Backend: route.Backend{Service: testService(t, v1)}},
+		{Name: "priority-user", Path: "/v1/user", Priority: 10, Backend: route.Backend{Service: testService(t, user)}},
+	}
+
+	_, _, url := serveTestGateway(t, &config.Config{Routes: routes})
+	if _, body := get(t, url+"/v1/user/1"); body != "user" {
+		t.Errorf("expected the route with the highest priority, got %q", body)
+	}
+	if _, body := get(t, url+"/v1/posts"); body != "v1" {
+		t.Errorf("expected the other route, got %q", body)
+	}
+}
+
+func TestRouterConflicts(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+	backend := route.Backend{Service: testService(t, upstream)}
+
+	shadowed := []route.Route{
+		{Name: "conflicts-v1", Path: "/v1", Backend: backend},
+		{Name: "conflicts-user", Path: "/v1/user", Backend: backend},
+	}
+	if _, err := New("test", &config.Config{Routes: shadowed}); err != nil {
+		t.Fatalf("expected conflicts to be logged only, got %s", err)
+	}
+
+	_, err := New("test", &config.Config{Routes: shadowed, Router: config.Router{Conflicts: "reject"}})
+	if err == nil || !strings.Contains(err.Error(), "conflicts-user") {
+		t.Fatalf("expected the shadowed route to be rejected, got %v", err)
+	}
+
+	duplicates := []route.Route{
+		{Name: "conflicts-api", Path: "/api", Backend: backend},
+		{Name: "conflicts-api", Path: "/other", Backend: backend},
+	}
+	_, err = New("test", &config.Config{Routes: duplicates, Router: config.Router{Conflicts: "reject"}})
+	if err == nil || !strings.Contains(err.Error(), "duplicate route name") {
+		t.Fatalf("expected the duplicate name to be rejected, got %v", err)
+	}
+
+	// a priority resolves the conflict
+	shadowed[1].Priority = 1
+	if _, err := New("test", &config.Config{Routes: shadowed, Router: config.Router{Conflicts: "reject"}}); err != nil {
+		t.Fatalf("expected no conflict, got %s", err)
+	}
+
+	if _, err := New("test", &config.Config{Routes: shadowed, Router: config.Router{Conflicts: "ignore"}}); err == nil {
+		t.Error("expected an unsupported conflicts policy to be rejected")
+	}
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-zoox/api-gateway/metrics"
+	"github.com/go-zoox/zoox"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// prepareMetrics records the requests of the generation, and serves the metrics on the
+// gateway port unless they have their own. It runs ahead of the plugins, so requests
+// they answer (IP policy, CORS preflight, ...) are counted too.
+func (c *core) prepareMetrics() {
+	if !c.cfg.Metrics.Enable {
+		return
+	}
+
+	handler := c.metricsHandler()
+	path := c.cfg.Metrics.EffectivePath()
+	onGatewayPort := c.cfg.Metrics.Port == 0
+
+	c.app.Use(func(ctx *zoox.Context) {
+		if onGatewayPort && ctx.Path == path {
+			handler.ServeHTTP(ctx.Writer, ctx.Request)
+			return
+		}
+
+		observeRequest(ctx)
+	})
+}
+
+// observeRequest counts the request, its duration and the bytes of its bodies, under
+// the route it matched.
+func observeRequest(ctx *zoox.Context) {
+	start := time.Now()
+
+	ctx.Request = metrics.Observe(ctx.Request)
+	body := &countingBody{ReadCloser: ctx.Request.Body}
+	if ctx.Request.Body != nil && ctx.Request.Body != http.NoBody {
+		ctx.Request.Body = body
+	}
+
+	ctx.Next()
+
+	route, _ := metrics.Done(ctx.Request)
+	method := metrics.Method(ctx.Method)
+	status := metrics.StatusClass(ctx.Writer.Status())
+
+	metrics.Requests.WithLabelValues(route, method, status).Inc()
+	metrics.RequestDuration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+	metrics.RequestBytes.WithLabelValues(route, method).Add(float64(body.n))
+	if size := ctx.Writer.Size(); size > 0 {
+		metrics.ResponseBytes.WithLabelValues(route, method, status).Add(float64(size))
+	}
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// metricsHandler serves the gateway metrics, with the state of the backend servers.
+func (c *core) metricsHandler() http.Handler {
+	servers := prometheus.NewRegistry()
+	servers.MustRegister(&serverCollector{shared: c.shared})
+	return metrics.Handler(servers)
+}
+
+// newMetricsServer creates the server of the metrics, when they have their own port.
+func (c *core) newMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(c.cfg.Metrics.EffectivePath(), c.metricsHandler())
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+var (
+	serverHealthyDesc = prometheus.NewDesc(
+		"api_gateway_server_healthy",
+		"Whether the backend server can receive traffic (1) or is unhealthy or disabled (0), by backend and server.",
+		[]string{"backend", "server"}, nil,
+	)
+	serverInFlightDesc = prometheus.NewDesc(
+		"api_gateway_server_requests_in_flight",
+		"Requests in flight to the backend server, by backend and server.",
+		[]string{"backend", "server"}, nil,
+	)
+)
+
+// serverCollector reports the backend servers of the running configuration when scraped.
+type serverCollector struct {
+	shared *shared
+}
+
+func (s *serverCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serverHealthyDesc
+	ch <- serverInFlightDesc
+}
+
+func (s *serverCollector) Collect(ch chan<- prometheus.Metric) {
+	c := s.shared.current.Load()
+	inflight := c.lbManager.GetInFlight()
+
+	for _, group := range c.backendGroups() {
+		backend := group.backends[0]
+		for _, server := range backend.Servers {
+			healthy := 0.0
+			if server.IsHealthy() && !server.IsDisabled() {
+				healthy = 1
+			}
+
+			ch <- prometheus.MustNewConstMetric(serverHealthyDesc, prometheus.GaugeValue, healthy, group.id, server.ID())
+			ch <- prometheus.MustNewConstMetric(serverInFlightDesc, prometheus.GaugeValue, float64(inflight.Count(backend, server)), group.id, server.ID())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// metricValue returns the value of a series in the text exposition format, like
+// `api_gateway_requests_total{method="GET",route="api",status="2xx"}`, or -1.
+func metricValue(body, series string) float64 {
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), series+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return -1
+			}
+			return v
+		}
+	}
+	return -1
+}
+
+func TestMetrics(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Metrics: config.Metrics{Enable: true},
+		Routes: []route.Route{
+			{
+				Name: "metrics-api",
+				Path: "/api",
+				Backend: route.Backend{
+					Service: service.Service{
+						Protocol: "http",
+						Servers:  []service.Server{*testServer(t, upstream)},
+					},
+				},
+				RateLimit: route.RateLimit{Enable: true, Limit: 2, Window: 60, Algorithm: "fixed-window"},
+			},
+			{
+				Name:     "metrics-denied",
+				Path:     "/denied",
+				Backend:  route.Backend{Service: testService(t, upstream)},
+				IPPolicy: route.IPPolicy{Enable: true, Deny: []string{"127.0.0.1/32"}},
+			},
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		get(t, url+"/api")
+	}
+	if status, _ := get(t, url+"/denied"); status != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", status)
+	}
+
+	status, body := get(t, url+"/metrics")
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	server := testServer(t, upstream).ID()
+	expected := map[string]float64{
+		`api_gateway_requests_total{method="GET",route="metrics-api",status="2xx"}`:                                      2,
+		`api_gateway_requests_total{method="GET",route="metrics-api",status="4xx"}`:                                      1,
+		`api_gateway_requests_total{method="GET",route="metrics-denied",status="4xx"}`:                                   1,
+		`api_gateway_requests_in_flight{route="metrics-api"}`:                                                            0,
+		`api_gateway_ratelimit_requests_total{result="allowed",route="metrics-api"}`:                                     2,
+		`api_gateway_ratelimit_requests_total{result="denied",route="metrics-api"}`:                                      1,
+		`api_gateway_ippolicy_denied_total{route="metrics-denied"}`:                                                      1,
+		`api_gateway_loadbalancer_selections_total{algorithm="round-robin",route="metrics-api",server="` + server + `"}`: 3,
+		`api_gateway_upstream_duration_seconds_count{route="metrics-api",server="` + server + `",status="2xx"}`:          2,
+	}
+	for series, want := range expected {
+		if got := metricValue(body, series); got != want {
+			t.Errorf("expected %s to be %g, got %g", series, want, got)
+		}
+	}
+
+	if got := metricValue(body, `api_gateway_response_bytes_total{method="GET",route="metrics-api",status="2xx"}`); got != 2 {
+		t.Errorf("expected 2 response bytes, got %g", got)
+	}
+	if !strings.Contains(body, `api_gateway_server_healthy{backend="`) {
+		t.Error("expected the health of the backend servers")
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Backend: route.Backend{Service: testService(t, upstream)},
+	})
+
+	// without metrics, the path is proxied like any other
+	if _, body := get(t, url+"/metrics"); body != "a" {
+		t.Fatalf("expected the request to be proxied, got %q", body)
+	}
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/metrics"
+	"github.com/go-zoox/api-gateway/requestid"
+	"github.com/go-zoox/api-gateway/router"
+	"github.com/go-zoox/zoox"
+	"github.com/gorilla/websocket"
+)
+
+// mirrorCounter tracks the copies in flight per route.
+type mirrorCounter struct {
+	counts map[string]int64
+	mu     sync.Mutex
+}
+
+func newMirrorCounter() *mirrorCounter {
+	return &mirrorCounter{
+		counts: make(map[string]int64),
+	}
+}
+
+// Acquire reserves a copy on the route, reporting false when max is reached.
+func (m *mirrorCounter) Acquire(key string, max int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.counts[key] >= max {
+		return false
+	}
+	m.counts[key]++
+	return true
+}
+
+// Release frees a copy reserved by Acquire.
+func (m *mirrorCounter) Release(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.counts[key] <= 1 {
+		delete(m.counts, key)
+		return
+	}
+	m.counts[key]--
+}
+
+// primaryOutcome is how the primary backend answered a mirrored request.
+type primaryOutcome struct {
+	status  int
+	latency time.Duration
+}
+
+// mirrorRequest copies a request to the mirror backend of its route, and compares the
+// answer of the mirror with the one of the primary backend.
+type mirrorRequest struct {
+	c      *core
+	route  *route.Route
+	params router.Params
+	body   []byte
+	served context.Context
+
+	sent    time.Time
+	primary chan primaryOutcome
+	once    sync.Once
+}
+
+// mirror picks the requests of r that are copied to its mirror backend and buffers their
+// body; it returns nil for the others. WebSockets and gRPC calls are never mirrored.
+func (c *core) mirror(ctx *zoox.Context, r *route.Route) *mirrorRequest {
+	if !r.Mirror.Enable || websocket.IsWebSocketUpgrade(ctx.Request) || isGRPCRequest(ctx.Request) {
+		return nil
+	}
+	if rand.Float64()*100 >= r.Mirror.EffectivePercentage() {
+		return nil
+	}
+
+	body, ok := bufferRequestBody(ctx.Request, r.Mirror.EffectiveMaxBodyBytes())
+	if !ok {
+		metrics.MirrorSkipped.WithLabelValues(r.Name, "body_too_large").Inc()
+		return nil
+	}
+
+	return &mirrorRequest{
+		c:       c,
+		route:   r,
+		params:  router.ParamsFromRequest(ctx.Request),
+		body:    body,
+		served:  ctx.Request.Context(),
+		primary: make(chan primaryOutcome, 1),
+	}
+}
+
+// send copies req, the request sent to the primary upstream, to the mirror backend in the
+// background, with the path, query and request headers of the mirror service in place of
+// those of the primary.
+func (m *mirrorRequest) send(req *http.Request, primary *upstream) {
+	r := m.route
+	backend := r.Mirror.Backend.Normalize()
+	lb := m.c.lbManager.GetLoadBalancer(backend.Algorithm)
+	server, err := lb.Select(req, backend)
+	if err != nil {
+		metrics.MirrorSkipped.WithLabelValues(r.Name, "no_server").Inc()
+		return
+	}
+	if !m.c.mirrors.Acquire(r.Name, r.Mirror.EffectiveMaxInFlight()) {
+		metrics.MirrorSkipped.WithLabelValues(r.Name, "max_in_flight").Inc()
+		return
+	}
+
+	u := newUpstream(m.c, r.Name, backend, lb, server)
+	u.path = primary.path
+	u.rawQuery = primary.rawQuery
+	u.params = m.params
+
+	// the copy outlives the request, but keeps its values, like the request ID
+	out := req.Clone(context.WithoutCancel(req.Context()))
+	out.Body = http.NoBody
+	if len(m.body) > 0 {
+		out.Body = io.NopCloser(bytes.NewReader(m.body))
+	}
+	out.ContentLength = int64(len(m.body))
+	// the request headers of the primary service (credentials, ...) are not for the mirror
+	for k := range primary.service.Request.Headers {
+		out.Header.Del(k)
+	}
+	u.apply(out)
+
+	m.sent = time.Now()
+	go func() {
+		defer m.c.mirrors.Release(r.Name)
+		m.run(out, u)
+	}()
+}
+
+// respond records the answer of the primary backend; only the first call counts.
+func (m *mirrorRequest) respond(status int) {
+	m.once.Do(func() {
+		m.primary <- primaryOutcome{status: status, latency: time.Since(m.sent)}
+	})
+}
+
+func (m *mirrorRequest) run(req *http.Request, u *upstream) {
+	ctx, cancel := context.WithTimeout(req.Context(), m.route.Mirror.EffectiveTimeout())
+	defer cancel()
+
+	status := 0
+	start := time.Now()
+	transport, err := u.transports.Get(u.service, u.timeout)
+	if err == nil {
+		var res *http.Response
+		res, err = transport.RoundTrip(req.WithContext(ctx))
+		if err == nil {
+			status = res.StatusCode
+			drainBody(res.Body)
+		}
+	}
+	latency := time.Since(start)
+	if err != nil {
+		requestid.Logger(req).Warnf("[mirror][route: %s] %s %s => %s failed: %s", m.route.Name, req.Method, req.URL.Path, u.server.ID(), err)
+	}
+
+	// the primary answers first, unless it failed before its response
+	var primary primaryOutcome
+	select {
+	case primary = <-m.primary:
+	case <-m.served.Done():
+		select {
+		case primary = <-m.primary:
+		default:
+		}
+	}
+
+	metrics.MirrorRequests.WithLabelValues(m.route.Name, metrics.StatusClass(primary.status), metrics.StatusClass(status)).Inc()
+	if primary.status != 0 {
+		metrics.MirrorDuration.WithLabelValues(m.route.Name, "primary").Observe(primary.latency.Seconds())
+	}
+	if err == nil {
+		metrics.MirrorDuration.WithLabelValues(m.route.Name, "mirror").Observe(latency.Seconds())
+	}
+}
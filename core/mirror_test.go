@@ -0,0 +1,247 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+)
+
+type mirrored struct {
+	method string
+	path   string
+	body   string
+	id     string
+	header http.Header
+}
+
+// newMirrorUpstream records the requests it receives and answers with status after delay.
+func newMirrorUpstream(t *testing.T, status int, delay time.Duration) (*httptest.Server, chan mirrored) {
+	t.Helper()
+
+	received := make(chan mirrored, 16)
+	upstream := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- mirrored{method: r.Method, path: r.URL.RequestURI(), body: string(body), id: r.Header.Get("X-Request-Id"), header: r.Header}
+		time.Sleep(delay)
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, "mirror")
+	})
+	return upstream, received
+}
+
+func TestMirror(t *testing.T) {
+	primary := newNamedUpstream(t, "primary")
+	mirror, received := newMirrorUpstream(t, http.StatusInternalServerError, 0)
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Metrics: config.Metrics{Enable: true},
+		Routes: []route.Route{
+			{
+				Name:    "mirror-api",
+				Path:    "/api",
+				Backend: route.Backend{Service: testService(t, primary)},
+				Mirror:  route.Mirror{Enable: true, Backend: route.Backend{Service: testService(t, mirror)}},
+			},
+		},
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, url+"/api/users?page=2", strings.NewReader("payload"))
+	req.Header.Set("X-Request-Id", "mirrored-1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || string(body) != "primary" {
+		t.Fatalf("expected the primary response, got %d %q", res.StatusCode, body)
+	}
+
+	select {
+	case got := <-received:
+		want := mirrored{method: http.MethodPost, path: "/api/users?page=2", body: "payload", id: "mirrored-1"}
+		got.header = nil
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected the mirror to receive %+v, got %+v", want, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the request to be mirrored")
+	}
+
+	// the mirror compares both answers once the primary one is known
+	series := `api_gateway_mirror_requests_total{mirror_status="5xx",primary_status="2xx",route="mirror-api"}`
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, metrics := get(t, url+"/metrics")
+		if metricValue(metrics, series) == 1 {
+			if metricValue(metrics, `api_gateway_mirror_duration_seconds_count{route="mirror-api",target="primary"}`) != 1 ||
+				metricValue(metrics, `api_gateway_mirror_duration_seconds_count{route="mirror-api",target="mirror"}`) != 1 {
+				t.Errorf("expected the latency of both backends, got\n%s", metrics)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s to be 1, got\n%s", series, metrics)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMirrorDoesNotDelayClient(t *testing.T) {
+	primary := newNamedUpstream(t, "primary")
+	mirror, received := newMirrorUpstream(t, http.StatusOK, time.Second)
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "mirror-slow",
+				Path:    "/api",
+				Backend: route.Backend{Service: testService(t, primary)},
+				Mirror:  route.Mirror{Enable: true, Backend: route.Backend{Service: testService(t, mirror)}},
+			},
+		},
+	})
+
+	start := time.Now()
+	if status, body := get(t, url+"/api"); status != http.StatusOK || body != "primary" {
+		t.Fatalf("expected the primary response, got %d %q", status, body)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the client not to wait for the mirror, took %s", elapsed)
+	}
+	<-received
+}
+
+func TestMirrorSkipped(t *testing.T) {
+	primary, primaryReceived := newMirrorUpstream(t, http.StatusOK, 0)
+	mirror, received := newMirrorUpstream(t, http.StatusOK, 0)
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "mirror-large",
+				Path:    "/large",
+				Backend: route.Backend{Service: testService(t, primary)},
+				Mirror:  route.Mirror{Enable: true, Backend: route.Backend{Service: testService(t, mirror)}, MaxBodyBytes: 4},
+			},
+			{
+				Name:    "mirror-sampled",
+				Path:    "/sampled",
+				Backend: route.Backend{Service: testService(t, primary)},
+				Mirror:  route.Mirror{Enable: true, Backend: route.Backend{Service: testService(t, mirror)}, Percentage: 0.0001},
+			},
+		},
+	})
+
+	// larger bodies reach the primary untouched, without a copy
+	res, err := http.Post(url+"/large", "text/plain", strings.NewReader("too large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if got := <-primaryReceived; got.body != "too large" {
+		t.Errorf("expected the primary to receive the whole body, got %q", got.body)
+	}
+
+	for i := 0; i < 10; i++ {
+		get(t, url+"/sampled")
+		<-primaryReceived
+	}
+
+	select {
+	case got := <-received:
+		t.Errorf("expected no request to be mirrored, got %+v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMirrorRequiresBackend(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+
+	_, err := New("test", &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "mirror-none",
+				Path:    "/api",
+				Backend: route.Backend{Service: testService(t, upstream)},
+				Mirror:  route.Mirror{Enable: true},
+			},
+		},
+	})
+	if err == nil {
+		t.Error("expected a mirror without a backend to be rejected")
+	}
+}
+
+func TestMirrorRequestHeaders(t *testing.T) {
+	primary := newNamedUpstream(t, "primary")
+	mirror, received := newMirrorUpstream(t, http.StatusOK, 0)
+
+	primaryService := testService(t, primary)
+	primaryService.Request.Headers = map[string]string{"X-Upstream-Token": "primary-secret", "X-Env": "production"}
+	mirrorService := testService(t, mirror)
+	mirrorService.Request.Headers = map[string]string{"X-Env": "staging"}
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "mirror-headers",
+				Path:    "/api",
+				Backend: route.Backend{Service: primaryService},
+				Mirror:  route.Mirror{Enable: true, Backend: route.Backend{Service: mirrorService}},
+			},
+		},
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, url+"/api", nil)
+	req.Header.Set("X-Client", "client")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	select {
+	case got := <-received:
+		if token := got.header.Get("X-Upstream-Token"); token != "" {
+			t.Errorf("expected the headers of the primary service not to reach the mirror, got %q", token)
+		}
+		if env := got.header.Get("X-Env"); env != "staging" {
+			t.Errorf("expected the headers of the mirror service, got %q", env)
+		}
+		if client := got.header.Get("X-Client"); client != "client" {
+			t.Errorf("expected the headers of the client, got %q", client)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the request to be mirrored")
+	}
+}
+
+func TestMirrorTLS(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+
+	mirrorService := testService(t, upstream)
+	mirrorService.TLS.CA = filepath.Join(t.TempDir(), "missing.pem")
+
+	_, err := New("test", &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "mirror-tls",
+				Path:    "/api",
+				Backend: route.Backend{Service: testService(t, upstream)},
+				Mirror:  route.Mirror{Enable: true, Backend: route.Backend{Service: mirrorService}},
+			},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "mirror") {
+		t.Errorf("expected an invalid mirror tls setting to fail at startup, got %v", err)
+	}
+}
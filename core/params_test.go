@@ -0,0 +1,74 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+func TestParamRoutes(t *testing.T) {
+	upstream := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.RequestURI()+" "+r.Header.Get("X-User-Id"))
+	})
+
+	orders := testService(t, upstream)
+	orders.Request = service.Request{
+		Path: service.RequestPath{
+			Rewrites: []string{"^/[^/]+/users/[^/]+/orders(.*)$:/v2/accounts/{id}/orders$1"},
+		},
+		Headers: map[string]string{"X-User-Id": "{id}"},
+		Query:   map[string]string{"tenant": "{tenant}"},
+	}
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:     "params-orders",
+				Path:     "/:tenant/users/:id/orders/*rest",
+				PathType: "param",
+				Backend:  route.Backend{Service: orders},
+			},
+			{
+				Name:     "params-limited",
+				Path:     "/:tenant/limited",
+				PathType: "param",
+				Backend:  route.Backend{Service: testService(t, upstream)},
+				RateLimit: route.RateLimit{
+					Enable:   true,
+					KeyType:  "param",
+					KeyParam: "tenant",
+					Limit:    1,
+					Window:   60,
+				},
+			},
+		},
+	})
+
+	// the params of the route path fill the rewrite, header and query templates
+	status, body := get(t, url+"/acme/users/42/orders/7")
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d %q", status, body)
+	}
+	if want := "/v2/accounts/42/orders/7?tenant=acme 42"; body != want {
+		t.Errorf("expected %q, got %q", want, body)
+	}
+
+	// one request per tenant
+	if status, _ := get(t, url+"/acme/limited"); status != http.StatusOK {
+		t.Errorf("expected the first request of acme to pass, got %d", status)
+	}
+	if status, _ := get(t, url+"/acme/limited"); status != http.StatusTooManyRequests {
+		t.Errorf("expected the second request of acme to be limited, got %d", status)
+	}
+	if status, _ := get(t, url+"/globex/limited"); status != http.StatusOK {
+		t.Errorf("expected another tenant to have its own limit, got %d", status)
+	}
+
+	if status, _ := get(t, url+"/acme/users"); status != http.StatusNotFound {
+		t.Errorf("expected 404 for a path without the params, got %d", status)
+	}
+}
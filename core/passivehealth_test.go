@@ -0,0 +1,61 @@
+package core
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+func TestPassiveHealthCheckEjectsFailingServer(t *testing.T) {
+	var failed, ok int32
+	bad := newCountingUpstream(t, http.StatusBadGateway, &failed)
+	good := newCountingUpstream(t, http.StatusOK, &ok)
+
+	badServer, goodServer := testServer(t, bad), testServer(t, good)
+
+	// no active health check is configured
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name: "passive",
+				Path: "/passive",
+				Backend: route.Backend{
+					Service: service.Service{
+						Protocol:  "http",
+						Algorithm: "round-robin",
+						Servers: []service.Server{
+							{Name: badServer.Name, Port: badServer.Port},
+							{Name: goodServer.Name, Port: goodServer.Port},
+						},
+						HealthCheck: service.HealthCheck{
+							Passive: service.PassiveHealthCheck{
+								Enable:              true,
+								ConsecutiveFailures: 3,
+								BaseEjection:        60,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	for i := 0; i < 12; i++ {
+		res, err := http.Get(gw.URL + "/passive")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	if hits := atomic.LoadInt32(&failed); hits != 3 {
+		t.Fatalf("expected the failing server to be ejected after 3 failures, got %d hits", hits)
+	}
+	if hits := atomic.LoadInt32(&ok); hits != 9 {
+		t.Fatalf("expected the healthy server to take the remaining traffic, got %d hits", hits)
+	}
+}
@@ -6,5 +6,7 @@ import (
 
 func (c *core) Plugin(plugin ...plugin.Plugin) Core {
 	c.plugins = append(c.plugins, plugin...)
+	// kept by the generations built on reload
+	c.shared.plugins = append(c.shared.plugins, plugin...)
 	return c
 }
@@ -1,15 +1,27 @@
 package core
 
 import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
 	"github.com/go-zoox/api-gateway/core/loadbalancer"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+	"github.com/go-zoox/api-gateway/plugin/auth"
 	"github.com/go-zoox/api-gateway/plugin/baseuri"
+	"github.com/go-zoox/api-gateway/plugin/clientcert"
 	"github.com/go-zoox/api-gateway/plugin/cors"
 	"github.com/go-zoox/api-gateway/plugin/httpcache"
 	"github.com/go-zoox/api-gateway/plugin/ippolicy"
 	"github.com/go-zoox/api-gateway/plugin/jsonaudit"
 	"github.com/go-zoox/api-gateway/plugin/ratelimit"
+	"github.com/go-zoox/api-gateway/requestid"
+	"github.com/go-zoox/api-gateway/router"
 	"github.com/go-zoox/kv"
 	"github.com/go-zoox/kv/redis"
+	"github.com/go-zoox/logger"
 )
 
 func (c *core) prepare() error {
@@ -18,11 +30,75 @@ func (c *core) prepare() error {
 		return err
 	}
 
+	// prepare https certificates
+	if err := c.prepareTLS(); err != nil {
+		return err
+	}
+
 	// prepare load balancer manager
 	if err := c.prepareLoadBalancer(); err != nil {
 		return err
 	}
 
+	// prepare tracing exporter
+	if err := c.prepareTracing(); err != nil {
+		return err
+	}
+
+	// prepare access log output
+	if err := c.prepareAccessLog(); err != nil {
+		return err
+	}
+
+	return c.prepareGeneration()
+}
+
+// prepareGeneration prepares what a configuration reload rebuilds: the route checks,
+// upstream TLS and plugins of c.app.
+func (c *core) prepareGeneration() error {
+	// routes that would only fail at request time
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	// routes are compiled once per generation, so a reload takes effect at once
+	matcher, err := router.Compile(c.cfg)
+	if err != nil {
+		return err
+	}
+	c.matcher = matcher
+
+	if err := c.checkConflicts(); err != nil {
+		return err
+	}
+
+	// load upstream tls certificates, so invalid files fail at startup
+	if err := c.prepareUpstreamTLS(); err != nil {
+		return err
+	}
+
+	// request logger, first so every middleware logs with the request ID
+	c.app.Use(logRequestID)
+
+	// request metrics, outermost so every answer is counted
+	c.prepareMetrics()
+
+	// server spans, ahead of the plugins so their outcome is recorded
+	if c.tracer.Enabled() {
+		c.app.Use(c.traceRequest)
+	}
+
+	// access log, ahead of the plugins so the requests they answer are logged too
+	if c.shouldEnableAccessLog() {
+		c.app.Use(c.logAccess)
+	}
+
+	// gRPC status for calls rejected by the gateway, registered ahead of the plugin middlewares
+	c.app.Use(grpcErrors)
+
+	// route of the request, matched once for the plugins and the proxy
+	c.app.Use(c.matchRoute)
+
 	// prepare plugins
 	if err := c.preparePlugins(); err != nil {
 		return err
@@ -31,27 +107,321 @@ func (c *core) prepare() error {
 	return nil
 }
 
+// validate rejects route settings that would otherwise only fail when a request is matched.
+func (c *core) validate() error {
+	if g := c.cfg.RequestID.EffectiveGenerator(); !slices.Contains(requestid.Generators, g) {
+		return fmt.Errorf("request_id: unsupported generator: %s", g)
+	}
+
+	if admin := c.cfg.Admin; admin.Port != 0 && !admin.Protected() {
+		return fmt.Errorf("admin: port %d requires a token or a username and a password", admin.Port)
+	} else if admin.Username != "" && admin.Password == "" {
+		return fmt.Errorf("admin: username %s requires a password", admin.Username)
+	}
+
+	if mode := c.cfg.Router.EffectiveMode(); !slices.Contains(router.Modes, mode) {
+		return fmt.Errorf("router: unsupported mode: %s", mode)
+	}
+	if policy := c.cfg.Router.EffectiveConflicts(); !slices.Contains(router.ConflictPolicies, policy) {
+		return fmt.Errorf("router: unsupported conflicts: %s", policy)
+	}
+
+	hosts := map[string]bool{}
+	for _, h := range c.cfg.Hosts {
+		if err := router.ValidateHost(h.Host); err != nil {
+			return fmt.Errorf("hosts: %s", err)
+		}
+		name := router.Hostname(h.Host)
+		if hosts[name] {
+			return fmt.Errorf("hosts: duplicate host: %s", h.Host)
+		}
+		hosts[name] = true
+		if h.Backend.Normalize() == nil {
+			return fmt.Errorf("hosts: %s requires a backend service", h.Host)
+		}
+	}
+
+	for _, r := range c.cfg.Routes {
+		for _, host := range r.Hosts {
+			if err := router.ValidateHost(host); err != nil {
+				return fmt.Errorf("route %s: %s", r.Name, err)
+			}
+		}
+
+		switch r.PathType {
+		case "", "prefix", "exact":
+		case "regex":
+			if _, err := regexp.Compile(r.Path); err != nil {
+				return fmt.Errorf("route %s: invalid path regex: %s", r.Name, err)
+			}
+		case "param":
+			if err := router.ValidatePattern(r.Path); err != nil {
+				return fmt.Errorf("route %s: invalid param path: %s", r.Name, err)
+			}
+		default:
+			return fmt.Errorf("route %s: unsupported path type: %s", r.Name, r.PathType)
+		}
+
+		if err := router.ValidateMatch(r.Match); err != nil {
+			return fmt.Errorf("route %s: invalid match: %s", r.Name, err)
+		}
+
+		if err := validateSplit(r); err != nil {
+			return fmt.Errorf("route %s: %s", r.Name, err)
+		}
+
+		if r.Mirror.Enable && r.Mirror.Backend.Normalize() == nil {
+			return fmt.Errorf("route %s: mirror requires a backend service", r.Name)
+		}
+	}
+
+	return nil
+}
+
+// checkConflicts logs the routes that can never match and the duplicate route names, or
+// refuses them with router.conflicts set to reject.
+func (c *core) checkConflicts() error {
+	conflicts := c.matcher.Conflicts()
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	if c.cfg.Router.EffectiveConflicts() == router.ConflictsReject {
+		messages := make([]string, len(conflicts))
+		for i, conflict := range conflicts {
+			messages[i] = conflict.String()
+		}
+		return fmt.Errorf("router: %s", strings.Join(messages, "; "))
+	}
+
+	for _, conflict := range conflicts {
+		logger.Warn("router: %s", conflict)
+	}
+	return nil
+}
+
+// validateSplit rejects the backends of a split that could not serve its requests.
+func validateSplit(r route.Route) error {
+	if len(r.Backends) == 0 {
+		return nil
+	}
+	if r.Backend.Normalize() != nil {
+		return fmt.Errorf("backend and backends are exclusive")
+	}
+
+	names := map[string]bool{}
+	total := int64(0)
+	for _, b := range r.Backends {
+		if b.Name == "" {
+			return fmt.Errorf("split backend without a name")
+		}
+		if names[b.Name] {
+			return fmt.Errorf("duplicate split backend: %s", b.Name)
+		}
+		names[b.Name] = true
+
+		if b.Weight < 0 {
+			return fmt.Errorf("split backend %s: negative weight", b.Name)
+		}
+		total += b.Weight
+
+		if backend := b.Backend(); backend.Normalize() == nil {
+			return fmt.Errorf("split backend %s requires a service", b.Name)
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("split backends need a weight above 0")
+	}
+
+	return nil
+}
+
+func (c *core) prepareTLS() error {
+	if c.cfg.HTTPS.Port == 0 {
+		return nil
+	}
+
+	if len(c.cfg.SSL) == 0 && !c.cfg.HTTPS.ACME.Enable {
+		return fmt.Errorf("https requires at least one ssl certificate or acme")
+	}
+
+	certificates, err := newCertificateStore(c.cfg.SSL)
+	if err != nil {
+		return err
+	}
+	c.certificates = certificates
+
+	if c.cfg.HTTPS.ACME.Enable {
+		manager, err := newACMEManager(c.cfg.HTTPS.ACME, c.app.Cache())
+		if err != nil {
+			return err
+		}
+		if c.cfg.HTTPS.ACME.Storage == "cache" && c.cfg.Cache.Host == "" {
+			logger.Warn("acme: the cache storage is in memory without redis, so the account key and certificates are lost on restart and obtained again, which soon hits the CA rate limits; use the dir storage or configure cache.host")
+		}
+		c.acme = manager
+	}
+
+	tlsConfig, err := newTLSConfig(c.cfg.HTTPS, c.getCertificate)
+	if err != nil {
+		return err
+	}
+	c.tlsConfig = tlsConfig
+
+	return nil
+}
+
 func (c *core) prepareLoadBalancer() error {
 	// Initialize load balancer manager
 	c.lbManager = loadbalancer.NewManager()
 
 	// Start health checks for all backends
+	return c.lbManager.SyncHealthChecks(c.backends(), c.upstreamTLS)
+}
+
+// backends returns the normalized backends of the configuration: the default backend, the
+// default backends of the hosts, then the routes' and the backends they split between.
+func (c *core) backends() []*route.NormalizedBackend {
+	backends := []*route.NormalizedBackend{}
+
 	// Default backend
 	if c.cfg.Backend.Service.Name != "" || len(c.cfg.Backend.Service.Servers) > 0 {
-		normalizedBackend := c.cfg.Backend.Normalize()
-		if normalizedBackend != nil {
-			if err := c.lbManager.StartHealthCheck(normalizedBackend); err != nil {
-				return err
-			}
+		if normalizedBackend := c.cfg.Backend.Normalize(); normalizedBackend != nil {
+			backends = append(backends, normalizedBackend)
+		}
+	}
+
+	// Host default backends
+	for _, h := range c.cfg.Hosts {
+		if normalizedBackend := h.Backend.Normalize(); normalizedBackend != nil {
+			backends = append(backends, normalizedBackend)
 		}
 	}
 
 	// Route backends
 	for _, route := range c.cfg.Routes {
-		normalizedBackend := route.Backend.Normalize()
-		if normalizedBackend != nil {
-			if err := c.lbManager.StartHealthCheck(normalizedBackend); err != nil {
-				return err
+		if normalizedBackend := route.Backend.Normalize(); normalizedBackend != nil {
+			backends = append(backends, normalizedBackend)
+		}
+		for _, split := range route.Backends {
+			backend := split.Backend()
+			if normalizedBackend := backend.Normalize(); normalizedBackend != nil {
+				backends = append(backends, normalizedBackend)
+			}
+		}
+		if route.Mirror.Enable {
+			if normalizedBackend := route.Mirror.Backend.Normalize(); normalizedBackend != nil {
+				backends = append(backends, normalizedBackend)
+			}
+		}
+	}
+
+	return backends
+}
+
+// backendGroup groups the normalized backends of the routes sharing a set of servers.
+type backendGroup struct {
+	id     string
+	routes []string
+	// backends follow the routes; the first one is health checked
+	backends []*route.NormalizedBackend
+}
+
+// servers returns the server with the given ID in each of the backends.
+func (b *backendGroup) servers(serverID string) []*service.Server {
+	servers := []*service.Server{}
+	for _, backend := range b.backends {
+		for _, server := range backend.Servers {
+			if server.ID() == serverID {
+				servers = append(servers, server)
+			}
+		}
+	}
+	return servers
+}
+
+// backendGroups returns the backends of the configuration, in the order of backends().
+func (c *core) backendGroups() []*backendGroup {
+	names := []string{}
+	if c.cfg.Backend.Service.Name != "" || len(c.cfg.Backend.Service.Servers) > 0 {
+		names = append(names, "default")
+	}
+	for _, rt := range router.HostDefaults(c.cfg) {
+		if rt.Backend.Service.Name != "" || len(rt.Backend.Service.Servers) > 0 {
+			names = append(names, rt.Name)
+		}
+	}
+	for _, rt := range c.cfg.Routes {
+		if rt.Backend.Service.Name != "" || len(rt.Backend.Service.Servers) > 0 {
+			names = append(names, rt.Name)
+		}
+		for _, split := range rt.Backends {
+			if split.Service.Name != "" || len(split.Service.Servers) > 0 {
+				names = append(names, rt.Name+"/"+split.Name)
+			}
+		}
+		if mirror := rt.Mirror.Backend.Service; rt.Mirror.Enable && (mirror.Name != "" || len(mirror.Servers) > 0) {
+			names = append(names, rt.Name+"/mirror")
+		}
+	}
+
+	list := []*backendGroup{}
+	byID := map[string]*backendGroup{}
+	for i, backend := range c.backends() {
+		id := loadbalancer.BackendID(backend)
+		b, exists := byID[id]
+		if !exists {
+			b = &backendGroup{id: id}
+			byID[id] = b
+			list = append(list, b)
+		}
+		b.routes = append(b.routes, names[i])
+		b.backends = append(b.backends, backend)
+	}
+	return list
+}
+
+func (c *core) backendGroup(id string) *backendGroup {
+	for _, backend := range c.backendGroups() {
+		if backend.id == id {
+			return backend
+		}
+	}
+	return nil
+}
+
+func (c *core) prepareUpstreamTLS() error {
+	names := []string{"default backend"}
+	backends := []*route.Backend{&c.cfg.Backend}
+	for i := range c.cfg.Hosts {
+		names = append(names, "host "+c.cfg.Hosts[i].Host)
+		backends = append(backends, &c.cfg.Hosts[i].Backend)
+	}
+	for i := range c.cfg.Routes {
+		rt := &c.cfg.Routes[i]
+		names = append(names, "route "+rt.Name)
+		backends = append(backends, &rt.Backend)
+		for j := range rt.Backends {
+			backend := rt.Backends[j].Backend()
+			names = append(names, "route "+rt.Name+" backend "+rt.Backends[j].Name)
+			backends = append(backends, &backend)
+		}
+		if rt.Mirror.Enable {
+			names = append(names, "route "+rt.Name+" mirror")
+			backends = append(backends, &rt.Mirror.Backend)
+		}
+	}
+
+	for i, backend := range backends {
+		normalizedBackend := backend.Normalize()
+		if normalizedBackend == nil {
+			continue
+		}
+
+		for _, server := range normalizedBackend.Servers {
+			effective := server.GetEffectiveConfig(normalizedBackend.BaseConfig)
+			if _, err := c.upstreamTLS.Get(effective.TLS); err != nil {
+				return fmt.Errorf("%s: server %s: %s", names[i], server.ID(), err)
 			}
 		}
 	}
@@ -112,11 +482,22 @@ func (c *core) preparePluginsBuildin() error {
 		c.plugins = append(c.plugins, ippolicy.New())
 	}
 
+	// client certificates (middleware + no-op OnRequest/OnResponse)
+	if c.shouldEnableClientCert() {
+		c.plugins = append(c.plugins, clientcert.New())
+	}
+
 	// CORS (middleware + OnResponse)
 	if c.shouldEnableCORS() {
 		c.plugins = append(c.plugins, cors.New())
 	}
 
+	// authentication (before rate limiting and the HTTP cache, so cached responses are not
+	// served to unauthenticated clients)
+	if c.shouldEnableAuth() {
+		c.plugins = append(c.plugins, auth.New())
+	}
+
 	// rate limit
 	if c.shouldEnableRateLimit() {
 		c.plugins = append(c.plugins, ratelimit.New())
@@ -135,6 +516,11 @@ func (c *core) preparePluginsBuildin() error {
 	return nil
 }
 
+// shouldEnableAuth is true when a service or a server has an auth type.
+func (c *core) shouldEnableAuth() bool {
+	return len(auth.Configs(c.cfg)) > 0
+}
+
 // shouldEnableHTTPCache is true when global http_cache is enabled or any route enables it.
 func (c *core) shouldEnableHTTPCache() bool {
 	if c.cfg.HTTPCache.Enable {
@@ -173,6 +559,18 @@ func (c *core) shouldEnableIPPolicy() bool {
 	return false
 }
 
+func (c *core) shouldEnableClientCert() bool {
+	if c.cfg.ClientCert.Enable {
+		return true
+	}
+	for _, r := range c.cfg.Routes {
+		if r.ClientCert.Enable {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *core) shouldEnableCORS() bool {
 	if c.cfg.CORS.Enable {
 		return true
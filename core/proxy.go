@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-zoox/api-gateway/requestid"
+	"github.com/go-zoox/proxy"
+	"github.com/go-zoox/zoox"
+	"github.com/go-zoox/zoox/middleware"
+)
+
+// proxyConfig extends middleware.ProxyConfig with the upstream settings that
+// zoox's Proxy middleware does not expose.
+type proxyConfig struct {
+	middleware.ProxyConfig
+
+	// Transport is used to reach the upstream; nil means http.DefaultTransport.
+	Transport http.RoundTripper
+
+	// Timeout caps the whole proxied exchange; zero means no deadline.
+	Timeout time.Duration
+}
+
+// proxyMiddleware mirrors middleware.Proxy, but honors proxyConfig.Transport and proxyConfig.Timeout.
+func proxyMiddleware(fn func(ctx *zoox.Context, cfg *proxyConfig) (next, stop bool, err error)) zoox.Middleware {
+	return func(ctx *zoox.Context) {
+		cfg := &proxyConfig{}
+		next, stop, err := fn(ctx, cfg)
+		if err != nil {
+			ctx.Logger.Errorf("[proxy] proxy error: %#v", err)
+			if v, ok := err.(*proxy.HTTPError); ok {
+				ctx.HTML(v.Status(), requestid.Message(ctx.Request, v.Error()))
+			} else {
+				ctx.HTML(http.StatusInternalServerError, requestid.Message(ctx.Request, err.Error()))
+			}
+			return
+		}
+
+		if stop {
+			return
+		}
+
+		if next {
+			ctx.Next()
+			return
+		}
+
+		if cfg.Timeout > 0 {
+			reqCtx, cancel := context.WithTimeout(ctx.Request.Context(), cfg.Timeout)
+			defer cancel()
+			ctx.Request = ctx.Request.WithContext(reqCtx)
+		}
+
+		p := proxy.New(&cfg.Config)
+		p.Transport = cfg.Transport
+		zoox.WrapH(p)(ctx)
+	}
+}
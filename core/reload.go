@@ -0,0 +1,227 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/service"
+	"github.com/go-zoox/logger"
+	"github.com/go-zoox/zoox/defaults"
+)
+
+// ErrNoConfigSource is returned when a reload is requested but no configuration file is set.
+var ErrNoConfigSource = errors.New("no configuration file to reload from")
+
+// Reload builds a new generation of the gateway from cfg: routes, plugins and health
+// checks. Once it is ready, new requests are served by it, while requests in flight
+// finish on the previous generation. An invalid cfg is rejected and changes nothing.
+func (c *core) Reload(cfg *config.Config) error {
+	c.shared.reloadMu.Lock()
+	defer c.shared.reloadMu.Unlock()
+
+	previous := c.shared.current.Load()
+	next, err := previous.next(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to reload: %s", err)
+	}
+
+	// health checks follow the new backends: removed ones stop, the others restart
+	// with their new settings and keep the health observed so far. The checks are
+	// shared by the generations, so a failure puts back those of the previous one.
+	if err := next.lbManager.SyncHealthChecks(next.backends(), next.upstreamTLS); err != nil {
+		if rollbackErr := previous.lbManager.SyncHealthChecks(previous.backends(), previous.upstreamTLS); rollbackErr != nil {
+			logger.Error("failed to restore the health checks of generation %d: %s", previous.generation, rollbackErr)
+		}
+		next.closePlugins()
+		return fmt.Errorf("failed to reload: %s", err)
+	}
+
+	c.shared.current.Store(next)
+	next.transports.Use(next.upstreamTLS)
+	go previous.retire(next.cfg.Shutdown.EffectiveTimeout())
+
+	logger.Info("Configuration reloaded (generation %d, %d routes)", next.generation, len(next.cfg.Routes))
+	return nil
+}
+
+// next prepares the generation following c for cfg. Listeners, certificates, the cache
+// and the retry budget are not rebuilt: changes to them are reported and only take effect after a restart.
+func (c *core) next(cfg *config.Config) (*core, error) {
+	if changed := keepStaticConfig(c.cfg, cfg); len(changed) > 0 {
+		logger.Warn("Configuration reload ignores changes to %s, restart to apply them", strings.Join(changed, ", "))
+	}
+
+	n := &core{
+		app: defaults.Default(),
+		//
+		version: c.version,
+		//
+		cfg: cfg,
+		//
+		lbManager:    c.lbManager,
+		transports:   c.transports,
+		upstreamTLS:  service.NewTLSConfigs(),
+		retryBudget:  c.retryBudget,
+		websockets:   c.websockets,
+		mirrors:      c.mirrors,
+		certificates: c.certificates,
+		tlsConfig:    c.tlsConfig,
+		acme:         c.acme,
+		tracer:       c.tracer,
+		accessLog:    c.accessLog,
+		//
+		generation: c.generation + 1,
+		shared:     c.shared,
+	}
+
+	if err := n.prepareCache(); err != nil {
+		return nil, err
+	}
+	if err := n.prepareGeneration(); err != nil {
+		n.closePlugins()
+		return nil, err
+	}
+	// plugins attached with Plugin() are kept
+	n.plugins = append(n.plugins, c.shared.plugins...)
+
+	if err := n.build(); err != nil {
+		n.closePlugins()
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// keepStaticConfig copies the settings a reload cannot apply from the running
+// configuration into cfg, and returns the names of those that changed.
+func keepStaticConfig(running, cfg *config.Config) (changed []string) {
+	if cfg.Port != running.Port {
+		changed = append(changed, "port")
+	}
+	if !reflect.DeepEqual(cfg.HTTPS, running.HTTPS) {
+		changed = append(changed, "https")
+	}
+	if !reflect.DeepEqual(cfg.SSL, running.SSL) {
+		changed = append(changed, "ssl")
+	}
+	if cfg.Cache != running.Cache {
+		changed = append(changed, "cache")
+	}
+	if cfg.Reload != running.Reload {
+		changed = append(changed, "reload")
+	}
+	if cfg.Admin != running.Admin {
+		changed = append(changed, "admin")
+	}
+	if cfg.Metrics != running.Metrics {
+		changed = append(changed, "metrics")
+	}
+	if !reflect.DeepEqual(cfg.Tracing, running.Tracing) {
+		changed = append(changed, "tracing")
+	}
+	if cfg.AccessLog != running.AccessLog {
+		changed = append(changed, "access_log")
+	}
+	if cfg.RetryBudget != running.RetryBudget {
+		changed = append(changed, "retry_budget")
+	}
+
+	cfg.Port = running.Port
+	cfg.HTTPS = running.HTTPS
+	cfg.SSL = running.SSL
+	cfg.Cache = running.Cache
+	cfg.Reload = running.Reload
+	cfg.Admin = running.Admin
+	cfg.Metrics = running.Metrics
+	cfg.Tracing = running.Tracing
+	cfg.AccessLog = running.AccessLog
+	cfg.RetryBudget = running.RetryBudget
+
+	return changed
+}
+
+// retire closes the plugins of a replaced generation once its requests have finished,
+// or after timeout.
+func (c *core) retire(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for c.inflight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			logger.Warn("%d requests of configuration generation %d still in flight after %s", c.inflight.Load(), c.generation, timeout)
+			c.closePlugins()
+			return
+		case <-ticker.C:
+		}
+	}
+
+	c.closePlugins()
+}
+
+// closePlugins releases the resources of the plugins (files, database handles, ...)
+// implementing io.Closer.
+func (c *core) closePlugins() {
+	for _, p := range c.plugins {
+		if closer, ok := p.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logger.Warn("failed to close plugin: %s", err)
+			}
+		}
+	}
+}
+
+// reloadFromSource loads the configuration file again and reloads it.
+func (c *core) reloadFromSource(trigger string) error {
+	c.shared.reloadMu.Lock()
+	load := c.shared.load
+	c.shared.reloadMu.Unlock()
+
+	if load == nil {
+		return ErrNoConfigSource
+	}
+
+	logger.Info("Reloading configuration (%s) ...", trigger)
+	cfg, err := load()
+	if err != nil {
+		return fmt.Errorf("failed to reload: %s", err)
+	}
+
+	return c.Reload(cfg)
+}
+
+// watchConfig reloads the configuration when its file changes, until ctx is done.
+func (c *core) watchConfig(ctx context.Context, interval time.Duration) {
+	path := c.shared.path
+	stamp, _ := statFile(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := statFile(path)
+		if err != nil || current == stamp {
+			continue
+		}
+		stamp = current
+
+		if err := c.reloadFromSource("file changed"); err != nil {
+			logger.Error("%s", err)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return res.StatusCode, string(body)
+}
+
+func TestReload(t *testing.T) {
+	a := newNamedUpstream(t, "a")
+	b := newNamedUpstream(t, "b")
+
+	gw, _, url := serveTestGateway(t, &config.Config{
+		Port: 8080,
+		Routes: []route.Route{
+			{Name: "api", Path: "/api", Backend: route.Backend{Service: testService(t, a)}},
+		},
+	})
+
+	if _, body := get(t, url+"/api"); body != "a" {
+		t.Fatalf("expected upstream a, got %q", body)
+	}
+
+	err := gw.Reload(&config.Config{
+		Port: 9090,
+		Routes: []route.Route{
+			{Name: "api", Path: "/api", Backend: route.Backend{Service: testService(t, b)}},
+			{Name: "new", Path: "/new", Backend: route.Backend{Service: testService(t, a)}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, body := get(t, url+"/api"); body != "b" {
+		t.Fatalf("expected the reloaded route to upstream b, got %q", body)
+	}
+	if _, body := get(t, url+"/new"); body != "a" {
+		t.Fatalf("expected the new route, got %q", body)
+	}
+
+	current := gw.shared.current.Load()
+	if current.generation != 2 {
+		t.Fatalf("expected generation 2, got %d", current.generation)
+	}
+	// listeners are not rebuilt, so their settings are kept
+	if current.cfg.Port != 8080 {
+		t.Fatalf("expected the port to be kept, got %d", current.cfg.Port)
+	}
+}
+
+func TestReloadInvalidConfig(t *testing.T) {
+	a := newNamedUpstream(t, "a")
+
+	gw, _, url := serveTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{Name: "api", Path: "/api", Backend: route.Backend{Service: testService(t, a)}},
+		},
+	})
+
+	invalid := []route.Route{
+		{Name: "regex", Path: "^/api/(", PathType: "regex"},
+		{Name: "type", Path: "/api", PathType: "glob"},
+	}
+	for _, r := range invalid {
+		if err := gw.Reload(&config.Config{Routes: []route.Route{r}}); err == nil {
+			t.Errorf("expected route %s to be rejected", r.Name)
+		}
+	}
+
+	if gw.shared.current.Load() != gw {
+		t.Fatal("expected the running configuration to be kept")
+	}
+	if _, body := get(t, url+"/api"); body != "a" {
+		t.Fatalf("expected upstream a, got %q", body)
+	}
+}
+
+func TestReloadStaticConfig(t *testing.T) {
+	a := newNamedUpstream(t, "a")
+	routes := []route.Route{
+		{Name: "api", Path: "/api", Backend: route.Backend{Service: testService(t, a)}},
+	}
+
+	gw, _, _ := serveTestGateway(t, &config.Config{
+		RetryBudget: config.RetryBudget{Percent: 20},
+		Routes:      routes,
+	})
+
+	next := &config.Config{RetryBudget: config.RetryBudget{Percent: 50}, Routes: routes}
+	if changed := keepStaticConfig(gw.cfg, next); !slices.Contains(changed, "retry_budget") {
+		t.Errorf("expected the retry budget change to be reported, got %v", changed)
+	}
+
+	if err := gw.Reload(&config.Config{RetryBudget: config.RetryBudget{Percent: 50}, Routes: routes}); err != nil {
+		t.Fatal(err)
+	}
+	if percent := gw.shared.current.Load().cfg.RetryBudget.Percent; percent != 20 {
+		t.Errorf("expected the retry budget to be kept until a restart, got %v", percent)
+	}
+}
+
+func TestReloadInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "slow")
+	})
+	b := newNamedUpstream(t, "b")
+
+	gw, _, url := serveTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{Name: "api", Path: "/api", Backend: route.Backend{Service: testService(t, slow)}},
+		},
+	})
+
+	inflight := make(chan string, 1)
+	go func() {
+		_, body := get(t, url+"/api")
+		inflight <- body
+	}()
+	<-started
+
+	err := gw.Reload(&config.Config{
+		Routes: []route.Route{
+			{Name: "api", Path: "/api", Backend: route.Backend{Service: testService(t, b)}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, body := get(t, url+"/api"); body != "b" {
+		t.Fatalf("expected new requests on the new generation, got %q", body)
+	}
+
+	close(release)
+	if body := <-inflight; body != "slow" {
+		t.Fatalf("expected the in-flight request to finish on the previous generation, got %q", body)
+	}
+	if n := gw.inflight.Load(); n != 0 {
+		t.Fatalf("expected no request left on the previous generation, got %d", n)
+	}
+}
+
+func TestReloadFromSource(t *testing.T) {
+	a := newNamedUpstream(t, "a")
+	b := newNamedUpstream(t, "b")
+
+	gw, _, url := serveTestGateway(t, &config.Config{
+		Admin: config.Admin{Token: "secret"},
+		Routes: []route.Route{
+			{Name: "api", Path: "/api", Backend: route.Backend{Service: testService(t, a)}},
+		},
+	})
+
+	admin := newTestServer(t, gw.newAdminServer("").Handler.ServeHTTP)
+
+	reload := func(token string) (int, map[string]any) {
+		req, _ := http.NewRequest(http.MethodPost, admin.URL+"/reload", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var body map[string]any
+		_ = json.NewDecoder(res.Body).Decode(&body)
+		return res.StatusCode, body
+	}
+
+	if status, _ := reload("secret"); status != http.StatusConflict {
+		t.Fatalf("expected 409 without a configuration file, got %d", status)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("a"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var backend atomic.Pointer[httptest.Server]
+	backend.Store(a)
+	gw.SetConfigSource(path, func() (*config.Config, error) {
+		return &config.Config{
+			Routes: []route.Route{
+				{Name: "api", Path: "/api", Backend: route.Backend{Service: testService(t, backend.Load())}},
+			},
+		}, nil
+	})
+
+	if status, _ := reload("wrong"); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with a wrong token, got %d", status)
+	}
+	status, body := reload("secret")
+	if status != http.StatusOK || body["generation"] != float64(2) {
+		t.Fatalf("expected generation 2, got %d %v", status, body)
+	}
+
+	// the watched file changes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go gw.watchConfig(ctx, 20*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	backend.Store(b)
+	if err := os.WriteFile(path, []byte("b changed"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, body := get(t, url+"/api"); body == "b" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the configuration to be reloaded when the file changes")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,31 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/go-zoox/api-gateway/requestid"
+	"github.com/go-zoox/zoox"
+)
+
+// identify keeps the request ID sent by the client, or generates one, then forwards it
+// upstream, echoes it in the response and carries it in the request context.
+func (c *core) identify(w http.ResponseWriter, r *http.Request) *http.Request {
+	header := c.cfg.RequestID.EffectiveHeader()
+
+	id := r.Header.Get(header)
+	if !requestid.Valid(id) {
+		id = requestid.Generate(c.cfg.RequestID.EffectiveGenerator())
+		r.Header.Set(header, id)
+	}
+	// zoox reads its own header for ctx.RequestID() and generates an ID without it
+	r.Header.Set(requestid.Header, id)
+
+	w.Header().Set(header, id)
+	return requestid.WithID(r, id)
+}
+
+// logRequestID ends the log lines of the request with its ID.
+func logRequestID(ctx *zoox.Context) {
+	ctx.Logger = requestid.Logger(ctx.Request)
+	ctx.Next()
+}
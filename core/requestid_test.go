@@ -0,0 +1,127 @@
+package core
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+	"github.com/google/uuid"
+)
+
+// newEchoIDUpstream answers with the request ID it received, in its body and headers.
+func newEchoIDUpstream(t *testing.T, header string) *httptest.Server {
+	t.Helper()
+
+	return newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(header, r.Header.Get(header))
+		_, _ = io.WriteString(w, r.Header.Get(header))
+	})
+}
+
+func getWithHeader(t *testing.T, url, header, value string) (*http.Response, string) {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if value != "" {
+		req.Header.Set(header, value)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return res, string(body)
+}
+
+func TestRequestID(t *testing.T) {
+	upstream := newEchoIDUpstream(t, "X-Request-Id")
+	_, _, url := serveTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{Name: "api", Path: "/api", Backend: route.Backend{Service: testService(t, upstream)}},
+		},
+	})
+
+	// kept from the client
+	res, body := getWithHeader(t, url+"/api", "X-Request-Id", "client-1")
+	if body != "client-1" {
+		t.Errorf("expected the client ID to be forwarded, got %q", body)
+	}
+	if ids := res.Header.Values("X-Request-Id"); len(ids) != 1 || ids[0] != "client-1" {
+		t.Errorf("expected the client ID to be echoed once, got %v", ids)
+	}
+
+	// generated
+	res, body = getWithHeader(t, url+"/api", "X-Request-Id", "")
+	id, err := uuid.Parse(res.Header.Get("X-Request-Id"))
+	if err != nil || id.Version() != 7 {
+		t.Fatalf("expected a UUIDv7, got %q", res.Header.Get("X-Request-Id"))
+	}
+	if body != id.String() {
+		t.Errorf("expected the generated ID to be forwarded, got %q", body)
+	}
+
+	// replaced when it cannot be trusted
+	res, _ = getWithHeader(t, url+"/api", "X-Request-Id", strings.Repeat("x", 200))
+	if _, err := uuid.Parse(res.Header.Get("X-Request-Id")); err != nil {
+		t.Errorf("expected an oversized ID to be replaced, got %q", res.Header.Get("X-Request-Id"))
+	}
+}
+
+func TestRequestIDCustomHeader(t *testing.T) {
+	upstream := newEchoIDUpstream(t, "X-Correlation-Id")
+	_, _, url := serveTestGateway(t, &config.Config{
+		RequestID: config.RequestID{Header: "X-Correlation-Id", Generator: "ulid"},
+		Routes: []route.Route{
+			{Name: "api", Path: "/api", Backend: route.Backend{Service: testService(t, upstream)}},
+		},
+	})
+
+	res, body := getWithHeader(t, url+"/api", "X-Correlation-Id", "")
+	id := res.Header.Get("X-Correlation-Id")
+	if len(id) != 26 {
+		t.Fatalf("expected a ULID, got %q", id)
+	}
+	if body != id {
+		t.Errorf("expected the generated ID to be forwarded, got %q", body)
+	}
+}
+
+func TestRequestIDErrorBody(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "down",
+				Path:    "/down",
+				Backend: route.Backend{Service: service.Service{Name: "127.0.0.1", Port: int64(port)}},
+			},
+		},
+	})
+
+	res, body := getWithHeader(t, url+"/down", "X-Request-Id", "client-2")
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", res.StatusCode)
+	}
+	if !strings.HasSuffix(body, "(request_id: client-2)") {
+		t.Errorf("expected the request ID in the error body, got %q", body)
+	}
+}
+
+func TestRequestIDGenerator(t *testing.T) {
+	if _, err := New("test", &config.Config{RequestID: config.RequestID{Generator: "snowflake"}}); err == nil {
+		t.Error("expected an unsupported generator to be rejected")
+	}
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/service"
+	"github.com/go-zoox/api-gateway/requestid"
+)
+
+// retryTransport re-sends failed upstream requests to another server of the backend,
+// within the service retry policy and the gateway-wide retry budget.
+type retryTransport struct {
+	budget *retryBudget
+
+	upstream *upstream
+	policy   service.Retry
+
+	// body is the buffered request body, replayed on every attempt
+	body []byte
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tried := make(map[string]bool)
+	attempts := int(t.policy.Attempts)
+
+	for attempt := 1; ; attempt++ {
+		tried[t.upstream.server.ID()] = true
+
+		if len(t.body) > 0 {
+			req.Body = io.NopCloser(bytes.NewReader(t.body))
+			req.ContentLength = int64(len(t.body))
+		}
+
+		res, err := t.upstream.RoundTrip(req)
+		if attempt >= attempts || !t.shouldRetry(res, err) {
+			return res, err
+		}
+
+		if !t.budget.Withdraw() {
+			requestid.Logger(req).Warnf("[retry] retry budget exhausted, giving up on %s after %d attempt(s)", t.upstream.server.ID(), attempt)
+			return res, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = res.Status
+			drainBody(res.Body)
+		}
+
+		select {
+		case <-time.After(t.policy.Backoff(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		previous := t.upstream.server
+		headers := t.upstream.service.Request.Headers
+
+		t.upstream.switchTo(t.upstream.next(req, tried))
+
+		req = req.Clone(req.Context())
+		for k := range headers {
+			req.Header.Del(k)
+		}
+		t.upstream.apply(req)
+
+		requestid.Logger(req).Warnf("[retry] attempt %d/%d: %s failed (%s), retrying on %s", attempt+1, attempts, previous.ID(), reason, t.upstream.server.ID())
+	}
+}
+
+func (t *retryTransport) shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		return t.policy.RetryNetworkErrors() && isRetryableError(err)
+	}
+	return t.policy.RetryStatus(res.StatusCode)
+}
+
+// isRetryableError reports whether err is a connection-level upstream failure that may
+// succeed on another server. Client cancellations and deadlines are never retried.
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+func drainBody(body io.ReadCloser) {
+	if body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 64*1024))
+	body.Close()
+}
+
+// bufferRequestBody reads the request body into memory so it can be replayed on retries.
+// It returns false, leaving the body intact, when the body is larger than max.
+func bufferRequestBody(req *http.Request, max int64) ([]byte, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(req.Body, max+1))
+	if err != nil {
+		req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(raw), errReader{err}))
+		return nil, false
+	}
+
+	if int64(len(raw)) > max {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(raw), req.Body), req.Body}
+		return nil, false
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(raw))
+	return raw, true
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// retryBudget limits retries to a percentage of the requests seen in a sliding window,
+// plus a minimum number of retries per second that is always allowed.
+type retryBudget struct {
+	percent      float64
+	minPerSecond int64
+	window       int64
+
+	buckets []retryBudgetBucket
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+type retryBudgetBucket struct {
+	second   int64
+	requests int64
+	retries  int64
+}
+
+func newRetryBudget(cfg config.RetryBudget) *retryBudget {
+	window := cfg.EffectiveWindow()
+	return &retryBudget{
+		percent:      cfg.EffectivePercent(),
+		minPerSecond: cfg.EffectiveMinRetriesPerSecond(),
+		window:       window,
+		buckets:      make([]retryBudgetBucket, window),
+		now:          time.Now,
+	}
+}
+
+// RecordRequest counts a proxied request towards the budget.
+func (b *retryBudget) RecordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bucket(b.now().Unix()).requests++
+}
+
+// Withdraw reserves one retry, reporting false when the budget is exhausted.
+func (b *retryBudget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now().Unix()
+
+	var requests, retries int64
+	for _, bucket := range b.buckets {
+		if bucket.second > now-b.window {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+
+	allowed := int64(float64(requests) * b.percent / 100)
+	if min := b.minPerSecond * b.window; allowed < min {
+		allowed = min
+	}
+	if retries >= allowed {
+		return false
+	}
+
+	b.bucket(now).retries++
+	return true
+}
+
+func (b *retryBudget) bucket(second int64) *retryBudgetBucket {
+	bucket := &b.buckets[second%b.window]
+	if bucket.second != second {
+		*bucket = retryBudgetBucket{second: second}
+	}
+	return bucket
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// testServer returns a backend server entry pointing at the test upstream.
+func testServer(t *testing.T, upstream *httptest.Server) *service.Server {
+	t.Helper()
+
+	host, port, err := net.SplitHostPort(upstream.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.ParseInt(port, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &service.Server{Name: host, Port: p}
+}
+
+// newCountingUpstream answers every request with status and counts the hits.
+func newCountingUpstream(t *testing.T, status int, hits *int32) *httptest.Server {
+	t.Helper()
+
+	return newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, strconv.Itoa(status)+":"+string(body))
+	})
+}
+
+func newRetryGateway(t *testing.T, retry service.Retry, servers ...*service.Server) *httptest.Server {
+	t.Helper()
+
+	backendServers := make([]service.Server, len(servers))
+	for i, server := range servers {
+		backendServers[i].Name = server.Name
+		backendServers[i].Port = server.Port
+	}
+
+	return newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name: "retry",
+				Path: "/retry",
+				Backend: route.Backend{
+					Service: service.Service{
+						Protocol:  "http",
+						Algorithm: "round-robin",
+						Servers:   backendServers,
+						Retry:     retry,
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestRetryOnAnotherServer(t *testing.T) {
+	var failed, ok int32
+	bad := newCountingUpstream(t, http.StatusServiceUnavailable, &failed)
+	good := newCountingUpstream(t, http.StatusOK, &ok)
+
+	gw := newRetryGateway(t, service.Retry{Attempts: 2, BackoffBaseMs: 1}, testServer(t, bad), testServer(t, good))
+
+	// POST is not idempotent and is not retried by default
+	statuses := map[int]int{}
+	for i := 0; i < 4; i++ {
+		res, err := http.Post(gw.URL+"/retry", "text/plain", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		statuses[res.StatusCode]++
+	}
+	if statuses[http.StatusServiceUnavailable] == 0 {
+		t.Fatalf("expected POST failures to reach the client, got %v", statuses)
+	}
+
+	atomic.StoreInt32(&failed, 0)
+	for i := 0; i < 4; i++ {
+		req, _ := http.NewRequest(http.MethodPut, gw.URL+"/retry", strings.NewReader("payload"))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK || string(body) != "200:payload" {
+			t.Fatalf("expected 200 with replayed body, got %d %q", res.StatusCode, body)
+		}
+	}
+	if atomic.LoadInt32(&failed) == 0 {
+		t.Fatalf("expected the failing server to be tried")
+	}
+}
+
+func TestRetryConnectionRefused(t *testing.T) {
+	var ok int32
+	good := newCountingUpstream(t, http.StatusOK, &ok)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	down := testServer(t, closed)
+	closed.Close()
+
+	gw := newRetryGateway(t, service.Retry{Attempts: 2, BackoffBaseMs: 1}, down, testServer(t, good))
+
+	for i := 0; i < 4; i++ {
+		res, err := http.Get(gw.URL + "/retry")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, res.StatusCode)
+		}
+	}
+}
+
+func TestRetryDisabled(t *testing.T) {
+	var failed, ok int32
+	bad := newCountingUpstream(t, http.StatusServiceUnavailable, &failed)
+	good := newCountingUpstream(t, http.StatusOK, &ok)
+
+	gw := newRetryGateway(t, service.Retry{}, testServer(t, bad), testServer(t, good))
+
+	statuses := map[int]int{}
+	for i := 0; i < 4; i++ {
+		res, err := http.Get(gw.URL + "/retry")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		statuses[res.StatusCode]++
+	}
+
+	if statuses[http.StatusServiceUnavailable] == 0 {
+		t.Fatalf("expected failures to reach the client without retries, got %v", statuses)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Unix(1000, 0)
+	budget := newRetryBudget(config.RetryBudget{Percent: 50, MinRetriesPerSecond: -1, Window: 10})
+	budget.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		budget.RecordRequest()
+	}
+
+	if !budget.Withdraw() || !budget.Withdraw() {
+		t.Fatalf("expected 2 retries to fit 50%% of 4 requests")
+	}
+	if budget.Withdraw() {
+		t.Fatalf("expected the budget to be exhausted")
+	}
+
+	// requests and retries leave the window
+	now = now.Add(10 * time.Second)
+	if budget.Withdraw() {
+		t.Fatalf("expected no budget without recent requests")
+	}
+
+	budget.RecordRequest()
+	budget.RecordRequest()
+	if !budget.Withdraw() {
+		t.Fatalf("expected the budget to refill in a new window")
+	}
+}
+
+func TestRetryBudgetMinPerSecond(t *testing.T) {
+	budget := newRetryBudget(config.RetryBudget{Percent: 0.0001, MinRetriesPerSecond: 1, Window: 2})
+
+	if !budget.Withdraw() || !budget.Withdraw() {
+		t.Fatalf("expected the minimum retries to be allowed")
+	}
+	if budget.Withdraw() {
+		t.Fatalf("expected the budget to be exhausted")
+	}
+}
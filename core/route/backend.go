@@ -12,8 +12,8 @@ func (b *Backend) Normalize() *NormalizedBackend {
 		servers := make([]*service.Server, 0, len(b.Service.Servers))
 		for i := range b.Service.Servers {
 			server := &b.Service.Servers[i]
-			// Initialize health status to true by default
-			server.SetHealthy(true)
+			// Initialize health status to true by default, keeping health check results
+			server.InitHealthy()
 			servers = append(servers, server)
 		}
 
@@ -147,3 +147,32 @@ func TestBackendGetService(t *testing.T) {
 		}
 	})
 }
+
+func TestBackendNormalizeKeepsHealth(t *testing.T) {
+	backend := &Backend{
+		Service: service.Service{
+			Servers: []service.Server{
+				{Name: "server1.com", Port: 8080},
+				{Name: "server2.com", Port: 8080},
+			},
+		},
+	}
+
+	normalized := backend.Normalize()
+	for _, server := range normalized.Servers {
+		if !server.IsHealthy() {
+			t.Fatalf("服务器 %s 默认应该是健康的", server.ID())
+		}
+	}
+
+	// 健康检查的结果不应该被再次 Normalize() 覆盖
+	normalized.Servers[0].SetHealthy(false)
+
+	normalized = backend.Normalize()
+	if normalized.Servers[0].IsHealthy() {
+		t.Error("再次 Normalize() 不应该重置健康状态")
+	}
+	if !normalized.Servers[1].IsHealthy() {
+		t.Error("其他服务器应该保持健康")
+	}
+}
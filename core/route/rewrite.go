@@ -14,7 +14,7 @@ func (r *Route) Rewrite(path string) string {
 	baseConfig := normalizedBackend.BaseConfig
 
 	if !baseConfig.Request.Path.DisablePrefixRewrite {
-		if r.PathType != "regex" {
+		if r.PathType != "regex" && r.PathType != "param" {
 			if r.PathType == "prefix" && r.Path == "/" {
 				// home should not rewrite
 			} else {
@@ -2,6 +2,7 @@ package route
 
 import (
 	"strings"
+	"time"
 
 	"github.com/go-zoox/api-gateway/core/service"
 )
@@ -15,8 +16,9 @@ type Backend struct {
 type RateLimit struct {
 	Enable    bool              `config:"enable"`
 	Algorithm string            `config:"algorithm,default=token-bucket"` // token-bucket, leaky-bucket, fixed-window
-	KeyType   string            `config:"key_type,default=ip"`            // ip, user, apikey, clientid, header
+	KeyType   string            `config:"key_type,default=ip"`            // ip, user, apikey, clientid, clientcert, header, param
 	KeyHeader string            `config:"key_header"`                     // when key_type=header, specify header name
+	KeyParam  string            `config:"key_param"`                      // when key_type=param, specify route param name
 	Limit     int64             `config:"limit"`                          // limit count
 	Window    int64             `config:"window"`                         // time window in seconds
 	Burst     int64             `config:"burst"`                          // burst capacity (only for token-bucket)
@@ -140,17 +142,189 @@ type CORS struct {
 	MaxAge int64 `config:"max_age"`
 }
 
+// WebSocket configures WebSocket connections upgraded on a route. The gateway terminates
+// the client handshake, dials the selected server and relays messages in both directions.
+type WebSocket struct {
+	// IdleTimeout closes connections without messages in either direction for this many seconds (0 = no limit).
+	IdleTimeout int64 `config:"idle_timeout"`
+	// PingInterval pings both peers every this many seconds (0 disables pings).
+	PingInterval int64 `config:"ping_interval"`
+	// PongTimeout closes the connection when a peer does not answer a ping within this many seconds (default 10).
+	PongTimeout int64 `config:"pong_timeout,default=10"`
+	// MaxConnections caps concurrent WebSocket connections on the route (0 = unlimited).
+	MaxConnections int64 `config:"max_connections"`
+}
+
+// ClientCert requires clients to present a TLS client certificate verified against
+// https.client_ca, and forwards their identity upstream. Enable at the global level and/or
+// per route; route settings override the global block for fields that are set.
+type ClientCert struct {
+	Enable bool `config:"enable"`
+	// Identity maps the certificate to the client identity: subject (default, the RFC 2253
+	// distinguished name), cn, or the first dns, email or uri SAN.
+	Identity string `config:"identity,default=subject"`
+	// Header forwards the identity upstream (default X-Client-Cert-Subject).
+	Header string `config:"header,default=X-Client-Cert-Subject"`
+	// PEMHeader forwards the URL-encoded PEM certificate upstream in this header; empty disables it.
+	PEMHeader string `config:"pem_header"`
+	// Message is the response body for requests without a valid certificate (HTTP 401).
+	Message string `config:"message,default=Client certificate required"`
+}
+
+// AccessLog overrides the access log settings for a route.
+type AccessLog struct {
+	// Enable logs the route's requests (true) or not (false); omitted (nil) follows access_log.enable.
+	Enable *bool `config:"enable"`
+	// SampleRate is the fraction of successful requests (status < 400) logged (0.0–1.0];
+	// 0 follows access_log.sample_rate. Failed requests are always logged.
+	SampleRate float64 `config:"sample_rate"`
+}
+
+// Match narrows a route down to the requests with these methods, headers, query
+// parameters and cookies, on top of its path. Every condition must hold; routes sharing a
+// path are then told apart by their conditions, like a canary route for X-Canary: true.
+type Match struct {
+	// Methods lists the accepted methods (empty = any).
+	Methods []string `config:"methods"`
+	// Headers lists conditions on request headers.
+	Headers []MatchCondition `config:"headers"`
+	// Query lists conditions on query parameters.
+	Query []MatchCondition `config:"query"`
+	// Cookies lists conditions on cookies.
+	Cookies []MatchCondition `config:"cookies"`
+}
+
+// MatchCondition is a condition on a header, query parameter or cookie.
+type MatchCondition struct {
+	Name string `config:"name"`
+	// Type is exact (default), regex or present.
+	Type string `config:"type,default=exact"`
+	// Value is the expected value (exact) or a regular expression (regex); present ignores it.
+	Value string `config:"value"`
+}
+
+// IsZero reports whether no condition is configured.
+func (m Match) IsZero() bool {
+	return len(m.Methods) == 0 && len(m.Headers) == 0 && len(m.Query) == 0 && len(m.Cookies) == 0
+}
+
+// EffectiveType returns how the condition is evaluated.
+func (c MatchCondition) EffectiveType() string {
+	if c.Type == "" {
+		return "exact"
+	}
+	return c.Type
+}
+
+// Mirror sends a copy of the requests of a route to another backend, like a new version
+// of a service tested against real traffic. The copies are sent in the background and
+// their responses are discarded, so they never change what the client receives.
+type Mirror struct {
+	Enable bool `config:"enable"`
+	// Backend receives the copies.
+	Backend Backend `config:"backend"`
+	// Percentage is the share of requests mirrored, above 0 and up to 100 (default 100).
+	Percentage float64 `config:"percentage,default=100"`
+	// MaxBodyBytes caps the request body buffered for the copy; requests with larger bodies
+	// are not mirrored (default 1MiB).
+	MaxBodyBytes int64 `config:"max_body_bytes,default=1048576"`
+	// Timeout caps a mirrored exchange in seconds (default 10).
+	Timeout int64 `config:"timeout,default=10"`
+	// MaxInFlight caps the copies in flight on the route; requests above it are not
+	// mirrored (default 100).
+	MaxInFlight int64 `config:"max_in_flight,default=100"`
+}
+
+// EffectivePercentage returns the share of requests mirrored.
+func (m Mirror) EffectivePercentage() float64 {
+	if m.Percentage <= 0 || m.Percentage > 100 {
+		return 100
+	}
+	return m.Percentage
+}
+
+// EffectiveMaxBodyBytes returns the largest request body mirrored.
+func (m Mirror) EffectiveMaxBodyBytes() int64 {
+	if m.MaxBodyBytes <= 0 {
+		return 1024 * 1024
+	}
+	return m.MaxBodyBytes
+}
+
+// EffectiveTimeout returns how long a mirrored exchange may take.
+func (m Mirror) EffectiveTimeout() time.Duration {
+	if m.Timeout <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(m.Timeout) * time.Second
+}
+
+// EffectiveMaxInFlight returns how many copies may be in flight on the route.
+func (m Mirror) EffectiveMaxInFlight() int64 {
+	if m.MaxInFlight <= 0 {
+		return 100
+	}
+	return m.MaxInFlight
+}
+
+// SplitBackend is one of the backends a route splits its requests between, with its own
+// service configuration: protocol, servers, rewrites, headers and auth.
+type SplitBackend struct {
+	// Name identifies the backend in the sticky cookie, metrics and spans, like blue or v2.
+	Name string `config:"name"`
+	// Weight is the share of requests sent to the backend, relative to the others; 0 sends
+	// it none, so it can be drained.
+	Weight  int64           `config:"weight"`
+	Service service.Service `config:"service"`
+}
+
+// Backend returns the backend to proxy the requests of b to.
+func (b SplitBackend) Backend() Backend {
+	return Backend{Service: b.Service}
+}
+
+// Sticky keeps a client on the same backend of a split.
+type Sticky struct {
+	// Cookie is set on the first response with the name of the backend the client was sent
+	// to; later requests carrying it go to the same backend while its weight is above 0.
+	Cookie string `config:"cookie"`
+	// MaxAge is the lifetime of the cookie in seconds; 0 keeps it for the browser session.
+	MaxAge int64 `config:"max_age"`
+	// Header sends the requests with the same value of this header to the same backend,
+	// like X-User-Id.
+	Header string `config:"header"`
+}
+
 type Route struct {
 	Name    string  `config:"name"`
 	Path    string  `config:"path"`
 	Backend Backend `config:"backend"`
-	// PathType is the path type of route, options: prefix, regex
-	PathType  string    `config:"path_type,default=prefix"`
+	// PathType is the path type of route, options: prefix, exact, regex, param (/users/:id/orders/*rest)
+	PathType string `config:"path_type,default=prefix"`
+	// Match adds conditions on the method, headers, query and cookies of the request
+	Match Match `config:"match"`
+	// Hosts restricts the route to requests for these hosts, like api.example.com or
+	// *.example.com (a single label); empty matches every host
+	Hosts []string `config:"hosts"`
+	// Priority orders the matching routes of a request: the highest priority wins, routes
+	// of the same priority keep the order of the router mode
+	Priority  int64     `config:"priority"`
 	RateLimit RateLimit `config:"rate_limit"`
 	JSONAudit JSONAudit `config:"json_audit"`
 	HTTPCache HTTPCache `config:"http_cache"`
 	IPPolicy  IPPolicy  `config:"ip_policy"`
 	CORS      CORS      `config:"cors"`
+	WebSocket WebSocket `config:"websocket"`
+	// ClientCert requires a TLS client certificate on the route
+	ClientCert ClientCert `config:"client_cert"`
+	// AccessLog turns the access log on or off for the route, or samples it differently
+	AccessLog AccessLog `config:"access_log"`
+	// Mirror copies the route's requests to another backend
+	Mirror Mirror `config:"mirror"`
+	// Backends split the route's requests by weight, in place of Backend
+	Backends []SplitBackend `config:"backends"`
+	// Sticky keeps a client on the same backend of Backends
+	Sticky Sticky `config:"sticky"`
 }
 
 // EffectiveJSONAuditProvider returns the normalized sink id: console, file, or http.
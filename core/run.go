@@ -1,7 +1,20 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-zoox/logger"
 )
 
 func (c *core) Run() error {
@@ -9,12 +22,261 @@ func (c *core) Run() error {
 		return err
 	}
 
-	// Stop all health checks when shutting down
-	defer func() {
-		if c.lbManager != nil {
-			c.lbManager.StopAllHealthChecks()
+	// SIGTERM / SIGINT start a graceful shutdown; a second signal terminates at once
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	// SIGHUP reloads the configuration file
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := c.reloadFromSource("SIGHUP"); err != nil {
+					logger.Error("%s", err)
+				}
+			}
 		}
 	}()
 
-	return c.app.Run(fmt.Sprintf(":%d", c.cfg.Port))
+	return c.serve(ctx)
+}
+
+// serve runs the listeners until one of them fails or ctx is done, then shuts the gateway down.
+func (c *core) serve(ctx context.Context) error {
+	servers := []*http.Server{}
+	serves := []func() error{}
+
+	server := c.newServer(fmt.Sprintf(":%d", c.cfg.Port))
+	servers = append(servers, server)
+	serves = append(serves, func() error {
+		logger.Info("Server started at http://0.0.0.0%s", server.Addr)
+		return server.ListenAndServe()
+	})
+
+	if c.tlsConfig != nil {
+		go c.certificates.Watch(c.cfg.HTTPS.EffectiveReloadInterval())
+		defer c.certificates.Stop()
+
+		tlsServer := c.newTLSServer(fmt.Sprintf(":%d", c.cfg.HTTPS.Port))
+		servers = append(servers, tlsServer)
+		serves = append(serves, func() error {
+			logger.Info("Server started at https://0.0.0.0%s", tlsServer.Addr)
+			// certificates come from the TLS config
+			return tlsServer.ListenAndServeTLS("", "")
+		})
+
+		if c.cfg.HTTPS.RedirectPort != 0 {
+			redirectServer := c.newRedirectServer(fmt.Sprintf(":%d", c.cfg.HTTPS.RedirectPort))
+			servers = append(servers, redirectServer)
+			serves = append(serves, func() error {
+				logger.Info("HTTPS redirect started at http://0.0.0.0%s", redirectServer.Addr)
+				return redirectServer.ListenAndServe()
+			})
+		}
+	}
+
+	if c.cfg.Admin.Port != 0 {
+		adminServer := c.newAdminServer(fmt.Sprintf(":%d", c.cfg.Admin.Port))
+		servers = append(servers, adminServer)
+		serves = append(serves, func() error {
+			logger.Info("Admin API started at http://0.0.0.0%s", adminServer.Addr)
+			return adminServer.ListenAndServe()
+		})
+	}
+
+	if c.cfg.Metrics.Enable && c.cfg.Metrics.Port != 0 {
+		metricsServer := c.newMetricsServer(fmt.Sprintf(":%d", c.cfg.Metrics.Port))
+		servers = append(servers, metricsServer)
+		serves = append(serves, func() error {
+			logger.Info("Metrics started at http://0.0.0.0%s%s", metricsServer.Addr, c.cfg.Metrics.EffectivePath())
+			return metricsServer.ListenAndServe()
+		})
+	}
+
+	if c.cfg.Reload.Watch && c.shared.path != "" {
+		go c.watchConfig(ctx, c.cfg.Reload.EffectiveInterval())
+	}
+
+	// the gateway stops as soon as any of its listeners fails
+	errs := make(chan error, len(serves))
+	for _, serve := range serves {
+		go func() {
+			errs <- serve()
+		}()
+	}
+
+	select {
+	case err := <-errs:
+		c.shutdown(servers, 0)
+		return err
+	case <-ctx.Done():
+		logger.Info("Shutting down ...")
+		c.shutdown(servers, c.shared.current.Load().cfg.Shutdown.EffectiveDelay())
+		return nil
+	}
+}
+
+// shutdown drains the gateway. The outer health check fails at once, so load balancers
+// stop sending traffic; after delay the listeners close, and in-flight requests and
+// WebSockets get up to the shutdown timeout to finish. Health checks and plugin
+// resources (audit sinks, ...) are released last.
+func (c *core) shutdown(servers []*http.Server, delay time.Duration) {
+	c.shared.draining.Store(true)
+	for _, server := range servers {
+		// clients reconnect for their next request, possibly to another instance
+		server.SetKeepAlivesEnabled(false)
+	}
+	if delay > 0 {
+		logger.Info("Health check reports unavailable, closing listeners in %s", delay)
+		time.Sleep(delay)
+	}
+
+	// the settings of the configuration loaded last
+	current := c.shared.current.Load()
+
+	timeout := current.cfg.Shutdown.EffectiveTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := server.Shutdown(ctx); err != nil {
+				logger.Warn("Requests still in flight on %s after %s, closing connections", server.Addr, timeout)
+				server.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := c.websockets.Drain(ctx); err != nil {
+		logger.Warn("%d WebSocket connections still open after %s, closing them", c.websockets.Total(), timeout)
+		c.websockets.CloseAll()
+
+		// the relays stop at once; give their handlers a moment to release the upstreams
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = c.websockets.Drain(ctx)
+	}
+
+	current.close()
+	logger.Info("Server stopped")
+}
+
+// close releases what the gateway holds once no request is in flight.
+func (c *core) close() {
+	if c.lbManager != nil {
+		c.lbManager.StopAllHealthChecks()
+	}
+
+	c.closePlugins()
+
+	if c.accessLog != nil {
+		if err := c.accessLog.Close(); err != nil {
+			logger.Warn("failed to close access log: %s", err)
+		}
+	}
+
+	// spans still buffered are exported before the gateway exits
+	if c.tracer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := c.tracer.Shutdown(ctx); err != nil {
+			logger.Warn("failed to export spans: %s", err)
+		}
+	}
+
+	// go-zoox/cache has no Close; release the client when the engine supports it
+	if closer, ok := c.app.Cache().(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Warn("failed to close cache: %s", err)
+		}
+	}
+}
+
+// newServer creates the gateway's HTTP server. Besides HTTP/1.1 it accepts HTTP/2
+// over cleartext (h2c with prior knowledge), so gRPC clients can connect without TLS.
+func (c *core) newServer(addr string) *http.Server {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	return &http.Server{
+		Addr: addr,
+		// HTTP-01 challenges are answered on every plain HTTP listener
+		Handler: c.acmeHTTPHandler(c.shared),
+		// no read/write timeouts: streams (gRPC, SSE, WebSocket) are long-lived,
+		// and proxied exchanges are bounded by the upstream timeouts
+		ReadHeaderTimeout: 60 * time.Second,
+		IdleTimeout:       300 * time.Second,
+		Protocols:         protocols,
+	}
+}
+
+// newTLSServer creates the gateway's HTTPS server, negotiating HTTP/2 or HTTP/1.1 with ALPN.
+func (c *core) newTLSServer(addr string) *http.Server {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+
+	server := c.newServer(addr)
+	server.Protocols = protocols
+	server.TLSConfig = c.tlsConfig.Clone()
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the incoming URL has no scheme; set it so upstreams get X-Forwarded-Proto: https
+		r.URL.Scheme = "https"
+		c.shared.ServeHTTP(w, r)
+	})
+
+	return server
+}
+
+// newRedirectServer creates the plain HTTP server that sends every request to HTTPS.
+func (c *core) newRedirectServer(addr string) *http.Server {
+	server := c.newServer(addr)
+	server.Protocols = nil
+	server.Handler = c.acmeHTTPHandler(http.HandlerFunc(c.redirectToHTTPS))
+	return server
+}
+
+// ServeHTTP serves a request with the current generation of the gateway. The request
+// finishes on that generation even when a reload swaps in a new one meanwhile.
+func (s *shared) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c := s.current.Load()
+	c.inflight.Add(1)
+	defer c.inflight.Add(-1)
+
+	c.app.ServeHTTP(w, c.identify(w, r))
+}
+
+// redirectToHTTPS redirects a request to the same host, path and query over HTTPS.
+func (c *core) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else {
+		host = strings.Trim(host, "[]")
+	}
+	if port := c.cfg.HTTPS.Port; port != 443 {
+		host = net.JoinHostPort(host, strconv.FormatInt(port, 10))
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	// 308 keeps the method and body of non-GET requests
+	status := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		status = http.StatusPermanentRedirect
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
 }
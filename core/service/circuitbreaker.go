@@ -0,0 +1,80 @@
+package service
+
+import "time"
+
+// CircuitBreaker configures the per-server circuit breaker of a backend.
+//
+// A closed breaker lets traffic through and trips open on consecutive failures,
+// on the error ratio or on the slow-call ratio over a rolling window. An open breaker
+// takes the server out of load balancing for OpenDuration seconds, after which it is
+// half-open and lets HalfOpenRequests trial requests decide whether it closes again.
+type CircuitBreaker struct {
+	Enable bool `config:"enable"`
+
+	// ConsecutiveFailures trips the breaker after this many failures in a row (0 disables).
+	ConsecutiveFailures int64 `config:"consecutive_failures,default=5"`
+	// ErrorRatio trips the breaker when failures / requests over the window reaches it, 0-1 (0 disables).
+	ErrorRatio float64 `config:"error_ratio"`
+	// SlowCallRatio trips the breaker when slow calls / requests over the window reaches it, 0-1 (0 disables).
+	SlowCallRatio float64 `config:"slow_call_ratio"`
+	// SlowCallMs is the response time in milliseconds above which a call is slow (default 1000).
+	SlowCallMs int64 `config:"slow_call_ms,default=1000"`
+	// MinRequests is the number of requests in the window before the ratios are evaluated (default 10).
+	MinRequests int64 `config:"min_requests,default=10"`
+	// Window is the rolling window for the ratios in seconds (default 60).
+	Window int64 `config:"window,default=60"`
+
+	// OpenDuration is how long the breaker stays open in seconds (default 30).
+	OpenDuration int64 `config:"open_duration,default=30"`
+	// HalfOpenRequests is the number of trial requests allowed while half-open (default 1).
+	HalfOpenRequests int64 `config:"half_open_requests,default=1"`
+}
+
+// EffectiveConsecutiveFailures returns the consecutive failure threshold.
+// When neither ratio is configured, it defaults to 5 so an enabled breaker always has a trigger.
+func (c CircuitBreaker) EffectiveConsecutiveFailures() int64 {
+	if c.ConsecutiveFailures <= 0 && c.ErrorRatio <= 0 && c.SlowCallRatio <= 0 {
+		return 5
+	}
+	return c.ConsecutiveFailures
+}
+
+// SlowCallThreshold returns the duration above which a call counts as slow.
+func (c CircuitBreaker) SlowCallThreshold() time.Duration {
+	if c.SlowCallMs <= 0 {
+		return time.Second
+	}
+	return time.Duration(c.SlowCallMs) * time.Millisecond
+}
+
+// EffectiveMinRequests returns the minimum window volume before the ratios apply.
+func (c CircuitBreaker) EffectiveMinRequests() int64 {
+	if c.MinRequests <= 0 {
+		return 10
+	}
+	return c.MinRequests
+}
+
+// EffectiveWindow returns the rolling window in seconds.
+func (c CircuitBreaker) EffectiveWindow() int64 {
+	if c.Window <= 0 {
+		return 60
+	}
+	return c.Window
+}
+
+// OpenTimeout returns how long the breaker stays open before the first trial request.
+func (c CircuitBreaker) OpenTimeout() time.Duration {
+	if c.OpenDuration <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.OpenDuration) * time.Second
+}
+
+// EffectiveHalfOpenRequests returns the number of trial requests while half-open.
+func (c CircuitBreaker) EffectiveHalfOpenRequests() int64 {
+	if c.HalfOpenRequests <= 0 {
+		return 1
+	}
+	return c.HalfOpenRequests
+}
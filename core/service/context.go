@@ -0,0 +1,21 @@
+package service
+
+import (
+	"context"
+	"net/http"
+)
+
+type serviceKey struct{}
+
+// WithService returns a shallow copy of req carrying svc, the effective configuration of the
+// server selected for req.
+func WithService(req *http.Request, svc *Service) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), serviceKey{}, svc))
+}
+
+// FromRequest returns the effective configuration of the server selected for req, or nil
+// before the selection.
+func FromRequest(req *http.Request) *Service {
+	svc, _ := req.Context().Value(serviceKey{}).(*Service)
+	return svc
+}
@@ -28,3 +28,12 @@ func (s *Service) CheckDNS() (ips []string, err error) {
 
 	return net.LookupHost(s.Name)
 }
+
+// CheckDNS resolves the server name; IP addresses resolve to themselves.
+func (s *Server) CheckDNS() (ips []string, err error) {
+	if s.Name == "" {
+		return nil, fmt.Errorf("server name is required")
+	}
+
+	return net.LookupHost(s.Name)
+}
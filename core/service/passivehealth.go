@@ -0,0 +1,72 @@
+package service
+
+import "time"
+
+// PassiveHealthCheck configures outlier detection from proxied traffic. A server that
+// fails ConsecutiveFailures requests in a row (connection errors or 5xx responses) is
+// marked unhealthy and ejected from load balancing. Every ejection lasts twice as long
+// as the previous one, from BaseEjection up to MaxEjection; after an ejection the server
+// is back on probation, where a single failure ejects it again.
+type PassiveHealthCheck struct {
+	Enable bool `config:"enable"`
+
+	// ConsecutiveFailures ejects the server after this many failures in a row (default 5).
+	ConsecutiveFailures int64 `config:"consecutive_failures,default=5"`
+	// BaseEjection is the first ejection period in seconds (default 30).
+	BaseEjection int64 `config:"base_ejection,default=30"`
+	// MaxEjection caps the ejection period in seconds (default 300).
+	MaxEjection int64 `config:"max_ejection,default=300"`
+	// MaxEjectionPercent caps the share of a backend's servers ejected at the same time (default 50).
+	MaxEjectionPercent int64 `config:"max_ejection_percent,default=50"`
+	// ProbationSuccesses is the number of successful requests that end probation
+	// and reset the ejection back-off (default 5).
+	ProbationSuccesses int64 `config:"probation_successes,default=5"`
+}
+
+// EffectiveConsecutiveFailures returns the failures in a row that eject a server.
+func (p PassiveHealthCheck) EffectiveConsecutiveFailures() int64 {
+	if p.ConsecutiveFailures <= 0 {
+		return 5
+	}
+	return p.ConsecutiveFailures
+}
+
+// Ejection returns how long the server is ejected for its nth ejection (1 for the first).
+func (p PassiveHealthCheck) Ejection(n int64) time.Duration {
+	base := p.BaseEjection
+	if base <= 0 {
+		base = 30
+	}
+	max := p.MaxEjection
+	if max <= 0 {
+		max = 300
+	}
+
+	seconds := base
+	for i := int64(1); i < n && seconds < max; i++ {
+		seconds *= 2
+	}
+	if seconds > max {
+		seconds = max
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// EffectiveMaxEjectionPercent returns the max share of servers ejected at once.
+func (p PassiveHealthCheck) EffectiveMaxEjectionPercent() int64 {
+	if p.MaxEjectionPercent <= 0 {
+		return 50
+	}
+	if p.MaxEjectionPercent > 100 {
+		return 100
+	}
+	return p.MaxEjectionPercent
+}
+
+// EffectiveProbationSuccesses returns the successes that end probation.
+func (p PassiveHealthCheck) EffectiveProbationSuccesses() int64 {
+	if p.ProbationSuccesses <= 0 {
+		return 5
+	}
+	return p.ProbationSuccesses
+}
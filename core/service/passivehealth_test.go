@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPassiveHealthCheckEjection(t *testing.T) {
+	p := PassiveHealthCheck{BaseEjection: 10, MaxEjection: 60}
+
+	cases := []struct {
+		n    int64
+		want time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, 60 * time.Second},
+		{10, 60 * time.Second},
+	}
+
+	for _, c := range cases {
+		if got := p.Ejection(c.n); got != c.want {
+			t.Errorf("ejection #%d: expected %s, got %s", c.n, c.want, got)
+		}
+	}
+}
+
+func TestPassiveHealthCheckDefaults(t *testing.T) {
+	p := PassiveHealthCheck{}
+
+	if got := p.EffectiveConsecutiveFailures(); got != 5 {
+		t.Errorf("expected 5 consecutive failures, got %d", got)
+	}
+	if got := p.Ejection(1); got != 30*time.Second {
+		t.Errorf("expected 30s base ejection, got %s", got)
+	}
+	if got := p.EffectiveMaxEjectionPercent(); got != 50 {
+		t.Errorf("expected 50%% max ejection, got %d", got)
+	}
+	if got := p.EffectiveProbationSuccesses(); got != 5 {
+		t.Errorf("expected 5 probation successes, got %d", got)
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+// Supported upstream protocols.
+//
+// grpc and h2c are HTTP/2 over cleartext (prior knowledge, no upgrade);
+// grpcs is HTTP/2 over TLS.
+const (
+	ProtocolHTTP  = "http"
+	ProtocolHTTPS = "https"
+	ProtocolH2C   = "h2c"
+	ProtocolGRPC  = "grpc"
+	ProtocolGRPCS = "grpcs"
+)
+
+// Scheme returns the URL scheme used to reach the service.
+func (s *Service) Scheme() string {
+	switch s.Protocol {
+	case ProtocolHTTPS, ProtocolGRPCS:
+		return "https"
+	default:
+		return "http"
+	}
+}
+
+// IsHTTP2 reports whether the service must be reached over HTTP/2.
+func (s *Service) IsHTTP2() bool {
+	switch s.Protocol {
+	case ProtocolH2C, ProtocolGRPC, ProtocolGRPCS:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsGRPC reports whether the service speaks gRPC.
+func (s *Service) IsGRPC() bool {
+	return s.Protocol == ProtocolGRPC || s.Protocol == ProtocolGRPCS
+}
+
+// Supported health check types (health_check.type).
+const (
+	HealthCheckTypeHTTP = "http"
+	HealthCheckTypeGRPC = "grpc"
+)
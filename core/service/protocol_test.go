@@ -0,0 +1,32 @@
+package service
+
+import "testing"
+
+func TestProtocol(t *testing.T) {
+	cases := []struct {
+		protocol string
+		scheme   string
+		http2    bool
+		grpc     bool
+	}{
+		{"", "http", false, false},
+		{"http", "http", false, false},
+		{"https", "https", false, false},
+		{"h2c", "http", true, false},
+		{"grpc", "http", true, true},
+		{"grpcs", "https", true, true},
+	}
+
+	for _, c := range cases {
+		s := &Service{Protocol: c.protocol}
+		if s.Scheme() != c.scheme {
+			t.Errorf("protocol %q: expected scheme %s, got %s", c.protocol, c.scheme, s.Scheme())
+		}
+		if s.IsHTTP2() != c.http2 {
+			t.Errorf("protocol %q: expected IsHTTP2 %v", c.protocol, c.http2)
+		}
+		if s.IsGRPC() != c.grpc {
+			t.Errorf("protocol %q: expected IsGRPC %v", c.protocol, c.grpc)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Retry configures re-sending failed upstream requests to another server of the backend.
+type Retry struct {
+	// Attempts is the total number of tries, including the first one. Values <= 1 disable retries.
+	Attempts int64 `config:"attempts"`
+	// Statuses lists upstream response codes that are retried (default [502, 503, 504]).
+	Statuses []int64 `config:"statuses"`
+	// NetworkErrors retries connection failures such as refused or reset connections.
+	// Omitted (nil) means on.
+	NetworkErrors *bool `config:"network_errors"`
+	// BackoffBaseMs is the delay before the first retry in milliseconds; it doubles on every retry (default 50).
+	BackoffBaseMs int64 `config:"backoff_base_ms,default=50"`
+	// BackoffMaxMs caps the backoff delay in milliseconds (default 1000).
+	BackoffMaxMs int64 `config:"backoff_max_ms,default=1000"`
+	// NonIdempotent also retries POST, PATCH and other non-idempotent methods (default false).
+	NonIdempotent bool `config:"non_idempotent"`
+	// MaxBodyBytes caps the request body buffered for replay; larger requests are not retried (default 1MiB).
+	MaxBodyBytes int64 `config:"max_body_bytes,default=1048576"`
+}
+
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// Enabled reports whether more than one attempt is configured.
+func (r Retry) Enabled() bool {
+	return r.Attempts > 1
+}
+
+// MethodAllowed reports whether requests with method may be retried.
+func (r Retry) MethodAllowed(method string) bool {
+	if r.NonIdempotent {
+		return true
+	}
+	return idempotentMethods[strings.ToUpper(method)]
+}
+
+// RetryStatus reports whether an upstream response with status should be retried.
+func (r Retry) RetryStatus(status int) bool {
+	statuses := r.Statuses
+	if len(statuses) == 0 {
+		statuses = []int64{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	for _, s := range statuses {
+		if int64(status) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryNetworkErrors reports whether connection failures are retried. When NetworkErrors is omitted, defaults to true.
+func (r Retry) RetryNetworkErrors() bool {
+	if r.NetworkErrors == nil {
+		return true
+	}
+	return *r.NetworkErrors
+}
+
+// EffectiveMaxBodyBytes returns the max buffered request body size.
+func (r Retry) EffectiveMaxBodyBytes() int64 {
+	if r.MaxBodyBytes <= 0 {
+		return 1024 * 1024
+	}
+	return r.MaxBodyBytes
+}
+
+// Backoff returns the delay before the given retry (1 for the first retry): exponential
+// from BackoffBaseMs up to BackoffMaxMs, with jitter over the upper half of the delay.
+func (r Retry) Backoff(retry int) time.Duration {
+	base := r.BackoffBaseMs
+	if base <= 0 {
+		base = 50
+	}
+	max := r.BackoffMaxMs
+	if max <= 0 {
+		max = 1000
+	}
+
+	delay := base
+	for i := 1; i < retry && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	d := time.Duration(delay) * time.Millisecond
+	half := d / 2
+	return half + rand.N(half+1)
+}
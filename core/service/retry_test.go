@@ -0,0 +1,75 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryMethodAllowed(t *testing.T) {
+	r := Retry{Attempts: 3}
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete} {
+		if !r.MethodAllowed(method) {
+			t.Errorf("expected %s to be retried", method)
+		}
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPatch} {
+		if r.MethodAllowed(method) {
+			t.Errorf("expected %s not to be retried", method)
+		}
+	}
+
+	r.NonIdempotent = true
+	if !r.MethodAllowed(http.MethodPost) {
+		t.Errorf("expected POST to be retried with non_idempotent")
+	}
+}
+
+func TestRetryStatus(t *testing.T) {
+	r := Retry{}
+	for status, want := range map[int]bool{502: true, 503: true, 504: true, 500: false, 200: false} {
+		if got := r.RetryStatus(status); got != want {
+			t.Errorf("default statuses: %d expected %v, got %v", status, want, got)
+		}
+	}
+
+	r.Statuses = []int64{500}
+	if !r.RetryStatus(500) || r.RetryStatus(503) {
+		t.Errorf("custom statuses not honored")
+	}
+}
+
+func TestRetryNetworkErrors(t *testing.T) {
+	if !(Retry{}).RetryNetworkErrors() {
+		t.Errorf("expected network errors to be retried by default")
+	}
+
+	off := false
+	if (Retry{NetworkErrors: &off}).RetryNetworkErrors() {
+		t.Errorf("expected network_errors: false to be honored")
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	r := Retry{BackoffBaseMs: 100, BackoffMaxMs: 300}
+
+	cases := []struct {
+		retry int
+		max   time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 300 * time.Millisecond},
+		{10, 300 * time.Millisecond},
+	}
+
+	for _, c := range cases {
+		for i := 0; i < 20; i++ {
+			d := r.Backoff(c.retry)
+			if d < c.max/2 || d > c.max {
+				t.Fatalf("retry %d: backoff %s out of [%s, %s]", c.retry, d, c.max/2, c.max)
+			}
+		}
+	}
+}
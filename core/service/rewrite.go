@@ -7,7 +7,13 @@ import (
 )
 
 func (s *Service) Rewrite(originPath string) (newPath string) {
-	r := s.rewriter()
+	return s.RewriteParams(originPath, nil)
+}
+
+// RewriteParams is Rewrite with the {name} placeholders of the replacements expanded with
+// params, the values captured by the route path.
+func (s *Service) RewriteParams(originPath string, params map[string]string) (newPath string) {
+	r := s.rewriter(params)
 	// if len(r) == 0 {
 	// 	return originPath
 	// }
@@ -15,16 +21,26 @@ func (s *Service) Rewrite(originPath string) (newPath string) {
 	return r.Rewrite(originPath)
 }
 
-func (s *Service) rewriter() (r rewriter.Rewriters) {
+func (s *Service) rewriter(params map[string]string) (r rewriter.Rewriters) {
 	for _, rewrite := range s.Request.Path.Rewrites {
 		ft := strings.Split(rewrite, ":")
 		if len(ft) != 2 {
 			continue
 		}
 
+		to := ft[1]
+		if len(params) > 0 {
+			// params are literal values, not references to the groups of the regex
+			escaped := make(map[string]string, len(params))
+			for k, v := range params {
+				escaped[k] = strings.ReplaceAll(v, "$", "$$")
+			}
+			to = Expand(to, escaped)
+		}
+
 		r = append(r, rewriter.Rewriter{
 			From: ft[0],
-			To:   ft[1],
+			To:   to,
 		})
 	}
 
@@ -13,7 +13,7 @@ func TestRewrite(t *testing.T) {
 		},
 	}
 
-	r := s.rewriter()
+	r := s.rewriter(nil)
 	if len(r) != 1 {
 		t.Fatalf("expected 1 rewrite, got %d", len(r))
 	}
@@ -36,7 +36,7 @@ func TestRewriteWithRegExp(t *testing.T) {
 		},
 	}
 
-	r := s.rewriter()
+	r := s.rewriter(nil)
 	if len(r) != 1 {
 		t.Fatalf("expected 1 rewrite, got %d", len(r))
 	}
@@ -63,8 +63,29 @@ func TestRewriteInvalid(t *testing.T) {
 		},
 	}
 
-	r := s.rewriter()
+	r := s.rewriter(nil)
 	if len(r) != 0 {
 		t.Fatalf("expected 0 rewrite, got %d", len(r))
 	}
 }
+
+func TestRewriteParams(t *testing.T) {
+	s := &Service{
+		Request: Request{
+			Path: RequestPath{
+				Rewrites: []string{
+					"^/users/[^/]+/orders/(.*)$:/v2/accounts/{id}/orders/$1",
+				},
+			},
+		},
+	}
+
+	if got := s.RewriteParams("/users/42/orders/7", map[string]string{"id": "42"}); got != "/v2/accounts/42/orders/7" {
+		t.Errorf("expected /v2/accounts/42/orders/7, got %s", got)
+	}
+
+	// params are literal values
+	if got := s.RewriteParams("/users/$1/orders/7", map[string]string{"id": "$1"}); got != "/v2/accounts/$1/orders/7" {
+		t.Errorf("expected /v2/accounts/$1/orders/7, got %s", got)
+	}
+}
@@ -21,9 +21,12 @@ type Server struct {
 	Response    *Response    `config:"response"`
 	Auth        *Auth        `config:"auth"`
 	HealthCheck *HealthCheck `config:"health_check"`
+	Timeout     *Timeout     `config:"timeout"`
+	TLS         *TLS         `config:"tls"`
 
 	// Runtime state (not serialized)
 	healthy     bool
+	healthSet   bool
 	healthMutex sync.RWMutex
 }
 
@@ -39,6 +42,32 @@ func (s *Server) SetHealthy(healthy bool) {
 	s.healthMutex.Lock()
 	defer s.healthMutex.Unlock()
 	s.healthy = healthy
+	s.healthSet = true
+}
+
+// IsDisabled reports whether the server is disabled, in the configuration or at runtime
+func (s *Server) IsDisabled() bool {
+	s.healthMutex.RLock()
+	defer s.healthMutex.RUnlock()
+	return s.Disabled
+}
+
+// SetDisabled disables or enables the server at runtime
+func (s *Server) SetDisabled(disabled bool) {
+	s.healthMutex.Lock()
+	defer s.healthMutex.Unlock()
+	s.Disabled = disabled
+}
+
+// InitHealthy marks the server healthy unless its health has already been set,
+// so health check results survive the backend being normalized again
+func (s *Server) InitHealthy() {
+	s.healthMutex.Lock()
+	defer s.healthMutex.Unlock()
+	if !s.healthSet {
+		s.healthy = true
+		s.healthSet = true
+	}
 }
 
 // ID returns a unique identifier for the server
@@ -93,6 +122,16 @@ func (s *Server) GetEffectiveConfig(base *Service) *Service {
 		effective.HealthCheck = mergeHealthCheck(base.HealthCheck, *s.HealthCheck)
 	}
 
+	// Merge timeout configuration
+	if s.Timeout != nil {
+		effective.Timeout = mergeTimeout(base.Timeout, *s.Timeout)
+	}
+
+	// Merge upstream TLS configuration
+	if s.TLS != nil {
+		effective.TLS = mergeTLS(base.TLS, *s.TLS)
+	}
+
 	return &effective
 }
 
@@ -155,6 +194,12 @@ func mergeHealthCheck(base, override HealthCheck) HealthCheck {
 	if override.Enable {
 		merged.Enable = override.Enable
 	}
+	if override.Type != "" {
+		merged.Type = override.Type
+	}
+	if override.GRPCService != "" {
+		merged.GRPCService = override.GRPCService
+	}
 	if override.Method != "" {
 		merged.Method = override.Method
 	}
@@ -15,6 +15,11 @@ type Service struct {
 	Response    Response    `config:"response"`
 	Auth        Auth        `config:"auth"`
 	HealthCheck HealthCheck `config:"health_check"`
+	Timeout     Timeout     `config:"timeout"`
+	Retry       Retry       `config:"retry"`
+	TLS         TLS         `config:"tls"`
+
+	CircuitBreaker CircuitBreaker `config:"circuit_breaker"`
 }
 
 type Request struct {
@@ -35,6 +40,12 @@ type Response struct {
 type HealthCheck struct {
 	Enable bool `config:"enable"`
 
+	// Type is the health check protocol: http, or grpc (grpc.health.v1).
+	// Empty means grpc for grpc/grpcs services and http otherwise
+	Type string `config:"type"`
+	// GRPCService is the service name sent in grpc health checks; empty checks the whole server
+	GRPCService string `config:"grpc_service"`
+
 	//
 	Method string  `config:"method,default=GET"`
 	Path   string  `config:"path,default=/health"`
@@ -47,20 +58,40 @@ type HealthCheck struct {
 
 	// ok means health check is ok, ignore real check
 	Ok bool `config:"ok"`
+
+	// Passive ejects servers based on the outcome of proxied requests
+	Passive PassiveHealthCheck `config:"passive"`
 }
 
 type Auth struct {
+	// Type is the authentication required from clients: basic, bearer or jwt; empty, and
+	// the reserved oauth2, oidc and service, let every request through
 	Type string `config:"type"`
+	// Realm is sent in the WWW-Authenticate header of 401 responses
+	Realm string `config:"realm"`
 
 	// type: basic
 	Username string `config:"username"`
+	// Password is the password of Username, in clear or as a bcrypt hash
 	Password string `config:"password"`
+	// HTPasswd is an htpasswd file of more users, with bcrypt hashed passwords
+	HTPasswd string `config:"htpasswd"`
 
 	// type: bearer
-	Token string `config:"token"`
+	Token  string   `config:"token"`
+	Tokens []string `config:"tokens"`
 
 	// type: jwt
+	// Secret verifies tokens signed with HMAC (HS256, HS384, HS512)
 	Secret string `config:"secret"`
+	// PublicKey is a PEM file with the RSA or ECDSA public key (or certificate) verifying
+	// tokens signed with RS*, PS* or ES*
+	PublicKey string `config:"public_key"`
+	// Issuer and Audience are required in the iss and aud claims when set
+	Issuer   string   `config:"issuer"`
+	Audience []string `config:"audience"`
+	// ClockSkew is the tolerance on the exp and nbf claims in seconds
+	ClockSkew int64 `config:"clock_skew"`
 
 	// type: oauth2
 	Provider     string   `config:"provider"`
@@ -73,3 +104,11 @@ type Auth struct {
 
 	// type: service
 }
+
+// EffectiveRealm returns the realm of the 401 responses.
+func (a Auth) EffectiveRealm() string {
+	if a.Realm == "" {
+		return "api-gateway"
+	}
+	return a.Realm
+}
@@ -0,0 +1,37 @@
+package service
+
+import "strings"
+
+// Expand replaces the {name} placeholders of template with params, like {id} with the
+// id captured by a route path /users/:id. Placeholders without a param are kept as is.
+func Expand(template string, params map[string]string) string {
+	if len(params) == 0 || !strings.Contains(template, "{") {
+		return template
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		value, ok := params[template[start+1:end]]
+		if !ok {
+			b.WriteString(template[:start+1])
+			template = template[start+1:]
+			continue
+		}
+		b.WriteString(template[:start])
+		b.WriteString(value)
+		template = template[end+1:]
+	}
+	b.WriteString(template)
+
+	return b.String()
+}
@@ -0,0 +1,26 @@
+package service
+
+import "testing"
+
+func TestExpand(t *testing.T) {
+	params := map[string]string{"id": "42", "tenant": "acme"}
+
+	cases := map[string]string{
+		"/v2/users/{id}": "/v2/users/42",
+		"{tenant}-{id}":  "acme-42",
+		"{unknown}/{id}": "{unknown}/42",
+		"no placeholder": "no placeholder",
+		"{id":            "{id",
+		"${1}/{id}":      "${1}/42",
+		"{{id}}":         "{42}",
+	}
+	for template, want := range cases {
+		if got := Expand(template, params); got != want {
+			t.Errorf("Expand(%q): expected %q, got %q", template, want, got)
+		}
+	}
+
+	if got := Expand("{id}", nil); got != "{id}" {
+		t.Errorf("expected the template without params, got %q", got)
+	}
+}
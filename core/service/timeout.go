@@ -0,0 +1,66 @@
+package service
+
+import "time"
+
+// Timeout configures how long the gateway waits on an upstream, in seconds.
+// Zero values are unset and fall back to the next level (server → service → gateway).
+type Timeout struct {
+	// Connect caps establishing the TCP connection to the upstream server.
+	Connect int64 `config:"connect"`
+	// TLSHandshake caps the TLS handshake with an https upstream.
+	TLSHandshake int64 `config:"tls_handshake"`
+	// ResponseHeader caps the wait for response headers once the request is written.
+	ResponseHeader int64 `config:"response_header"`
+	// Total caps the whole proxied exchange, including streaming the response body.
+	Total int64 `config:"total"`
+}
+
+// IsZero reports whether no timeout is configured.
+func (t Timeout) IsZero() bool {
+	return t == Timeout{}
+}
+
+// WithDefaults fills unset fields from d.
+func (t Timeout) WithDefaults(d Timeout) Timeout {
+	return mergeTimeout(d, t)
+}
+
+// ConnectTimeout returns the dial timeout.
+func (t Timeout) ConnectTimeout() time.Duration {
+	return time.Duration(t.Connect) * time.Second
+}
+
+// TLSHandshakeTimeout returns the TLS handshake timeout.
+func (t Timeout) TLSHandshakeTimeout() time.Duration {
+	return time.Duration(t.TLSHandshake) * time.Second
+}
+
+// ResponseHeaderTimeout returns the response header timeout.
+func (t Timeout) ResponseHeaderTimeout() time.Duration {
+	return time.Duration(t.ResponseHeader) * time.Second
+}
+
+// TotalTimeout returns the deadline for the whole proxied exchange.
+func (t Timeout) TotalTimeout() time.Duration {
+	return time.Duration(t.Total) * time.Second
+}
+
+// mergeTimeout merges two Timeout configurations
+func mergeTimeout(base, override Timeout) Timeout {
+	merged := base
+
+	if override.Connect > 0 {
+		merged.Connect = override.Connect
+	}
+	if override.TLSHandshake > 0 {
+		merged.TLSHandshake = override.TLSHandshake
+	}
+	if override.ResponseHeader > 0 {
+		merged.ResponseHeader = override.ResponseHeader
+	}
+	if override.Total > 0 {
+		merged.Total = override.Total
+	}
+
+	return merged
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerGetEffectiveConfigTimeout(t *testing.T) {
+	base := &Service{
+		Timeout: Timeout{
+			Connect: 3,
+			Total:   30,
+		},
+	}
+
+	server := &Server{
+		Timeout: &Timeout{
+			ResponseHeader: 5,
+			Total:          10,
+		},
+	}
+
+	effective := server.GetEffectiveConfig(base)
+	want := Timeout{Connect: 3, ResponseHeader: 5, Total: 10}
+	if effective.Timeout != want {
+		t.Fatalf("expected %+v, got %+v", want, effective.Timeout)
+	}
+
+	// the base service must not be mutated by the merge
+	if base.Timeout.Total != 30 || base.Timeout.ResponseHeader != 0 {
+		t.Fatalf("base timeout mutated: %+v", base.Timeout)
+	}
+}
+
+func TestTimeoutWithDefaults(t *testing.T) {
+	defaults := Timeout{Connect: 5, TLSHandshake: 10, ResponseHeader: 30, Total: 60}
+
+	got := Timeout{ResponseHeader: 2}.WithDefaults(defaults)
+	want := Timeout{Connect: 5, TLSHandshake: 10, ResponseHeader: 2, Total: 60}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	if !(Timeout{}).WithDefaults(Timeout{}).IsZero() {
+		t.Fatal("expected zero timeout")
+	}
+}
+
+func TestTimeoutDurations(t *testing.T) {
+	timeout := Timeout{Connect: 1, TLSHandshake: 2, ResponseHeader: 3, Total: 4}
+
+	if timeout.ConnectTimeout() != time.Second {
+		t.Errorf("connect: %s", timeout.ConnectTimeout())
+	}
+	if timeout.TLSHandshakeTimeout() != 2*time.Second {
+		t.Errorf("tls handshake: %s", timeout.TLSHandshakeTimeout())
+	}
+	if timeout.ResponseHeaderTimeout() != 3*time.Second {
+		t.Errorf("response header: %s", timeout.ResponseHeaderTimeout())
+	}
+	if timeout.TotalTimeout() != 4*time.Second {
+		t.Errorf("total: %s", timeout.TotalTimeout())
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// TLS configures the TLS connection to an https or grpcs upstream.
+// Files are PEM encoded.
+type TLS struct {
+	// CA is a bundle of CAs trusted for the upstream certificate; empty uses the system roots.
+	CA string `config:"ca"`
+	// Cert and Key are the client certificate and key presented to the upstream (mutual TLS).
+	Cert string `config:"cert"`
+	Key  string `config:"key"`
+	// SNI overrides the server name sent to the upstream and verified in its certificate.
+	SNI string `config:"sni"`
+	// InsecureSkipVerify disables the verification of the upstream certificate.
+	InsecureSkipVerify bool `config:"insecure_skip_verify"`
+}
+
+// IsZero reports whether no TLS setting is configured.
+func (t TLS) IsZero() bool {
+	return t == TLS{}
+}
+
+// ClientConfig builds the client TLS config, or nil when no TLS setting is configured.
+func (t TLS) ClientConfig() (*tls.Config, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		ServerName:         t.SNI,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CA != "" {
+		pem, err := os.ReadFile(t.CA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls ca: %s", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls ca %s contains no certificate", t.CA)
+		}
+		cfg.RootCAs = roots
+	}
+
+	if t.Cert != "" || t.Key != "" {
+		if t.Cert == "" || t.Key == "" {
+			return nil, fmt.Errorf("tls cert and key must be set together")
+		}
+		certificate, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %s", err)
+		}
+		cfg.Certificates = []tls.Certificate{certificate}
+	}
+
+	return cfg, nil
+}
+
+// TLSConfigs caches the client TLS configs of upstream TLS settings, so certificate
+// files are read once. Every configuration generation has its own cache: a reload
+// reads the files again and picks up rotated certificates.
+type TLSConfigs struct {
+	configs map[TLS]*tls.Config
+	mu      sync.Mutex
+}
+
+// NewTLSConfigs creates an empty TLS config cache
+func NewTLSConfigs() *TLSConfigs {
+	return &TLSConfigs{
+		configs: make(map[TLS]*tls.Config),
+	}
+}
+
+// Get returns the client TLS config of the settings, or nil when none is configured
+func (c *TLSConfigs) Get(settings TLS) (*tls.Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cfg, ok := c.configs[settings]; ok {
+		return cfg, nil
+	}
+
+	cfg, err := settings.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	c.configs[settings] = cfg
+	return cfg, nil
+}
+
+// mergeTLS merges two TLS configurations
+func mergeTLS(base, override TLS) TLS {
+	merged := base
+
+	if override.CA != "" {
+		merged.CA = override.CA
+	}
+	if override.Cert != "" {
+		merged.Cert = override.Cert
+	}
+	if override.Key != "" {
+		merged.Key = override.Key
+	}
+	if override.SNI != "" {
+		merged.SNI = override.SNI
+	}
+	if override.InsecureSkipVerify {
+		merged.InsecureSkipVerify = override.InsecureSkipVerify
+	}
+
+	return merged
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA returns a self-signed CA certificate, PEM encoded.
+func testCA(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestServerGetEffectiveConfigTLS(t *testing.T) {
+	base := &Service{
+		Protocol: "https",
+		TLS: TLS{
+			CA:  "/etc/ssl/ca.pem",
+			SNI: "api.internal",
+		},
+	}
+
+	server := &Server{
+		TLS: &TLS{
+			Cert: "/etc/ssl/client.pem",
+			Key:  "/etc/ssl/client.key",
+		},
+	}
+
+	effective := server.GetEffectiveConfig(base)
+	want := TLS{CA: "/etc/ssl/ca.pem", Cert: "/etc/ssl/client.pem", Key: "/etc/ssl/client.key", SNI: "api.internal"}
+	if effective.TLS != want {
+		t.Fatalf("expected %+v, got %+v", want, effective.TLS)
+	}
+
+	// the base service must not be mutated by the merge
+	if base.TLS.Cert != "" {
+		t.Fatalf("base tls mutated: %+v", base.TLS)
+	}
+
+	if (&Server{}).GetEffectiveConfig(base).TLS != base.TLS {
+		t.Fatal("expected the base tls without server override")
+	}
+}
+
+func TestTLSClientConfig(t *testing.T) {
+	cfg, err := TLS{}.ClientConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != nil {
+		t.Fatal("expected no tls config when nothing is configured")
+	}
+
+	cfg, err = TLS{SNI: "api.internal", InsecureSkipVerify: true}.ClientConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ServerName != "api.internal" || !cfg.InsecureSkipVerify {
+		t.Fatalf("unexpected tls config %+v", cfg)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("no certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []TLS{
+		{CA: "/nonexistent/ca.pem"},
+		{CA: empty},
+		{Cert: "/etc/ssl/client.pem"},
+		{Cert: empty, Key: empty},
+	}
+	for _, settings := range invalid {
+		if _, err := settings.ClientConfig(); err == nil {
+			t.Errorf("expected %+v to be rejected", settings)
+		}
+	}
+}
+
+func TestTLSConfigs(t *testing.T) {
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	settings := TLS{CA: ca}
+
+	if _, err := NewTLSConfigs().Get(settings); err == nil {
+		t.Fatal("expected a missing ca to be rejected")
+	}
+
+	if err := os.WriteFile(ca, testCA(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	configs := NewTLSConfigs()
+	cfg, err := configs.Get(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := configs.Get(settings); again != cfg {
+		t.Error("expected the config to be cached")
+	}
+
+	// a new generation reads the files again
+	if err := os.Remove(ca); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := configs.Get(settings); again != cfg {
+		t.Error("expected the cached config to be kept")
+	}
+	if _, err := NewTLSConfigs().Get(settings); err == nil {
+		t.Error("expected a new cache to read the files again")
+	}
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/gorilla/websocket"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	upstream := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})
+
+	gw, server, url := serveTestGateway(t, &config.Config{
+		HealthCheck: config.HealthCheck{
+			Outer: config.HealthCheckOuter{Enable: true, Path: "/healthz", Ok: true},
+		},
+		Shutdown: config.Shutdown{Delay: 1, Timeout: 5},
+		Routes: []route.Route{
+			{
+				Name:    "slow",
+				Path:    "/slow",
+				Backend: route.Backend{Service: testService(t, upstream)},
+			},
+		},
+	})
+
+	res, err := http.Get(url + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected healthy gateway, got %d", res.StatusCode)
+	}
+
+	type result struct {
+		body string
+		err  error
+	}
+	inflight := make(chan result, 1)
+	go func() {
+		res, err := http.Get(url + "/slow")
+		if err != nil {
+			inflight <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		inflight <- result{body: string(body), err: err}
+	}()
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		gw.shutdown([]*http.Server{server}, gw.cfg.Shutdown.EffectiveDelay())
+		close(stopped)
+	}()
+
+	// during the delay the listener still accepts, but the health check fails
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for {
+		res, err := http.Get(url + "/healthz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 503 while draining, got %d", res.StatusCode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-stopped:
+		t.Fatal("expected shutdown to wait for the in-flight request")
+	case <-time.After(1500 * time.Millisecond):
+	}
+
+	close(release)
+	if r := <-inflight; r.err != nil || r.body != "done" {
+		t.Fatalf("expected the in-flight request to complete, got %q (%v)", r.body, r.err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not return after the request drained")
+	}
+
+	if _, err := http.Get(url + "/healthz"); err == nil {
+		t.Fatal("expected the listener to be closed")
+	}
+}
+
+func TestGracefulShutdownClosesWebSockets(t *testing.T) {
+	upstream := newEchoWebSocketUpstream(t, "echo")
+
+	gw, server, url := serveTestGateway(t, &config.Config{
+		Shutdown: config.Shutdown{Delay: -1, Timeout: 1},
+		Routes: []route.Route{
+			{
+				Name:    "ws",
+				Path:    "/ws",
+				Backend: route.Backend{Service: testService(t, upstream)},
+			},
+		},
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+url[len("http"):]+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	echo(t, conn, "hello")
+
+	start := time.Now()
+	gw.shutdown([]*http.Server{server}, gw.cfg.Shutdown.EffectiveDelay())
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 3*time.Second {
+		t.Fatalf("expected the websocket to be closed at the drain timeout, took %s", elapsed)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Fatalf("expected close 1001, got %v", err)
+	}
+
+	if total := gw.websockets.Total(); total != 0 {
+		t.Fatalf("expected no open websocket, got %d", total)
+	}
+}
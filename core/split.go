@@ -0,0 +1,72 @@
+package core
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+
+	"github.com/go-zoox/api-gateway/core/route"
+)
+
+// splitBackend selects the backend of r for req among r.Backends: the one named in the
+// sticky cookie, else one picked by weight, from the hash of the sticky header when the
+// request has one. It reports whether the sticky cookie must be set on the response, and
+// returns nil when every weight is 0.
+func splitBackend(req *http.Request, r *route.Route) (backend *route.SplitBackend, setCookie bool) {
+	total := int64(0)
+	for _, b := range r.Backends {
+		if b.Weight > 0 {
+			total += b.Weight
+		}
+	}
+	if total == 0 {
+		return nil, false
+	}
+
+	sticky := r.Sticky
+	if sticky.Cookie != "" {
+		if cookie, err := req.Cookie(sticky.Cookie); err == nil {
+			for i := range r.Backends {
+				if b := &r.Backends[i]; b.Name == cookie.Value && b.Weight > 0 {
+					return b, false
+				}
+			}
+		}
+	}
+
+	var n int64
+	if value := req.Header.Get(sticky.Header); sticky.Header != "" && value != "" {
+		h := fnv.New64a()
+		h.Write([]byte(value))
+		n = int64(h.Sum64() % uint64(total))
+	} else {
+		n = rand.Int63n(total)
+	}
+
+	for i := range r.Backends {
+		b := &r.Backends[i]
+		if b.Weight <= 0 {
+			continue
+		}
+		if n < b.Weight {
+			return b, sticky.Cookie != ""
+		}
+		n -= b.Weight
+	}
+	return nil, false
+}
+
+// stickyCookie returns the cookie keeping the client of r on backend.
+func stickyCookie(r *route.Route, backend *route.SplitBackend) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     r.Sticky.Cookie,
+		Value:    backend.Name,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if r.Sticky.MaxAge > 0 {
+		cookie.MaxAge = int(r.Sticky.MaxAge)
+	}
+	return cookie
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// pathHandler answers with name and the path it received.
+func pathHandler(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, name+" "+r.URL.Path)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	blue := newTestServer(t, pathHandler("blue"))
+	green := newTestServer(t, pathHandler("green"))
+
+	// each backend has its own service config
+	greenService := testService(t, green)
+	greenService.Request.Path.Rewrites = []string{"^/api/(.*):/v2/$1"}
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Metrics: config.Metrics{Enable: true},
+		Routes: []route.Route{
+			{
+				Name: "split-api",
+				Path: "/api",
+				Backends: []route.SplitBackend{
+					{Name: "blue", Weight: 3, Service: testService(t, blue)},
+					{Name: "green", Weight: 1, Service: greenService},
+				},
+			},
+		},
+	})
+
+	counts := map[string]int{}
+	for i := 0; i < 400; i++ {
+		_, body := get(t, url+"/api/users")
+		counts[body]++
+	}
+	if counts["blue /api/users"]+counts["green /v2/users"] != 400 {
+		t.Fatalf("unexpected answers %v", counts)
+	}
+	if green := counts["green /v2/users"]; green < 50 || green > 150 {
+		t.Errorf("expected about a quarter of the requests on green, got %v", counts)
+	}
+
+	_, metrics := get(t, url+"/metrics")
+	if metricValue(metrics, `api_gateway_split_requests_total{backend="green",route="split-api"}`) != float64(counts["green /v2/users"]) {
+		t.Errorf("expected the requests of green to be counted, got\n%s", metrics)
+	}
+}
+
+func TestSplitSticky(t *testing.T) {
+	blue := newTestServer(t, pathHandler("blue"))
+	green := newTestServer(t, pathHandler("green"))
+
+	backends := []route.SplitBackend{
+		{Name: "blue", Weight: 1, Service: testService(t, blue)},
+		{Name: "green", Weight: 1, Service: testService(t, green)},
+	}
+	_, _, url := serveTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:     "split-cookie",
+				Path:     "/cookie",
+				Backends: backends,
+				Sticky:   route.Sticky{Cookie: "backend", MaxAge: 3600},
+			},
+			{
+				Name:     "split-header",
+				Path:     "/header",
+				Backends: backends,
+				Sticky:   route.Sticky{Header: "X-User-Id"},
+			},
+		},
+	})
+
+	do := func(path string, header http.Header) (*http.Response, string) {
+		req, _ := http.NewRequest(http.MethodGet, url+path, nil)
+		req.Header = header
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res, string(body)
+	}
+
+	res, first := do("/cookie", http.Header{})
+	cookies := res.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "backend" || cookies[0].MaxAge != 3600 {
+		t.Fatalf("expected the sticky cookie, got %v", res.Header["Set-Cookie"])
+	}
+	if first != cookies[0].Value+" /cookie" {
+		t.Fatalf("expected the cookie to name the backend, got %q for %q", cookies[0].Value, first)
+	}
+	for i := 0; i < 20; i++ {
+		res, body := do("/cookie", http.Header{"Cookie": {"backend=" + cookies[0].Value}})
+		if body != first {
+			t.Fatalf("expected the client to stay on %q, got %q", first, body)
+		}
+		if len(res.Cookies()) != 0 {
+			t.Fatalf("expected the cookie not to be set again, got %v", res.Header["Set-Cookie"])
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, user := range []string{"alice", "bob", "carol", "dave", "erin", "frank"} {
+		_, first := do("/header", http.Header{"X-User-Id": {user}})
+		seen[first] = true
+		for i := 0; i < 5; i++ {
+			if _, body := do("/header", http.Header{"X-User-Id": {user}}); body != first {
+				t.Fatalf("expected %s to stay on %q, got %q", user, first, body)
+			}
+		}
+	}
+	if len(seen) != 2 {
+		t.Errorf("expected the users to be split between both backends, got %v", seen)
+	}
+}
+
+func TestSplitDrained(t *testing.T) {
+	blue := newTestServer(t, pathHandler("blue"))
+	green := newTestServer(t, pathHandler("green"))
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name: "split-drained",
+				Path: "/api",
+				Backends: []route.SplitBackend{
+					{Name: "blue", Weight: 1, Service: testService(t, blue)},
+					{Name: "green", Weight: 0, Service: testService(t, green)},
+				},
+				Sticky: route.Sticky{Cookie: "backend"},
+			},
+		},
+	})
+
+	// a drained backend keeps no client, even a sticky one
+	req, _ := http.NewRequest(http.MethodGet, url+"/api", nil)
+	req.AddCookie(&http.Cookie{Name: "backend", Value: "green"})
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "blue /api" {
+		t.Errorf("expected blue, got %q", body)
+	}
+	if cookies := res.Cookies(); len(cookies) != 1 || cookies[0].Value != "blue" {
+		t.Errorf("expected the client to be moved to blue, got %v", res.Header["Set-Cookie"])
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	upstream := newNamedUpstream(t, "a")
+	svc := testService(t, upstream)
+
+	cases := map[string][]route.SplitBackend{
+		"no name":        {{Weight: 1, Service: svc}},
+		"duplicate name": {{Name: "a", Weight: 1, Service: svc}, {Name: "a", Weight: 1, Service: svc}},
+		"no service":     {{Name: "a", Weight: 1}},
+		"no weight":      {{Name: "a", Service: svc}, {Name: "b", Service: svc}},
+		"negative":       {{Name: "a", Weight: -1, Service: svc}, {Name: "b", Weight: 2, Service: svc}},
+	}
+	for name, backends := range cases {
+		_, err := New("test", &config.Config{
+			Routes: []route.Route{{Name: "split-invalid", Path: "/api", Backends: backends}},
+		})
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	_, err := New("test", &config.Config{
+		Routes: []route.Route{
+			{
+				Name:     "split-exclusive",
+				Path:     "/api",
+				Backend:  route.Backend{Service: svc},
+				Backends: []route.SplitBackend{{Name: "a", Weight: 1, Service: service.Service{Name: "b", Port: 80}}},
+			},
+		},
+	})
+	if err == nil {
+		t.Error("expected backend and backends together to be rejected")
+	}
+}
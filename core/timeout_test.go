@@ -0,0 +1,110 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+func newSlowUpstream(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+
+	return newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = io.WriteString(w, "slow")
+	})
+}
+
+func TestProxyResponseHeaderTimeout(t *testing.T) {
+	upstream := newSlowUpstream(t, 3*time.Second)
+
+	gw := newTestGateway(t, &config.Config{
+		Timeout: config.Timeout{ResponseHeader: 1},
+		Routes: []route.Route{
+			{
+				Name:    "slow",
+				Path:    "/slow",
+				Backend: route.Backend{Service: testService(t, upstream)},
+			},
+		},
+	})
+
+	start := time.Now()
+	res, err := http.Get(gw.URL + "/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", res.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > 2500*time.Millisecond {
+		t.Fatalf("timeout was not enforced, took %s", elapsed)
+	}
+}
+
+func TestProxyTotalTimeoutServiceOverride(t *testing.T) {
+	upstream := newSlowUpstream(t, 3*time.Second)
+
+	svc := testService(t, upstream)
+	svc.Timeout = service.Timeout{Total: 1}
+
+	gw := newTestGateway(t, &config.Config{
+		// the service-level timeout wins over the gateway default
+		Timeout: config.Timeout{Total: 10},
+		Routes: []route.Route{
+			{
+				Name:    "slow",
+				Path:    "/slow",
+				Backend: route.Backend{Service: svc},
+			},
+		},
+	})
+
+	res, err := http.Get(gw.URL + "/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", res.StatusCode)
+	}
+}
+
+func TestProxyWithinTimeout(t *testing.T) {
+	upstream := newSlowUpstream(t, 0)
+
+	gw := newTestGateway(t, &config.Config{
+		Timeout: config.Timeout{Connect: 1, ResponseHeader: 1, Total: 2},
+		Routes: []route.Route{
+			{
+				Name:    "fast",
+				Path:    "/fast",
+				Backend: route.Backend{Service: testService(t, upstream)},
+			},
+		},
+	})
+
+	res, err := http.Get(gw.URL + "/fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK || string(body) != "slow" {
+		t.Fatalf("expected 200 slow, got %d %q", res.StatusCode, body)
+	}
+}
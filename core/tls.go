@@ -0,0 +1,289 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/router"
+	"github.com/go-zoox/logger"
+	"golang.org/x/crypto/acme"
+)
+
+// tlsVersions maps the configured minimum TLS versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig builds the listener TLS config; getCertificate selects the certificate
+// of every handshake.
+func newTLSConfig(cfg config.HTTPS, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Config, error) {
+	minVersion := cfg.MinVersion
+	if minVersion == "" {
+		minVersion = "1.2"
+	}
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported https min_version %q (expected 1.0, 1.1, 1.2 or 1.3)", cfg.MinVersion)
+	}
+
+	cipherSuites, err := parseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	nextProtos := []string{"h2", "http/1.1"}
+	if cfg.ACME.Enable {
+		// TLS-ALPN-01 challenges
+		nextProtos = append(nextProtos, acme.ALPNProto)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     version,
+		CipherSuites:   cipherSuites,
+		GetCertificate: getCertificate,
+		NextProtos:     nextProtos,
+	}
+
+	if err := configureClientAuth(tlsConfig, cfg); err != nil {
+		return nil, err
+	}
+
+	return tlsConfig, nil
+}
+
+// configureClientAuth verifies the client certificates presented to the listener, and
+// requires one on the client_cert_hosts. Routes requiring a certificate are enforced
+// once the request is known, by the clientcert plugin.
+func configureClientAuth(tlsConfig *tls.Config, cfg config.HTTPS) error {
+	if cfg.ClientCA == "" {
+		if len(cfg.ClientCertHosts) > 0 {
+			return fmt.Errorf("https client_cert_hosts requires client_ca")
+		}
+		return nil
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCA)
+	if err != nil {
+		return fmt.Errorf("failed to read https client_ca: %s", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("https client_ca %s contains no certificate", cfg.ClientCA)
+	}
+
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+
+	if len(cfg.ClientCertHosts) > 0 {
+		strict := tlsConfig.Clone()
+		strict.ClientAuth = tls.RequireAndVerifyClientCert
+
+		tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			// the CA validating a TLS-ALPN-01 challenge has no client certificate
+			if !isACMEChallenge(hello) && router.MatchHost(cfg.ClientCertHosts, router.Hostname(hello.ServerName)) {
+				return strict, nil
+			}
+			return nil, nil
+		}
+	}
+
+	return nil
+}
+
+// parseCipherSuites resolves cipher suite names to their IDs. Suites Go considers
+// insecure are accepted too, since older clients may still require them.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := map[string]uint16{}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown https cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// certificateStore holds the configured certificates and picks one per TLS handshake.
+// Certificate files are reloaded when they change on disk.
+type certificateStore struct {
+	sync.RWMutex
+	entries []*certificateEntry
+
+	stop chan struct{}
+	once sync.Once
+}
+
+type certificateEntry struct {
+	domain   string
+	certFile string
+	keyFile  string
+
+	certificate *tls.Certificate
+	// modification stamps of the files the certificate was loaded from
+	certStamp fileStamp
+	keyStamp  fileStamp
+}
+
+// fileStamp identifies a version of a file on disk
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// newCertificateStore loads every certificate in ssl; any unreadable certificate is an error.
+func newCertificateStore(ssl []config.SSL) (*certificateStore, error) {
+	s := &certificateStore{
+		stop: make(chan struct{}),
+	}
+
+	for _, cfg := range ssl {
+		if cfg.Cert.Certificate == "" || cfg.Cert.CertificateKey == "" {
+			return nil, fmt.Errorf("ssl %q: certificate and certificate_key are required", cfg.Domain)
+		}
+
+		entry := &certificateEntry{
+			domain:   strings.ToLower(strings.TrimSuffix(cfg.Domain, ".")),
+			certFile: cfg.Cert.Certificate,
+			keyFile:  cfg.Cert.CertificateKey,
+		}
+		if err := entry.load(); err != nil {
+			return nil, fmt.Errorf("ssl %q: %s", cfg.Domain, err)
+		}
+
+		s.entries = append(s.entries, entry)
+	}
+
+	return s, nil
+}
+
+// load reads the certificate files if they changed since they were last loaded.
+func (e *certificateEntry) load() error {
+	certStamp, err := statFile(e.certFile)
+	if err != nil {
+		return err
+	}
+	keyStamp, err := statFile(e.keyFile)
+	if err != nil {
+		return err
+	}
+	if e.certificate != nil && certStamp == e.certStamp && keyStamp == e.keyStamp {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(e.certFile, e.keyFile)
+	if err != nil {
+		return err
+	}
+
+	e.certificate = &certificate
+	e.certStamp = certStamp
+	e.keyStamp = keyStamp
+	return nil
+}
+
+// GetCertificate selects the certificate for the requested server name: an exact
+// domain first, then a wildcard domain, then the first certificate as the default.
+func (s *certificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if len(s.entries) == 0 {
+		return nil, fmt.Errorf("no certificate configured")
+	}
+
+	if entry := s.lookup(hello.ServerName); entry != nil {
+		return entry.certificate, nil
+	}
+	return s.entries[0].certificate, nil
+}
+
+func (s *certificateStore) lookup(serverName string) *certificateEntry {
+	name := router.Hostname(serverName)
+	if name == "" {
+		return nil
+	}
+
+	for _, entry := range s.entries {
+		if entry.domain == name {
+			return entry
+		}
+	}
+
+	// an exact domain takes precedence over a wildcard one
+	if wildcard := router.Wildcard(name); wildcard != "" {
+		for _, entry := range s.entries {
+			if entry.domain == wildcard {
+				return entry
+			}
+		}
+	}
+
+	return nil
+}
+
+// Reload reloads the certificates whose files changed. A certificate that fails to
+// load keeps being served as it was until its files are fixed.
+func (s *certificateStore) Reload() {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, entry := range s.entries {
+		before := entry.certificate
+		if err := entry.load(); err != nil {
+			logger.Errorf("[tls] failed to reload certificate for %s: %s", entry.domain, err)
+			continue
+		}
+		if entry.certificate != before {
+			logger.Infof("[tls] reloaded certificate for %s", entry.domain)
+		}
+	}
+}
+
+// Watch checks the certificate files for changes every interval until Stop is called.
+func (s *certificateStore) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.Reload()
+		}
+	}
+}
+
+// Stop stops watching the certificate files.
+func (s *certificateStore) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
@@ -0,0 +1,524 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// writeTestCertificate writes a self-signed server and client certificate for dnsNames
+// to dir and returns its ssl config.
+func writeTestCertificate(t *testing.T, dir, name string, dnsNames ...string) config.SSL {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.SSL{
+		Domain: dnsNames[0],
+		Cert: config.SSLCert{
+			Certificate:    filepath.Join(dir, name+".crt"),
+			CertificateKey: filepath.Join(dir, name+".key"),
+		},
+	}
+	if err := os.WriteFile(cfg.Cert.Certificate, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.Cert.CertificateKey, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func certificateName(t *testing.T, certificate *tls.Certificate) string {
+	t.Helper()
+
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateStoreSNI(t *testing.T) {
+	dir := t.TempDir()
+	store, err := newCertificateStore([]config.SSL{
+		writeTestCertificate(t, dir, "default", "default.test"),
+		writeTestCertificate(t, dir, "wildcard", "*.example.com"),
+		writeTestCertificate(t, dir, "exact", "api.example.com"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"api.example.com":  "api.example.com",
+		"API.Example.com.": "api.example.com",
+		"www.example.com":  "*.example.com",
+		// wildcards match a single label
+		"a.b.example.com": "default.test",
+		"example.com":     "default.test",
+		// no SNI
+		"": "default.test",
+	}
+	for serverName, expected := range cases {
+		certificate, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := certificateName(t, certificate); got != expected {
+			t.Errorf("%q: expected the %s certificate, got %s", serverName, expected, got)
+		}
+	}
+}
+
+func TestCertificateStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	ssl := writeTestCertificate(t, dir, "site", "old.test")
+
+	store, err := newCertificateStore([]config.SSL{ssl})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an incomplete write keeps the current certificate
+	if err := os.WriteFile(ssl.Cert.Certificate, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store.Reload()
+	certificate, _ := store.GetCertificate(&tls.ClientHelloInfo{})
+	if got := certificateName(t, certificate); got != "old.test" {
+		t.Fatalf("expected the old certificate to be kept, got %s", got)
+	}
+
+	writeTestCertificate(t, dir, "site", "new.test")
+	go store.Watch(10 * time.Millisecond)
+	defer store.Stop()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		certificate, _ := store.GetCertificate(&tls.ClientHelloInfo{})
+		if certificateName(t, certificate) == "new.test" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the certificate to be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	store := &certificateStore{}
+
+	cfg, err := newTLSConfig(config.HTTPS{
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	}, store.GetCertificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MinVersion != tls.VersionTLS13 {
+		t.Fatalf("expected TLS 1.3, got %x", cfg.MinVersion)
+	}
+	if len(cfg.CipherSuites) != 1 || cfg.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Fatalf("unexpected cipher suites %v", cfg.CipherSuites)
+	}
+
+	if _, err := newTLSConfig(config.HTTPS{MinVersion: "1.4"}, store.GetCertificate); err == nil {
+		t.Fatal("expected an unsupported min_version to be rejected")
+	}
+	if _, err := newTLSConfig(config.HTTPS{CipherSuites: []string{"TLS_NOPE"}}, store.GetCertificate); err == nil {
+		t.Fatal("expected an unknown cipher suite to be rejected")
+	}
+}
+
+func TestHTTPS(t *testing.T) {
+	upstream := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("X-Forwarded-Proto"))
+	})
+
+	dir := t.TempDir()
+	c, err := New("test", &config.Config{
+		HTTPS: config.HTTPS{Port: 8443, MinVersion: "1.2"},
+		SSL:   []config.SSL{writeTestCertificate(t, dir, "gateway", "gateway.test")},
+		Routes: []route.Route{
+			{
+				Name:    "api",
+				Path:    "/",
+				Backend: route.Backend{Service: testService(t, upstream)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := c.(*core)
+	if err := gw.build(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		gw.lbManager.StopAllHealthChecks()
+	})
+
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = gw.newTLSServer("")
+	srv.TLS = srv.Config.TLSConfig
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	client := func(maxVersion uint16) *http.Client {
+		transport := &http.Transport{
+			ForceAttemptHTTP2: true,
+			TLSClientConfig: &tls.Config{
+				ServerName:         "gateway.test",
+				InsecureSkipVerify: true,
+				MaxVersion:         maxVersion,
+			},
+		}
+		t.Cleanup(transport.CloseIdleConnections)
+		return &http.Client{Transport: transport}
+	}
+
+	res, err := client(0).Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if string(body) != "https" {
+		t.Fatalf("expected X-Forwarded-Proto https, got %q", body)
+	}
+	if res.ProtoMajor != 2 {
+		t.Fatalf("expected HTTP/2 over TLS, got %s", res.Proto)
+	}
+	if got := res.TLS.PeerCertificates[0].Subject.CommonName; got != "gateway.test" {
+		t.Fatalf("unexpected certificate %s", got)
+	}
+
+	// below the minimum TLS version
+	if _, err := client(tls.VersionTLS11).Get(srv.URL + "/"); err == nil {
+		t.Fatal("expected a TLS 1.1 handshake to fail")
+	}
+}
+
+func TestHTTPSRequiresCertificate(t *testing.T) {
+	if _, err := New("test", &config.Config{HTTPS: config.HTTPS{Port: 8443}}); err == nil {
+		t.Fatal("expected https without ssl certificates to be rejected")
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	cases := []struct {
+		port     int64
+		method   string
+		target   string
+		status   int
+		location string
+	}{
+		{443, http.MethodGet, "http://example.com/a/b?c=d", http.StatusMovedPermanently, "https://example.com/a/b?c=d"},
+		{8443, http.MethodGet, "http://example.com:8080/", http.StatusMovedPermanently, "https://example.com:8443/"},
+		{443, http.MethodPost, "http://[::1]:8080/form", http.StatusPermanentRedirect, "https://[::1]/form"},
+	}
+
+	for _, tc := range cases {
+		c := &core{cfg: &config.Config{HTTPS: config.HTTPS{Port: tc.port}}}
+
+		w := httptest.NewRecorder()
+		c.redirectToHTTPS(w, httptest.NewRequest(tc.method, tc.target, nil))
+
+		if w.Code != tc.status {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.target, tc.status, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != tc.location {
+			t.Errorf("%s %s: expected %s, got %s", tc.method, tc.target, tc.location, got)
+		}
+	}
+}
+
+func TestUpstreamMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	client := writeTestCertificate(t, dir, "client", "gateway.internal")
+
+	clientCA, err := os.ReadFile(client.Cert.Certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(clientCA)
+
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.TLS.ServerName+" "+r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	upstream.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	upstream.StartTLS()
+	t.Cleanup(upstream.Close)
+
+	// the test server certificate is valid for example.com
+	ca := filepath.Join(dir, "upstream-ca.pem")
+	if err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: upstream.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := testService(t, upstream)
+	svc.Protocol = "https"
+	svc.TLS = service.TLS{
+		CA:   ca,
+		Cert: client.Cert.Certificate,
+		Key:  client.Cert.CertificateKey,
+		SNI:  "example.com",
+	}
+
+	anonymous := svc
+	anonymous.TLS = service.TLS{CA: ca, SNI: "example.com"}
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{Name: "mtls", Path: "/mtls", Backend: route.Backend{Service: svc}},
+			{Name: "anonymous", Path: "/anonymous", Backend: route.Backend{Service: anonymous}},
+		},
+	})
+
+	res, err := http.Get(gw.URL + "/mtls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK || string(body) != "example.com gateway.internal" {
+		t.Fatalf("expected the client certificate to reach the upstream, got %d %q", res.StatusCode, body)
+	}
+
+	// the upstream refuses connections without a client certificate
+	res, err = http.Get(gw.URL + "/anonymous")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected 502 without a client certificate, got %d", res.StatusCode)
+	}
+}
+
+func TestUpstreamTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	client := writeTestCertificate(t, dir, "client", "gateway.internal")
+
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	upstream.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	upstream.StartTLS()
+	t.Cleanup(upstream.Close)
+
+	svc := testService(t, upstream)
+	svc.Protocol = "https"
+	svc.TLS = service.TLS{
+		Cert:               client.Cert.Certificate,
+		Key:                client.Cert.CertificateKey,
+		InsecureSkipVerify: true,
+	}
+	cfg := func() *config.Config {
+		return &config.Config{
+			Routes: []route.Route{{Name: "mtls-reload", Path: "/", Backend: route.Backend{Service: svc}}},
+		}
+	}
+
+	gw, _, url := serveTestGateway(t, cfg())
+	if _, body := get(t, url+"/"); body != "gateway.internal" {
+		t.Fatalf("expected the client certificate, got %q", body)
+	}
+
+	// the rotated certificate is read again on reload
+	writeTestCertificate(t, dir, "client", "rotated.internal")
+	if _, body := get(t, url+"/"); body != "gateway.internal" {
+		t.Fatalf("expected the certificate to be kept until a reload, got %q", body)
+	}
+	if err := gw.Reload(cfg()); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := get(t, url+"/"); body != "rotated.internal" {
+		t.Fatalf("expected the rotated certificate after a reload, got %q", body)
+	}
+}
+
+func TestUpstreamTLSInvalidConfig(t *testing.T) {
+	_, err := New("test", &config.Config{
+		Routes: []route.Route{
+			{
+				Name: "mtls",
+				Path: "/",
+				Backend: route.Backend{
+					Service: service.Service{
+						Protocol: "https",
+						Name:     "127.0.0.1",
+						Port:     443,
+						TLS:      service.TLS{CA: "/nonexistent/ca.pem"},
+					},
+				},
+			},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an unreadable upstream ca to fail at startup")
+	}
+}
+
+func TestClientCertificateAuth(t *testing.T) {
+	upstream := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("X-Client-Cert-Subject")+"|"+r.Header.Get("X-Client-Cert"))
+	})
+
+	dir := t.TempDir()
+	client := writeTestCertificate(t, dir, "client", "client.test")
+
+	c, err := New("test", &config.Config{
+		HTTPS: config.HTTPS{
+			Port:            8443,
+			ClientCA:        client.Cert.Certificate,
+			ClientCertHosts: []string{"*.strict.test"},
+		},
+		SSL: []config.SSL{writeTestCertificate(t, dir, "gateway", "gateway.test")},
+		Routes: []route.Route{
+			{
+				Name:       "secure",
+				Path:       "/secure",
+				Backend:    route.Backend{Service: testService(t, upstream)},
+				ClientCert: route.ClientCert{Enable: true, Identity: "cn", PEMHeader: "X-Client-Cert"},
+			},
+			{
+				Name:    "open",
+				Path:    "/open",
+				Backend: route.Backend{Service: testService(t, upstream)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := c.(*core)
+	if err := gw.build(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		gw.lbManager.StopAllHealthChecks()
+	})
+
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = gw.newTLSServer("")
+	srv.TLS = srv.Config.TLSConfig
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	clientCertificate, err := tls.LoadX509KeyPair(client.Cert.Certificate, client.Cert.CertificateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient := func(serverName string, certificates ...tls.Certificate) *http.Client {
+		transport := &http.Transport{
+			TLSClientConfig: &tls.Config{
+				ServerName:         serverName,
+				InsecureSkipVerify: true,
+				Certificates:       certificates,
+			},
+		}
+		t.Cleanup(transport.CloseIdleConnections)
+		return &http.Client{Transport: transport}
+	}
+	get := func(client *http.Client, path string, header ...string) (int, string) {
+		t.Helper()
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	status, body := get(newClient("gateway.test", clientCertificate), "/secure")
+	if status != http.StatusOK {
+		t.Fatalf("expected 200 with a client certificate, got %d %s", status, body)
+	}
+	identity, escaped, _ := strings.Cut(body, "|")
+	if identity != "client.test" {
+		t.Fatalf("expected the certificate common name upstream, got %q", identity)
+	}
+	certPEM, err := url.PathUnescape(escaped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := os.ReadFile(client.Cert.Certificate)
+	if certPEM != string(expected) {
+		t.Fatalf("expected the url-encoded certificate upstream, got %q", certPEM)
+	}
+
+	anonymous := newClient("gateway.test")
+	if status, _ := get(anonymous, "/secure"); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a client certificate, got %d", status)
+	}
+
+	// identity headers sent by clients are dropped
+	status, body = get(anonymous, "/open", "X-Client-Cert-Subject", "admin")
+	if status != http.StatusOK || body != "|" {
+		t.Fatalf("expected no identity upstream, got %d %q", status, body)
+	}
+
+	// client_cert_hosts require the certificate during the handshake
+	if _, err := newClient("api.strict.test").Get(srv.URL + "/open"); err == nil {
+		t.Fatal("expected the handshake without a client certificate to fail")
+	}
+	if status, _ := get(newClient("api.strict.test", clientCertificate), "/open"); status != http.StatusOK {
+		t.Fatalf("expected 200 with a client certificate, got %d", status)
+	}
+}
@@ -0,0 +1,30 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/go-zoox/api-gateway/tracing"
+	"github.com/go-zoox/zoox"
+)
+
+// prepareTracing creates the tracer, which exports spans until the gateway shuts down.
+func (c *core) prepareTracing() error {
+	tracer, err := tracing.New(c.cfg.Tracing, c.version)
+	if err != nil {
+		return fmt.Errorf("failed to prepare tracing: %s", err)
+	}
+
+	c.tracer = tracer
+	return nil
+}
+
+// traceRequest records the server span of a request, continuing the trace of the client.
+func (c *core) traceRequest(ctx *zoox.Context) {
+	req, span := c.tracer.StartServer(ctx.Request)
+	ctx.Request = req
+	defer func() {
+		tracing.EndServer(span, ctx.Writer.Status())
+	}()
+
+	ctx.Next()
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+)
+
+// exportedSpan is the part of a span written by the file exporter that the tests check.
+type exportedSpan struct {
+	Name        string
+	SpanKind    int
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		TraceID string
+		SpanID  string
+	}
+	Attributes []struct {
+		Key   string
+		Value struct {
+			Value any
+		}
+	}
+}
+
+func (s exportedSpan) attribute(key string) any {
+	for _, attr := range s.Attributes {
+		if attr.Key == key {
+			return attr.Value.Value
+		}
+	}
+	return nil
+}
+
+func readSpans(t *testing.T, path string) []exportedSpan {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	spans := []exportedSpan{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var span exportedSpan
+		if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+			t.Fatal(err)
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+func TestTracing(t *testing.T) {
+	traceparent := make(chan string, 1)
+	upstream := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+		w.Write([]byte("ok"))
+	})
+
+	file := filepath.Join(t.TempDir(), "spans.json")
+	gw, _, url := serveTestGateway(t, &config.Config{
+		Tracing: config.Tracing{Enable: true, Exporter: "file", File: file},
+		Routes: []route.Route{
+			{
+				Name:    "traced",
+				Path:    "/api",
+				Backend: route.Backend{Service: testService(t, upstream)},
+			},
+		},
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest("GET", url+"/api/users", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	req.Header.Set("X-Request-Id", "traced-1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	// the backend continues the trace of the client
+	sent := <-traceparent
+	if !strings.HasPrefix(sent, "00-"+traceID+"-") || strings.Contains(sent, "00f067aa0ba902b7") {
+		t.Fatalf("expected the trace to be continued with a span of the gateway, got %q", sent)
+	}
+
+	if err := gw.tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := readSpans(t, file)
+	if len(spans) != 2 {
+		t.Fatalf("expected a server and a client span, got %d", len(spans))
+	}
+
+	var server, client exportedSpan
+	for _, span := range spans {
+		switch span.Name {
+		case "GET /api":
+			server = span
+		case "GET":
+			client = span
+		}
+	}
+
+	if server.SpanContext.TraceID != traceID || server.Parent.SpanID != "00f067aa0ba902b7" {
+		t.Errorf("expected the server span to be a child of the client span, got %+v", server)
+	}
+	if server.attribute("api_gateway.route") != "traced" {
+		t.Errorf("expected the route on the server span, got %v", server.attribute("api_gateway.route"))
+	}
+	if server.attribute("api_gateway.loadbalancer.algorithm") != "round-robin" {
+		t.Errorf("expected the algorithm on the server span, got %v", server.attribute("api_gateway.loadbalancer.algorithm"))
+	}
+	if server.attribute("api_gateway.request_id") != "traced-1" {
+		t.Errorf("expected the request ID on the server span, got %v", server.attribute("api_gateway.request_id"))
+	}
+	if status := server.attribute("http.response.status_code"); status != float64(200) {
+		t.Errorf("expected status 200 on the server span, got %v", status)
+	}
+
+	if client.Parent.SpanID != server.SpanContext.SpanID {
+		t.Errorf("expected the client span to be a child of the server span, got %+v", client)
+	}
+	if sent != "00-"+traceID+"-"+client.SpanContext.SpanID+"-01" {
+		t.Errorf("expected the client span to be sent upstream, got %q", sent)
+	}
+	if client.attribute("api_gateway.upstream.server") == nil {
+		t.Error("expected the selected server on the client span")
+	}
+	if client.attribute("api_gateway.request_id") != "traced-1" {
+		t.Errorf("expected the request ID on the client span, got %v", client.attribute("api_gateway.request_id"))
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	traceparent := make(chan string, 1)
+	upstream := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+	})
+
+	_, _, url := serveTestGateway(t, &config.Config{
+		Backend: route.Backend{Service: testService(t, upstream)},
+	})
+
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req, _ := http.NewRequest("GET", url+"/", nil)
+	req.Header.Set("traceparent", incoming)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	// without tracing, trace headers are passed on untouched
+	if sent := <-traceparent; sent != incoming {
+		t.Fatalf("expected %q, got %q", incoming, sent)
+	}
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// transportPool shares upstream transports between requests with the same settings,
+// so keep-alive connections are reused instead of being dialed per request. It is
+// shared by the generations and uses the TLS configs of the current one: transports
+// built with the certificates of a previous generation are rebuilt.
+type transportPool struct {
+	transports map[transportKey]*pooledTransport
+	tlsConfigs *service.TLSConfigs
+	mu         sync.Mutex
+}
+
+// pooledTransport is a transport and the TLS config it was built with.
+type pooledTransport struct {
+	transport *http.Transport
+	tlsConfig *tls.Config
+}
+
+// transportKey identifies the settings a pooled transport was built with.
+type transportKey struct {
+	// http2 forces HTTP/2: over cleartext (h2c, grpc) or over TLS (grpcs)
+	http2   bool
+	timeout service.Timeout
+	tls     service.TLS
+}
+
+func newTransportPool(tlsConfigs *service.TLSConfigs) *transportPool {
+	return &transportPool{
+		transports: make(map[transportKey]*pooledTransport),
+		tlsConfigs: tlsConfigs,
+	}
+}
+
+// Use switches the pool to the TLS configs of a new generation.
+func (p *transportPool) Use(tlsConfigs *service.TLSConfigs) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.tlsConfigs = tlsConfigs
+}
+
+// Get returns the transport for the given upstream service and timeouts.
+func (p *transportPool) Get(svc *service.Service, timeout service.Timeout) (http.RoundTripper, error) {
+	// Total is enforced on the request context, not by the transport
+	timeout.Total = 0
+
+	key := transportKey{
+		http2:   svc.IsHTTP2(),
+		timeout: timeout,
+		tls:     svc.TLS,
+	}
+	if key == (transportKey{}) {
+		return http.DefaultTransport, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tlsConfig, err := p.tlsConfigs.Get(key.tls)
+	if err != nil {
+		return nil, err
+	}
+
+	if pooled, ok := p.transports[key]; ok {
+		if pooled.tlsConfig == tlsConfig {
+			return pooled.transport, nil
+		}
+		// the certificates were read again by a reload
+		pooled.transport.CloseIdleConnections()
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+	if timeout.Connect > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   timeout.ConnectTimeout(),
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if timeout.TLSHandshake > 0 {
+		transport.TLSHandshakeTimeout = timeout.TLSHandshakeTimeout()
+	}
+	if timeout.ResponseHeader > 0 {
+		transport.ResponseHeaderTimeout = timeout.ResponseHeaderTimeout()
+	}
+	if key.http2 {
+		// http:// requests use h2c with prior knowledge, https:// requests require h2 (ALPN)
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+		transport.Protocols.SetHTTP2(true)
+	}
+
+	p.transports[key] = &pooledTransport{transport: transport, tlsConfig: tlsConfig}
+	return transport, nil
+}
+
+// CloseIdleConnections closes idle keep-alive connections of every pooled transport.
+func (p *transportPool) CloseIdleConnections() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, pooled := range p.transports {
+		pooled.transport.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,218 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-zoox/api-gateway/accesslog"
+	"github.com/go-zoox/api-gateway/core/loadbalancer"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+	"github.com/go-zoox/api-gateway/metrics"
+	"github.com/go-zoox/api-gateway/tracing"
+)
+
+// upstream tracks the backend server a request is proxied to. Retries may move the
+// request to another server of the same backend, so everything derived from the
+// selected server lives here instead of in the proxy closures.
+type upstream struct {
+	route      string
+	backend    *route.NormalizedBackend
+	lb         loadbalancer.LoadBalancer
+	inflight   *loadbalancer.InFlight
+	breakers   *loadbalancer.CircuitBreakers
+	passive    *loadbalancer.PassiveHealthChecks
+	transports *transportPool
+	tracer     *tracing.Tracer
+
+	server  *service.Server
+	service *service.Service
+	timeout service.Timeout
+
+	// defaultTimeout is the gateway-wide timeout that server and service timeouts override
+	defaultTimeout service.Timeout
+
+	// request path and query before any server-specific rewrite
+	path     string
+	rawQuery string
+	// params captured by the route path, expanded in rewrites, headers and query
+	params map[string]string
+
+	mu     sync.Mutex
+	active bool
+	closed bool
+}
+
+func newUpstream(c *core, routeName string, backend *route.NormalizedBackend, lb loadbalancer.LoadBalancer, server *service.Server) *upstream {
+	u := &upstream{
+		route:          routeName,
+		backend:        backend,
+		lb:             lb,
+		inflight:       c.lbManager.GetInFlight(),
+		breakers:       c.lbManager.GetCircuitBreakers(),
+		passive:        c.lbManager.GetPassiveHealthChecks(),
+		transports:     c.transports,
+		tracer:         c.tracer,
+		defaultTimeout: c.cfg.Timeout,
+	}
+	u.use(server)
+	return u
+}
+
+// use points the upstream at server and resolves its effective configuration.
+func (u *upstream) use(server *service.Server) {
+	u.server = server
+	u.service = server.GetEffectiveConfig(u.backend.BaseConfig)
+	u.timeout = u.service.Timeout.WithDefaults(u.defaultTimeout)
+}
+
+// begin opens the in-flight accounting (admin API, least-connections) for the current server.
+func (u *upstream) begin() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.active || u.closed {
+		return
+	}
+	u.start()
+	u.active = true
+}
+
+// switchTo moves the request to server, keeping the in-flight accounting in step.
+func (u *upstream) switchTo(server *service.Server) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.active {
+		u.end()
+		u.active = false
+	}
+	u.use(server)
+	if !u.closed {
+		u.start()
+		u.active = true
+	}
+}
+
+// release closes the in-flight accounting; it is safe to call more than once.
+func (u *upstream) release() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.active {
+		u.end()
+		u.active = false
+	}
+	u.closed = true
+}
+
+func (u *upstream) start() {
+	u.inflight.Start(u.backend, u.server)
+	u.lb.OnRequestStart(u.backend, u.server)
+}
+
+func (u *upstream) end() {
+	u.lb.OnRequestEnd(u.backend, u.server)
+	u.inflight.End(u.backend, u.server)
+}
+
+// next selects the server for a retry, preferring servers that have not been tried yet.
+// It returns the current server when every candidate was already tried.
+func (u *upstream) next(req *http.Request, tried map[string]bool) *service.Server {
+	for range u.backend.Servers {
+		server, err := u.lb.Select(req, u.backend)
+		if err != nil {
+			break
+		}
+		if !tried[server.ID()] {
+			return server
+		}
+	}
+
+	// deterministic algorithms (ip-hash) keep returning the same server
+	for _, server := range u.backend.Servers {
+		if !server.IsDisabled() && server.IsHealthy() && u.breakers.Allow(u.backend, server) && !tried[server.ID()] {
+			return server
+		}
+	}
+
+	return u.server
+}
+
+// apply points req at the current server and applies its path rewrites, request headers and query,
+// with the {name} placeholders of their templates replaced by the route params.
+func (u *upstream) apply(req *http.Request) {
+	req.URL.Scheme = u.service.Scheme()
+	req.URL.Host = u.server.Host()
+	req.Host = req.URL.Host
+
+	// apply path rewrite using effective service config
+	req.URL.Path = u.service.RewriteParams(u.path, u.params)
+	req.URL.RawQuery = u.rawQuery
+
+	// apply headers
+	if u.service.Request.Headers != nil {
+		for k, v := range u.service.Request.Headers {
+			req.Header.Set(k, service.Expand(v, u.params))
+		}
+	}
+
+	// apply query
+	if u.service.Request.Query != nil {
+		originQuery := req.URL.Query()
+		for k, v := range u.service.Request.Query {
+			originQuery.Set(k, service.Expand(v, u.params))
+		}
+		req.URL.RawQuery = originQuery.Encode()
+	}
+}
+
+// RoundTrip sends req to the current server and reports the outcome to its circuit breaker
+// and passive health check.
+func (u *upstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := u.transports.Get(u.service, u.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream tls config: %w", err)
+	}
+
+	done := u.track(req, req.Header)
+	res, err := transport.RoundTrip(req)
+	done(res, err)
+	return res, err
+}
+
+// track starts an exchange with the current server for req, whose trace context is written
+// into header; the returned function reports its outcome to the client span, and to the
+// server's circuit breaker and passive health check.
+func (u *upstream) track(req *http.Request, header http.Header) func(res *http.Response, err error) {
+	server := u.server
+	span := u.tracer.StartClient(req, header, server.ID())
+	call := u.breakers.Begin(u.backend, server)
+	start := time.Now()
+
+	return func(res *http.Response, err error) {
+		tracing.EndClient(span, res, err)
+
+		status := 0
+		if err == nil {
+			status = res.StatusCode
+		}
+		accesslog.SetUpstream(req, server.Host(), status, time.Since(start))
+
+		// the client going away says nothing about the server
+		if errors.Is(err, context.Canceled) {
+			call.Abort()
+			return
+		}
+
+		metrics.UpstreamDuration.WithLabelValues(u.route, server.ID(), metrics.StatusClass(status)).Observe(time.Since(start).Seconds())
+
+		failed := err != nil || res.StatusCode >= http.StatusInternalServerError
+		call.Done(failed, time.Since(start))
+		u.passive.Record(u.backend, server, failed)
+	}
+}
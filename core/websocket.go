@@ -0,0 +1,473 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/logger"
+	"github.com/go-zoox/proxy"
+	"github.com/go-zoox/zoox"
+	"github.com/gorilla/websocket"
+)
+
+// websocketHandshakeHeaders are negotiated separately on each side of the relay.
+var websocketHandshakeHeaders = []string{
+	"Connection",
+	"Upgrade",
+	"Sec-Websocket-Key",
+	"Sec-Websocket-Version",
+	"Sec-Websocket-Extensions",
+	"Sec-Websocket-Protocol",
+	"Sec-Websocket-Accept",
+}
+
+// websocketHopHeaders are hop-by-hop headers that are not forwarded.
+var websocketHopHeaders = []string{
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+}
+
+// websocketCounter tracks open WebSocket connections per route, and their relays so
+// they can be drained on shutdown (the HTTP servers do not track hijacked connections).
+type websocketCounter struct {
+	counts map[string]int64
+	relays map[*websocketRelay]struct{}
+	mu     sync.Mutex
+}
+
+func newWebSocketCounter() *websocketCounter {
+	return &websocketCounter{
+		counts: make(map[string]int64),
+		relays: make(map[*websocketRelay]struct{}),
+	}
+}
+
+// Acquire reserves a connection on the route, reporting false when max (> 0) is reached.
+func (w *websocketCounter) Acquire(key string, max int64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if max > 0 && w.counts[key] >= max {
+		return false
+	}
+	w.counts[key]++
+	return true
+}
+
+// Release frees a connection reserved by Acquire.
+func (w *websocketCounter) Release(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.counts[key] <= 1 {
+		delete(w.counts, key)
+		return
+	}
+	w.counts[key]--
+}
+
+// Count returns the open connections on the route.
+func (w *websocketCounter) Count(key string) int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.counts[key]
+}
+
+// Total returns the open connections on all routes, including handshakes in progress.
+func (w *websocketCounter) Total() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var total int64
+	for _, count := range w.counts {
+		total += count
+	}
+	return total
+}
+
+// Track registers a running relay until the returned func is called.
+func (w *websocketCounter) Track(relay *websocketRelay) func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.relays[relay] = struct{}{}
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		delete(w.relays, relay)
+	}
+}
+
+// Drain waits until every connection has closed, or ctx is done.
+func (w *websocketCounter) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for w.Total() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// CloseAll closes the running relays, telling both peers the gateway is going away.
+func (w *websocketCounter) CloseAll() {
+	w.mu.Lock()
+	relays := make([]*websocketRelay, 0, len(w.relays))
+	for relay := range w.relays {
+		relays = append(relays, relay)
+	}
+	w.mu.Unlock()
+
+	for _, relay := range relays {
+		relay.goAway()
+	}
+}
+
+// serveWebSocket terminates the client handshake, dials the selected server with the
+// route's rewrites and headers applied, and relays messages until either side closes.
+// It blocks for the lifetime of the connection, so the upstream stays in flight
+// (least-connections) until the socket is closed.
+func (c *core) serveWebSocket(ctx *zoox.Context, cfg *proxyConfig, r *route.Route, u *upstream) {
+	inReq := ctx.Request
+	opts := r.WebSocket
+
+	key := r.Name + "\x00" + r.Path
+	if !c.websockets.Acquire(key, opts.MaxConnections) {
+		cfg.OnError(proxy.NewHTTPError(http.StatusServiceUnavailable, ErrTooManyWebSocketConnections.Error()), ctx.Writer, inReq)
+		return
+	}
+	defer c.websockets.Release(key)
+
+	// path rewrites, request headers, query and plugins (ratelimit, ...)
+	outReq := inReq.Clone(inReq.Context())
+	if err := cfg.OnRequest(outReq, inReq); err != nil {
+		cfg.OnError(err, ctx.Writer, inReq)
+		return
+	}
+
+	target := *outReq.URL
+	if target.Scheme == "https" {
+		target.Scheme = "wss"
+	} else {
+		target.Scheme = "ws"
+	}
+
+	header := outReq.Header.Clone()
+	for _, h := range websocketHandshakeHeaders {
+		header.Del(h)
+	}
+	for _, h := range websocketHopHeaders {
+		header.Del(h)
+	}
+	header.Set("Host", outReq.Host)
+	if ip, _, err := net.SplitHostPort(inReq.RemoteAddr); err == nil {
+		if prior := header.Get("X-Forwarded-For"); prior != "" {
+			ip = prior + ", " + ip
+		}
+		header.Set("X-Forwarded-For", ip)
+	}
+
+	connectTimeout := u.timeout.ConnectTimeout()
+	if connectTimeout == 0 {
+		connectTimeout = 30 * time.Second
+	}
+	handshakeTimeout := u.timeout.ResponseHeaderTimeout()
+	if handshakeTimeout == 0 {
+		handshakeTimeout = 30 * time.Second
+	}
+
+	tlsConfig, err := c.upstreamTLS.Get(u.service.TLS)
+	if err != nil {
+		cfg.OnError(fmt.Errorf("invalid upstream tls config: %w", err), ctx.Writer, inReq)
+		return
+	}
+
+	dialer := &websocket.Dialer{
+		NetDialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		HandshakeTimeout: handshakeTimeout,
+		Subprotocols:     websocket.Subprotocols(inReq),
+		TLSClientConfig:  tlsConfig,
+	}
+
+	done := u.track(outReq, header)
+	backend, res, err := dialer.DialContext(inReq.Context(), target.String(), header)
+	if err != nil {
+		if res == nil {
+			done(nil, err)
+			cfg.OnError(err, ctx.Writer, inReq)
+			return
+		}
+
+		// the server refused the upgrade; relay its answer
+		done(res, nil)
+		defer res.Body.Close()
+		for k, vv := range res.Header {
+			for _, v := range vv {
+				ctx.Writer.Header().Add(k, v)
+			}
+		}
+		ctx.Writer.WriteHeader(res.StatusCode)
+		_, _ = io.Copy(ctx.Writer, res.Body)
+		return
+	}
+	done(res, nil)
+	defer backend.Close()
+
+	// response headers, plugins (cors, ...) and X-Powered-By, as for proxied responses
+	res.Body = http.NoBody
+	if err := cfg.OnResponse(res, inReq); err != nil {
+		cfg.OnError(err, ctx.Writer, inReq)
+		return
+	}
+
+	responseHeader := res.Header.Clone()
+	for _, h := range websocketHandshakeHeaders {
+		responseHeader.Del(h)
+	}
+	for _, h := range websocketHopHeaders {
+		responseHeader.Del(h)
+	}
+	responseHeader.Del("Date")
+
+	upgrader := websocket.Upgrader{
+		HandshakeTimeout: handshakeTimeout,
+		// origins are enforced by the cors plugin
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+	if protocol := backend.Subprotocol(); protocol != "" {
+		upgrader.Subprotocols = []string{protocol}
+	}
+
+	client, err := upgrader.Upgrade(ctx.Writer, inReq, responseHeader)
+	if err != nil {
+		// Upgrade has already answered the client
+		ctx.Logger.Warnf("[websocket] failed to upgrade client connection: %s", err)
+		return
+	}
+	defer client.Close()
+
+	ctx.Logger.Infof("[websocket][route: %s] connected %s => %s", r.Name, inReq.RemoteAddr, target.String())
+	start := time.Now()
+
+	relay := newWebSocketRelay(client, backend, opts)
+	untrack := c.websockets.Track(relay)
+	reason := relay.run()
+	untrack()
+
+	ctx.Logger.Infof("[websocket][route: %s] closed %s => %s after %s (%s)", r.Name, inReq.RemoteAddr, target.String(), time.Since(start).Round(time.Millisecond), reason)
+}
+
+// websocketRelay copies messages between the client and backend connections.
+type websocketRelay struct {
+	client  *websocket.Conn
+	backend *websocket.Conn
+
+	idleTimeout  time.Duration
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+
+	// lastActivity is the unix nano time of the last relayed message
+	lastActivity atomic.Int64
+
+	done chan struct{}
+	once sync.Once
+	// reason records why the relay stopped
+	reason string
+}
+
+func newWebSocketRelay(client, backend *websocket.Conn, opts route.WebSocket) *websocketRelay {
+	pongTimeout := time.Duration(opts.PongTimeout) * time.Second
+	if pongTimeout <= 0 {
+		pongTimeout = 10 * time.Second
+	}
+
+	return &websocketRelay{
+		client:       client,
+		backend:      backend,
+		idleTimeout:  time.Duration(opts.IdleTimeout) * time.Second,
+		pingInterval: time.Duration(opts.PingInterval) * time.Second,
+		pongTimeout:  pongTimeout,
+		done:         make(chan struct{}),
+	}
+}
+
+// run relays messages until either peer closes, a ping goes unanswered or the
+// connection is idle for too long. It returns the reason the relay stopped.
+func (r *websocketRelay) run() string {
+	r.touch()
+
+	for _, conn := range []*websocket.Conn{r.client, r.backend} {
+		r.keepalive(conn)
+	}
+
+	go r.copy(r.backend, r.client, "client")
+	go r.copy(r.client, r.backend, "backend")
+
+	if r.idleTimeout > 0 {
+		go r.watchIdle()
+	}
+
+	<-r.done
+	return r.reason
+}
+
+// stop ends the relay; the first reason wins.
+func (r *websocketRelay) stop(reason string) {
+	r.once.Do(func() {
+		r.reason = reason
+		close(r.done)
+		r.client.Close()
+		r.backend.Close()
+	})
+}
+
+// goAway closes both peers with 1001 (going away) and ends the relay.
+func (r *websocketRelay) goAway() {
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "gateway shutting down")
+	deadline := time.Now().Add(time.Second)
+	for _, conn := range []*websocket.Conn{r.client, r.backend} {
+		_ = conn.WriteControl(websocket.CloseMessage, message, deadline)
+	}
+	r.stop("gateway shutting down")
+}
+
+func (r *websocketRelay) touch() {
+	r.lastActivity.Store(time.Now().UnixNano())
+}
+
+// copy forwards messages read from src (named from) to dst.
+func (r *websocketRelay) copy(dst, src *websocket.Conn, from string) {
+	for {
+		messageType, data, err := src.ReadMessage()
+		if err != nil {
+			r.forwardClose(dst, err)
+			r.stop(describeWebSocketClose(from, err))
+			return
+		}
+
+		r.touch()
+		r.extendReadDeadline(src)
+
+		if err := dst.WriteMessage(messageType, data); err != nil {
+			r.stop("write failed: " + err.Error())
+			return
+		}
+	}
+}
+
+// forwardClose passes the close code of one peer on to the other.
+func (r *websocketRelay) forwardClose(dst *websocket.Conn, err error) {
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		switch closeErr.Code {
+		case websocket.CloseNoStatusReceived:
+			message = []byte{}
+		case websocket.CloseAbnormalClosure, websocket.CloseTLSHandshake:
+			// reserved codes that must not be sent on the wire
+		default:
+			message = websocket.FormatCloseMessage(closeErr.Code, closeErr.Text)
+		}
+	}
+
+	_ = dst.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+}
+
+// keepalive pings conn every ping interval and closes it when a pong does not arrive in time.
+func (r *websocketRelay) keepalive(conn *websocket.Conn) {
+	if r.pingInterval <= 0 {
+		return
+	}
+
+	r.extendReadDeadline(conn)
+	conn.SetPongHandler(func(string) error {
+		r.extendReadDeadline(conn)
+		return nil
+	})
+
+	go func() {
+		ticker := time.NewTicker(r.pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(r.pongTimeout)); err != nil {
+					r.stop("ping failed: " + err.Error())
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (r *websocketRelay) extendReadDeadline(conn *websocket.Conn) {
+	if r.pingInterval > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(r.pingInterval + r.pongTimeout))
+	}
+}
+
+// watchIdle closes the relay once no message was relayed for the idle timeout.
+func (r *websocketRelay) watchIdle() {
+	timer := time.NewTimer(r.idleTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-timer.C:
+			idle := time.Since(time.Unix(0, r.lastActivity.Load()))
+			if idle >= r.idleTimeout {
+				r.stop("idle timeout")
+				return
+			}
+			timer.Reset(r.idleTimeout - idle)
+		}
+	}
+}
+
+func describeWebSocketClose(from string, err error) string {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return from + " closed: " + closeErr.Error()
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return from + " did not answer ping"
+	}
+
+	if strings.Contains(err.Error(), "use of closed network connection") {
+		return from + " connection closed"
+	}
+
+	logger.Debugf("[websocket] %s read error: %s", from, err)
+	return from + " read failed: " + err.Error()
+}
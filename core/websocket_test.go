@@ -0,0 +1,297 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+	"github.com/gorilla/websocket"
+)
+
+// newEchoWebSocketUpstream echoes every message, prefixed with name and the request path and header.
+func newEchoWebSocketUpstream(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	return newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, http.Header{"X-Upstream": {name}})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			reply := name + " " + r.URL.Path + " " + r.Header.Get("X-Gateway") + ": " + string(data)
+			if err := conn.WriteMessage(messageType, []byte(reply)); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func dialWebSocket(t *testing.T, gw *httptest.Server, path string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+
+	conn, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(gw.URL, "http")+path, nil)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, res, err
+}
+
+func echo(t *testing.T, conn *websocket.Conn, message string) string {
+	t.Helper()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+		t.Fatal(err)
+	}
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWebSocketProxy(t *testing.T) {
+	upstream := newEchoWebSocketUpstream(t, "echo")
+
+	svc := testService(t, upstream)
+	svc.Request = service.Request{
+		Path:    service.RequestPath{Rewrites: []string{"^/ws/(.*):/$1"}},
+		Headers: map[string]string{"X-Gateway": "yes"},
+	}
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "ws",
+				Path:    "/ws",
+				Backend: route.Backend{Service: svc},
+			},
+		},
+	})
+
+	conn, res, err := dialWebSocket(t, gw, "/ws/chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Header.Get("X-Upstream") != "echo" {
+		t.Fatalf("expected upstream handshake headers to be relayed, got %v", res.Header)
+	}
+
+	if got := echo(t, conn, "hello"); got != "echo /chat yes: hello" {
+		t.Fatalf("unexpected reply %q", got)
+	}
+	if got := echo(t, conn, "again"); got != "echo /chat yes: again" {
+		t.Fatalf("unexpected reply %q", got)
+	}
+}
+
+func TestWebSocketMaxConnections(t *testing.T) {
+	upstream := newEchoWebSocketUpstream(t, "echo")
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:      "ws",
+				Path:      "/ws",
+				Backend:   route.Backend{Service: testService(t, upstream)},
+				WebSocket: route.WebSocket{MaxConnections: 1},
+			},
+		},
+	})
+
+	first, _, err := dialWebSocket(t, gw, "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo(t, first, "hello")
+
+	_, res, err := dialWebSocket(t, gw, "/ws")
+	if err == nil {
+		t.Fatalf("expected the second connection to be rejected")
+	}
+	if res == nil || res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %v", res)
+	}
+
+	// closing the first connection frees the slot
+	first.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, _, err := dialWebSocket(t, gw, "/ws")
+		if err == nil {
+			echo(t, conn, "hello")
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the slot to be freed: %s", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestWebSocketLeastConnectionsLifetime(t *testing.T) {
+	first := newEchoWebSocketUpstream(t, "first")
+	second := newEchoWebSocketUpstream(t, "second")
+
+	firstServer, secondServer := testServer(t, first), testServer(t, second)
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name: "ws",
+				Path: "/ws",
+				Backend: route.Backend{
+					Service: service.Service{
+						Protocol:  "http",
+						Algorithm: "least-connections",
+						Servers: []service.Server{
+							{Name: firstServer.Name, Port: firstServer.Port},
+							{Name: secondServer.Name, Port: secondServer.Port},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	a, _, err := dialWebSocket(t, gw, "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := dialWebSocket(t, gw, "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the open socket keeps counting as a connection, so the second one goes elsewhere
+	replyA, replyB := echo(t, a, "hi"), echo(t, b, "hi")
+	if strings.Fields(replyA)[0] == strings.Fields(replyB)[0] {
+		t.Fatalf("expected the sockets on different servers, got %q and %q", replyA, replyB)
+	}
+}
+
+func TestWebSocketIdleTimeout(t *testing.T) {
+	upstream := newEchoWebSocketUpstream(t, "echo")
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:      "ws",
+				Path:      "/ws",
+				Backend:   route.Backend{Service: testService(t, upstream)},
+				WebSocket: route.WebSocket{IdleTimeout: 1},
+			},
+		},
+	})
+
+	conn, _, err := dialWebSocket(t, gw, "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo(t, conn, "hello")
+
+	start := time.Now()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatalf("expected the idle connection to be closed")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("idle timeout was not enforced, took %s", elapsed)
+	}
+}
+
+func TestWebSocketPingTimeout(t *testing.T) {
+	upstream := newEchoWebSocketUpstream(t, "echo")
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:      "ws",
+				Path:      "/ws",
+				Backend:   route.Backend{Service: testService(t, upstream)},
+				WebSocket: route.WebSocket{PingInterval: 1, PongTimeout: 1},
+			},
+		},
+	})
+
+	conn, _, err := dialWebSocket(t, gw, "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a client that reads answers pings and stays connected
+	pings := 0
+	conn.SetPingHandler(func(data string) error {
+		pings++
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	_ = conn.SetReadDeadline(time.Now().Add(2500 * time.Millisecond))
+	if _, _, err := conn.ReadMessage(); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected the client read to time out, got %v", err)
+	}
+	if pings == 0 {
+		t.Fatalf("expected the gateway to ping the client")
+	}
+
+	// a client that stops reading does not answer pings and is disconnected
+	silent, _, err := dialWebSocket(t, gw, "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(3500 * time.Millisecond)
+
+	silent.SetPingHandler(func(string) error { return nil })
+	_ = silent.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := silent.ReadMessage(); err != nil {
+			if strings.Contains(err.Error(), "timeout") {
+				t.Fatalf("expected the silent connection to be closed by the gateway")
+			}
+			break
+		}
+	}
+}
+
+func TestWebSocketRateLimitHandshake(t *testing.T) {
+	upstream := newEchoWebSocketUpstream(t, "echo")
+
+	gw := newTestGateway(t, &config.Config{
+		Routes: []route.Route{
+			{
+				Name:    "ws",
+				Path:    "/ws",
+				Backend: route.Backend{Service: testService(t, upstream)},
+				RateLimit: route.RateLimit{
+					Enable:    true,
+					Algorithm: "fixed-window",
+					KeyType:   "ip",
+					Limit:     1,
+					Window:    60,
+				},
+			},
+		},
+	})
+
+	if _, _, err := dialWebSocket(t, gw, "/ws"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, res, err := dialWebSocket(t, gw, "/ws")
+	if err == nil {
+		t.Fatalf("expected the handshake to be rate limited")
+	}
+	if res == nil || res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %v", res)
+	}
+}
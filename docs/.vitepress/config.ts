@@ -57,7 +57,8 @@ export default defineConfig({
                 { text: 'Configuration', link: '/guide/configuration' },
                 { text: 'Routing', link: '/guide/routing' },
                 { text: 'Load Balancing', link: '/guide/load-balancing' },
-                { text: 'Health Check', link: '/guide/health-check' }
+                { text: 'Health Check', link: '/guide/health-check' },
+                { text: 'Observability', link: '/guide/observability' }
               ]
             },
             {
@@ -72,6 +73,7 @@ export default defineConfig({
               items: [
                 { text: 'Overview', link: '/guide/plugins/' },
                 { text: 'Base URI', link: '/guide/plugins/base-uri' },
+                { text: 'Auth', link: '/guide/plugins/auth' },
                 { text: 'Rate limiting', link: '/guide/plugins/rate-limit' },
                 { text: 'JSON audit', link: '/guide/plugins/json-audit' }
               ]
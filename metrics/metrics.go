@@ -0,0 +1,185 @@
+// Package metrics holds the Prometheus metrics of the gateway. The core and the plugins
+// record into them, and the gateway serves them on the metrics path.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "api_gateway"
+
+// Registry holds the metrics of the gateway, with the Go runtime and process metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Requests counts the requests served, by route, method and status class
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Requests served, by route, method and status class.",
+	}, []string{"route", "method", "status"})
+
+	// RequestDuration observes how long requests take, from arrival to the end of the response
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Time to serve requests, by route, method and status class.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// RequestsInFlight is the number of requests being served per route
+	RequestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "requests_in_flight",
+		Help:      "Requests being served, by route.",
+	}, []string{"route"})
+
+	// RequestBytes counts the bytes of request bodies received
+	RequestBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "request_bytes_total",
+		Help:      "Bytes of request bodies received, by route and method.",
+	}, []string{"route", "method"})
+
+	// ResponseBytes counts the bytes of response bodies sent
+	ResponseBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "response_bytes_total",
+		Help:      "Bytes of response bodies sent, by route, method and status class.",
+	}, []string{"route", "method", "status"})
+
+	// UpstreamDuration observes how long upstream servers take to answer with the response headers
+	UpstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_duration_seconds",
+		Help:      "Time until upstream servers answer with the response headers, by route, server and status class.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "server", "status"})
+
+	// Selections counts the servers chosen by load balancing
+	Selections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loadbalancer_selections_total",
+		Help:      "Servers selected by load balancing, by route, server and algorithm.",
+	}, []string{"route", "server", "algorithm"})
+
+	// SplitRequests counts the backends chosen by routes split between several backends
+	SplitRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "split_requests_total",
+		Help:      "Requests of routes split between several backends, by route and backend.",
+	}, []string{"route", "backend"})
+
+	// RateLimit counts the requests checked by rate limiting, by result (allowed, denied)
+	RateLimit = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ratelimit_requests_total",
+		Help:      "Requests checked by rate limiting, by route and result (allowed, denied).",
+	}, []string{"route", "result"})
+
+	// Auth counts the requests checked by authentication, by type and result (allowed, denied)
+	Auth = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_requests_total",
+		Help:      "Requests checked by authentication, by route, type (basic, bearer, jwt) and result (allowed, denied).",
+	}, []string{"route", "type", "result"})
+
+	// HTTPCache counts the requests looked up in the HTTP cache, by result (hit, miss, bypass)
+	HTTPCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "httpcache_requests_total",
+		Help:      "Requests on routes with the HTTP cache, by route and result (hit, miss, bypass).",
+	}, []string{"route", "result"})
+
+	// IPPolicyDenied counts the requests denied by the IP policy
+	IPPolicyDenied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ippolicy_denied_total",
+		Help:      "Requests denied by the IP policy, by route.",
+	}, []string{"route"})
+
+	// MirrorRequests counts the copies sent to mirror backends, by the status class of the
+	// primary and of the mirror response
+	MirrorRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mirror_requests_total",
+		Help:      "Requests mirrored, by route, primary status class and mirror status class.",
+	}, []string{"route", "primary_status", "mirror_status"})
+
+	// MirrorDuration observes how long the primary and the mirror backend take to answer the
+	// mirrored requests
+	MirrorDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mirror_duration_seconds",
+		Help:      "Time until the primary and the mirror backend answer mirrored requests with the response headers, by route and target (primary, mirror).",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "target"})
+
+	// MirrorSkipped counts the requests picked for mirroring but not mirrored
+	MirrorSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mirror_skipped_total",
+		Help:      "Requests not mirrored, by route and reason (body_too_large, max_in_flight, no_server).",
+	}, []string{"route", "reason"})
+
+	// JSONAuditSinkFailures counts the audit lines a sink failed to write
+	JSONAuditSinkFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jsonaudit_sink_failures_total",
+		Help:      "Audit lines the JSON audit sink failed to write, by sink (file, http, database).",
+	}, []string{"sink"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Requests,
+		RequestDuration,
+		RequestsInFlight,
+		RequestBytes,
+		ResponseBytes,
+		UpstreamDuration,
+		Selections,
+		SplitRequests,
+		RateLimit,
+		Auth,
+		HTTPCache,
+		IPPolicyDenied,
+		MirrorRequests,
+		MirrorDuration,
+		MirrorSkipped,
+		JSONAuditSinkFailures,
+	)
+}
+
+// Handler serves the metrics of Registry, along with those of gatherers.
+func Handler(gatherers ...prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(append(prometheus.Gatherers{Registry}, gatherers...), promhttp.HandlerOpts{})
+}
+
+// StatusClass returns the class of an HTTP status (2xx, 4xx, ...), or "error" when no
+// status was received.
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "error"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// Method returns the method label of a request; methods outside the standard ones are
+// reported as OTHER, so clients cannot grow the number of series.
+func Method(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
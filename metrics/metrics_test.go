@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatusClass(t *testing.T) {
+	cases := map[int]string{
+		200: "2xx",
+		204: "2xx",
+		301: "3xx",
+		429: "4xx",
+		503: "5xx",
+		0:   "error",
+		700: "error",
+	}
+	for status, want := range cases {
+		if got := StatusClass(status); got != want {
+			t.Errorf("StatusClass(%d) = %s, want %s", status, got, want)
+		}
+	}
+}
+
+func TestMethod(t *testing.T) {
+	if got := Method("GET"); got != "GET" {
+		t.Errorf("expected GET, got %s", got)
+	}
+	if got := Method("PROPFIND"); got != "OTHER" {
+		t.Errorf("expected OTHER, got %s", got)
+	}
+}
+
+func TestRouteInFlight(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+
+	// without Observe, nothing is recorded
+	SetRoute(req, "unobserved")
+	if name := RouteName(req); name != "" {
+		t.Fatalf("expected no route, got %q", name)
+	}
+
+	req = Observe(req)
+	SetRoute(req, "observed")
+	SetRoute(req, "other")
+	if name := RouteName(req); name != "observed" {
+		t.Fatalf("expected the first route to be kept, got %q", name)
+	}
+
+	if route, matched := Done(req); route != "observed" || !matched {
+		t.Fatalf("expected route observed, got %q %v", route, matched)
+	}
+	Done(req)
+
+	// Done counts once
+	res := httptest.NewRecorder()
+	Handler().ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(res.Body.String(), `api_gateway_requests_in_flight{route="observed"} 0`+"\n") {
+		t.Fatal("expected no request in flight on route observed")
+	}
+}
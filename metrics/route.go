@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+)
+
+type routeKey struct{}
+
+// observation is the per-request state of the request metrics.
+type observation struct {
+	route   string
+	matched bool
+}
+
+// Observe returns req prepared for the request metrics; the route it matches is recorded
+// with SetRoute.
+func Observe(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), routeKey{}, &observation{}))
+}
+
+// SetRoute records the route req matched, counting it in flight on the route until Done.
+func SetRoute(req *http.Request, name string) {
+	o, ok := req.Context().Value(routeKey{}).(*observation)
+	if !ok || o.matched {
+		return
+	}
+
+	o.route = name
+	o.matched = true
+	RequestsInFlight.WithLabelValues(name).Inc()
+}
+
+// Done ends the in-flight count of a request, and returns the route it matched. Only the
+// first call counts.
+func Done(req *http.Request) (route string, matched bool) {
+	o, ok := req.Context().Value(routeKey{}).(*observation)
+	if !ok || !o.matched {
+		return "", false
+	}
+
+	o.matched = false
+	RequestsInFlight.WithLabelValues(o.route).Dec()
+	return o.route, true
+}
+
+// RouteName returns the name of the route req matched, empty before it is matched or
+// when metrics are disabled.
+func RouteName(req *http.Request) string {
+	if o, ok := req.Context().Value(routeKey{}).(*observation); ok {
+		return o.route
+	}
+	return ""
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/core/service"
+	"github.com/go-zoox/api-gateway/metrics"
+	"github.com/go-zoox/api-gateway/plugin"
+	"github.com/go-zoox/api-gateway/router"
+	"github.com/go-zoox/api-gateway/tracing"
+	"github.com/go-zoox/proxy"
+	"github.com/go-zoox/zoox"
+)
+
+// Auth authenticates the requests of the services with an auth type (basic, bearer or jwt),
+// with the auth of the server selected for the request when the server overrides it, and
+// answers the others with 401 and a WWW-Authenticate challenge.
+type Auth struct {
+	plugin.Plugin
+
+	cfg *config.Config
+	// authenticators are the compiled auth configurations
+	authenticators []compiled
+}
+
+// compiled is an auth configuration with its authenticator.
+type compiled struct {
+	auth          service.Auth
+	authenticator authenticator
+}
+
+// reservedTypes are auth types accepted by the configuration before they were enforced;
+// their requests pass through, as they always did.
+var reservedTypes = []string{"oauth2", "oidc", "service"}
+
+// authenticator checks the credentials of a request for one auth configuration.
+type authenticator interface {
+	// authenticate returns why req is not authenticated, or nil
+	authenticate(req *http.Request) error
+	// challenge returns the WWW-Authenticate header of a request denied with err
+	challenge(err error) string
+}
+
+// New creates the auth plugin.
+func New() *Auth {
+	return &Auth{}
+}
+
+// Prepare compiles the auth of every service and server, so invalid htpasswd files and
+// public keys fail at startup.
+func (p *Auth) Prepare(app *zoox.Application, cfg *config.Config) error {
+	app.Logger().Infof("[plugin:auth] prepare ...")
+	p.cfg = cfg
+
+	p.authenticators = nil
+	warned := map[string]bool{}
+	for _, a := range Configs(cfg) {
+		if slices.Contains(reservedTypes, a.Type) {
+			if !warned[a.Type] {
+				app.Logger().Warnf("[plugin:auth] auth type %s is not supported yet, its requests pass through unauthenticated", a.Type)
+				warned[a.Type] = true
+			}
+			continue
+		}
+		if p.lookup(&a) != nil {
+			continue
+		}
+
+		authn, err := newAuthenticator(a)
+		if err != nil {
+			return fmt.Errorf("auth: %s", err)
+		}
+		p.authenticators = append(p.authenticators, compiled{auth: a, authenticator: authn})
+	}
+
+	app.Logger().Infof("[plugin:auth] initialized")
+	return nil
+}
+
+// OnRequest denies the requests without valid credentials.
+func (p *Auth) OnRequest(ctx *zoox.Context, req *http.Request) error {
+	a := p.configFor(req)
+	if a == nil || a.Type == "" || slices.Contains(reservedTypes, a.Type) {
+		return nil
+	}
+
+	authn, err := p.authenticator(a)
+	if err != nil {
+		ctx.Logger.Errorf("[plugin:auth] %s", err)
+		return proxy.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if err := authn.authenticate(req); err != nil {
+		metrics.Auth.WithLabelValues(metrics.RouteName(req), a.Type, "denied").Inc()
+		tracing.SetAttributes(req, tracing.AuthKey.String("denied"))
+
+		if ctx.Writer != nil {
+			ctx.Writer.Header().Set("WWW-Authenticate", authn.challenge(err))
+		}
+		ctx.Logger.Warnf("[plugin:auth] %s authentication failed: %s", a.Type, err)
+		return proxy.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	metrics.Auth.WithLabelValues(metrics.RouteName(req), a.Type, "allowed").Inc()
+	tracing.SetAttributes(req, tracing.AuthKey.String("allowed"))
+	return nil
+}
+
+// OnResponse is a no-op.
+func (p *Auth) OnResponse(ctx *zoox.Context, res *http.Response) error {
+	return nil
+}
+
+// configFor returns the auth of the server selected for req, or of the service of its
+// route when no server is selected yet.
+func (p *Auth) configFor(req *http.Request) *service.Auth {
+	if svc := service.FromRequest(req); svc != nil {
+		return &svc.Auth
+	}
+
+	rt := router.FromRequest(req)
+	if rt == nil {
+		return nil
+	}
+	return &rt.Backend.Service.Auth
+}
+
+// authenticator returns the compiled a, compiling it when it is not known.
+func (p *Auth) authenticator(a *service.Auth) (authenticator, error) {
+	if authn := p.lookup(a); authn != nil {
+		return authn, nil
+	}
+	return newAuthenticator(*a)
+}
+
+// lookup returns the authenticator compiled by Prepare for a, or nil. Requests carry
+// copies of the configuration, so a is compared by value.
+func (p *Auth) lookup(a *service.Auth) authenticator {
+	for i := range p.authenticators {
+		if c := &p.authenticators[i]; c.auth.Type == a.Type && reflect.DeepEqual(&c.auth, a) {
+			return c.authenticator
+		}
+	}
+	return nil
+}
+
+func newAuthenticator(a service.Auth) (authenticator, error) {
+	switch a.Type {
+	case "basic":
+		return newBasic(a)
+	case "bearer":
+		return newBearer(a)
+	case "jwt":
+		return newJWT(a)
+	default:
+		return nil, fmt.Errorf("unsupported auth type: %s", a.Type)
+	}
+}
+
+// Configs returns the auth configurations with a type of the services of cfg, and of their
+// servers overriding it.
+func Configs(cfg *config.Config) []service.Auth {
+	backends := []route.Backend{cfg.Backend}
+	for _, h := range cfg.Hosts {
+		backends = append(backends, h.Backend)
+	}
+	for _, rt := range cfg.Routes {
+		backends = append(backends, rt.Backend)
+		for _, split := range rt.Backends {
+			backends = append(backends, split.Backend())
+		}
+	}
+
+	auths := []service.Auth{}
+	for _, backend := range backends {
+		svc := backend.Service
+		if svc.Auth.Type != "" {
+			auths = append(auths, svc.Auth)
+		}
+		for i := range svc.Servers {
+			if override := svc.Servers[i].Auth; override != nil && override.Type != "" {
+				auths = append(auths, *override)
+			}
+		}
+	}
+	return auths
+}
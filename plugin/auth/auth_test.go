@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/core/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashPassword(t *testing.T, password string) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func basicRequest(username, password string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "http://gateway/api", nil)
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	return req
+}
+
+func TestBasic(t *testing.T) {
+	htpasswd := writeFile(t, "htpasswd", "# users\nalice:"+hashPassword(t, "wonderland")+"\n\nbob:"+hashPassword(t, "builder")+"\n")
+
+	b, err := newBasic(service.Auth{Type: "basic", Username: "ops", Password: "secret", HTPasswd: htpasswd})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		username, password string
+		want               error
+	}{
+		{"ops", "secret", nil},
+		{"alice", "wonderland", nil},
+		{"bob", "builder", nil},
+		{"ops", "wrong", ErrInvalidCredentials},
+		{"alice", "builder", ErrInvalidCredentials},
+		{"carol", "secret", ErrInvalidCredentials},
+		{"", "", ErrMissingCredentials},
+	}
+	for _, c := range cases {
+		if err := b.authenticate(basicRequest(c.username, c.password)); !errors.Is(err, c.want) {
+			t.Errorf("%s:%s: expected %v, got %v", c.username, c.password, c.want, err)
+		}
+	}
+
+	if challenge := b.challenge(ErrMissingCredentials); challenge != `Basic realm="api-gateway", charset="UTF-8"` {
+		t.Errorf("unexpected challenge %s", challenge)
+	}
+}
+
+func TestBasicHashedPassword(t *testing.T) {
+	b, err := newBasic(service.Auth{Type: "basic", Realm: "admin", Username: "ops", Password: hashPassword(t, "secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.authenticate(basicRequest("ops", "secret")); err != nil {
+		t.Errorf("expected the password to match its hash, got %v", err)
+	}
+	if err := b.authenticate(basicRequest("ops", hashPassword(t, "secret"))); err == nil {
+		t.Error("expected the hash not to be accepted as the password")
+	}
+	if challenge := b.challenge(ErrInvalidCredentials); !strings.Contains(challenge, `realm="admin"`) {
+		t.Errorf("expected the realm in the challenge, got %s", challenge)
+	}
+}
+
+func TestBasicInvalid(t *testing.T) {
+	cases := map[string]service.Auth{
+		"no user":          {Type: "basic"},
+		"missing htpasswd": {Type: "basic", HTPasswd: filepath.Join(t.TempDir(), "missing")},
+		"md5 htpasswd":     {Type: "basic", HTPasswd: writeFile(t, "htpasswd", "alice:$apr1$abc$def\n")},
+		"invalid entry":    {Type: "basic", HTPasswd: writeFile(t, "htpasswd", "alice\n")},
+	}
+	for name, a := range cases {
+		if _, err := newBasic(a); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBearer(t *testing.T) {
+	b, err := newBearer(service.Auth{Type: "bearer", Token: "one", Tokens: []string{"two"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]error{
+		"Bearer one":   nil,
+		"bearer two":   nil,
+		"Bearer three": ErrInvalidToken,
+		"Basic b25l":   ErrMissingCredentials,
+		"Bearer ":      ErrMissingCredentials,
+		"":             ErrMissingCredentials,
+	}
+	for header, want := range cases {
+		req, _ := http.NewRequest(http.MethodGet, "http://gateway/api", nil)
+		req.Header.Set("Authorization", header)
+		if err := b.authenticate(req); !errors.Is(err, want) {
+			t.Errorf("%q: expected %v, got %v", header, want, err)
+		}
+	}
+
+	if challenge := b.challenge(ErrMissingCredentials); challenge != `Bearer realm="api-gateway"` {
+		t.Errorf("unexpected challenge %s", challenge)
+	}
+	if challenge := b.challenge(ErrInvalidToken); challenge != `Bearer realm="api-gateway", error="invalid_token", error_description="invalid token"` {
+		t.Errorf("unexpected challenge %s", challenge)
+	}
+
+	if _, err := newBearer(service.Auth{Type: "bearer"}); err == nil {
+		t.Error("expected bearer auth without a token to be rejected")
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	for _, typ := range []string{"oauth2", "oidc", "service", "digest"} {
+		if _, err := newAuthenticator(service.Auth{Type: typ}); err == nil {
+			t.Errorf("expected %s to be unsupported", typ)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-zoox/api-gateway/core/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// basic checks the username and password of HTTP basic authentication.
+type basic struct {
+	realm string
+	// users are the passwords, in clear or bcrypt hashed, by username
+	users map[string]string
+}
+
+func newBasic(a service.Auth) (*basic, error) {
+	b := &basic{realm: a.EffectiveRealm(), users: map[string]string{}}
+
+	if a.HTPasswd != "" {
+		users, err := loadHTPasswd(a.HTPasswd)
+		if err != nil {
+			return nil, err
+		}
+		b.users = users
+	}
+	if a.Username != "" {
+		b.users[a.Username] = a.Password
+	}
+
+	if len(b.users) == 0 {
+		return nil, fmt.Errorf("basic auth requires a username or an htpasswd file")
+	}
+	return b, nil
+}
+
+func (b *basic) authenticate(req *http.Request) error {
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return ErrMissingCredentials
+	}
+
+	stored, exists := b.users[username]
+	if !exists || !checkPassword(stored, password) {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (b *basic) challenge(err error) string {
+	return fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, b.realm)
+}
+
+// checkPassword reports whether password matches stored, a password in clear or a bcrypt hash.
+func checkPassword(stored, password string) bool {
+	if isBcrypt(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+
+	// digests have the same length, so the comparison does not leak the length of stored
+	a, b := sha256.Sum256([]byte(stored)), sha256.Sum256([]byte(password))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// loadHTPasswd reads the users of an htpasswd file (user:hash lines). Only bcrypt hashes
+// (htpasswd -B) are supported.
+func loadHTPasswd(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("htpasswd: %s", err)
+	}
+
+	users := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		username, hash, ok := strings.Cut(entry, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("htpasswd %s: line %d: invalid entry", path, line)
+		}
+		if !isBcrypt(hash) {
+			return nil, fmt.Errorf("htpasswd %s: line %d: unsupported hash for %s, only bcrypt is supported", path, line, username)
+		}
+		users[username] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("htpasswd %s: %s", path, err)
+	}
+
+	return users, nil
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// bearer checks static bearer tokens.
+type bearer struct {
+	realm string
+	// digests are the SHA-256 digests of the tokens
+	digests [][sha256.Size]byte
+}
+
+func newBearer(a service.Auth) (*bearer, error) {
+	b := &bearer{realm: a.EffectiveRealm()}
+	for _, token := range append([]string{a.Token}, a.Tokens...) {
+		if token != "" {
+			b.digests = append(b.digests, sha256.Sum256([]byte(token)))
+		}
+	}
+
+	if len(b.digests) == 0 {
+		return nil, fmt.Errorf("bearer auth requires a token")
+	}
+	return b, nil
+}
+
+func (b *bearer) authenticate(req *http.Request) error {
+	token, ok := bearerToken(req)
+	if !ok {
+		return ErrMissingCredentials
+	}
+
+	digest := sha256.Sum256([]byte(token))
+	for _, d := range b.digests {
+		if subtle.ConstantTimeCompare(digest[:], d[:]) == 1 {
+			return nil
+		}
+	}
+	return ErrInvalidToken
+}
+
+func (b *bearer) challenge(err error) string {
+	return bearerChallenge(b.realm, err)
+}
+
+// bearerToken returns the token of the Authorization: Bearer header of req.
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// bearerChallenge returns the challenge of RFC 6750: without error for requests without
+// a token, with invalid_token otherwise.
+func bearerChallenge(realm string, err error) string {
+	if errors.Is(err, ErrMissingCredentials) {
+		return fmt.Sprintf("Bearer realm=%q", realm)
+	}
+	return fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\", error_description=%q", realm, err.Error())
+}
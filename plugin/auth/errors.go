@@ -0,0 +1,16 @@
+package auth
+
+import "errors"
+
+var (
+	// ErrMissingCredentials is returned when the request carries no credentials of the auth type
+	ErrMissingCredentials = errors.New("missing credentials")
+	// ErrInvalidCredentials is returned when the username or password is wrong
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidToken is returned when the token is unknown, malformed or wrongly signed
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned when the exp claim of a token is past
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenNotYetValid is returned when the nbf claim of a token is to come
+	ErrTokenNotYetValid = errors.New("token not yet valid")
+)
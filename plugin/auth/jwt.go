@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/service"
+
+	// hash functions of the signing algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// jwtAuth checks JSON Web Tokens signed with an HMAC secret or an RSA or ECDSA key.
+type jwtAuth struct {
+	realm     string
+	secret    []byte
+	publicKey crypto.PublicKey
+	issuer    string
+	audience  []string
+	skew      time.Duration
+}
+
+func newJWT(a service.Auth) (*jwtAuth, error) {
+	if a.Secret == "" && a.PublicKey == "" {
+		return nil, fmt.Errorf("jwt auth requires a secret or a public key")
+	}
+	if a.ClockSkew < 0 {
+		return nil, fmt.Errorf("jwt auth: clock_skew must not be negative")
+	}
+
+	j := &jwtAuth{
+		realm:    a.EffectiveRealm(),
+		issuer:   a.Issuer,
+		audience: a.Audience,
+		skew:     time.Duration(a.ClockSkew) * time.Second,
+	}
+	if a.Secret != "" {
+		j.secret = []byte(a.Secret)
+	}
+	if a.PublicKey != "" {
+		key, err := loadPublicKey(a.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		j.publicKey = key
+	}
+	return j, nil
+}
+
+func (j *jwtAuth) authenticate(req *http.Request) error {
+	token, ok := bearerToken(req)
+	if !ok {
+		return ErrMissingCredentials
+	}
+	return j.verify(token, time.Now())
+}
+
+func (j *jwtAuth) challenge(err error) string {
+	return bearerChallenge(j.realm, err)
+}
+
+// claims are the registered claims checked by the gateway.
+type claims struct {
+	Exp *float64 `json:"exp"`
+	Nbf *float64 `json:"nbf"`
+	Iss string   `json:"iss"`
+	Aud audience `json:"aud"`
+}
+
+// audience is the aud claim, a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// verify checks the signature of token, then its exp, nbf, iss and aud claims at now.
+func (j *jwtAuth) verify(token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	if err := j.verifySignature(header.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return err
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+
+	seconds := float64(now.UnixNano()) / float64(time.Second)
+	skew := j.skew.Seconds()
+	if c.Exp != nil && seconds >= *c.Exp+skew {
+		return ErrTokenExpired
+	}
+	if c.Nbf != nil && seconds < *c.Nbf-skew {
+		return ErrTokenNotYetValid
+	}
+	if j.issuer != "" && c.Iss != j.issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if len(j.audience) > 0 && !slices.ContainsFunc(c.Aud, func(aud string) bool { return slices.Contains(j.audience, aud) }) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+// verifySignature checks the signature of input with the algorithm alg, which must suit
+// the configured secret or public key.
+func (j *jwtAuth) verifySignature(alg, input string, signature []byte) error {
+	unsupported := fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+	invalid := fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+
+	if len(alg) != 5 {
+		return unsupported
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return unsupported
+	}
+
+	if alg[:2] == "HS" {
+		if j.secret == nil {
+			return unsupported
+		}
+		mac := hmac.New(hash.New, j.secret)
+		mac.Write([]byte(input))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return invalid
+		}
+		return nil
+	}
+
+	h := hash.New()
+	h.Write([]byte(input))
+	digest := h.Sum(nil)
+
+	var err error
+
+	switch alg[:2] {
+	case "RS", "PS":
+		key, ok := j.publicKey.(*rsa.PublicKey)
+		if !ok {
+			return unsupported
+		}
+		if alg[:2] == "RS" {
+			err = rsa.VerifyPKCS1v15(key, hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(key, hash, digest, signature, nil)
+		}
+		if err != nil {
+			return invalid
+		}
+		return nil
+	case "ES":
+		key, ok := j.publicKey.(*ecdsa.PublicKey)
+		if !ok || key.Curve.Params().BitSize != curveBits[hash] {
+			return unsupported
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return invalid
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return invalid
+		}
+		return nil
+	default:
+		return unsupported
+	}
+}
+
+// curveBits are the sizes of the curves of ES256 (P-256), ES384 (P-384) and ES512 (P-521).
+var curveBits = map[crypto.Hash]int{
+	crypto.SHA256: 256,
+	crypto.SHA384: 384,
+	crypto.SHA512: 521,
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// loadPublicKey reads an RSA or ECDSA public key from a PEM file: a PKIX public key, a
+// PKCS #1 RSA public key or a certificate.
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("jwt public key: %s", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwt public key %s: no PEM data", path)
+	}
+
+	var key crypto.PublicKey
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("jwt public key %s: %s", path, err)
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwt public key %s: %s", path, err)
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("jwt public key %s: unsupported key type %T, expected RSA or ECDSA", path, key)
+	}
+}
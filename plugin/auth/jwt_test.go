@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-zoox/api-gateway/core/service"
+)
+
+// signJWT returns a token of claims signed with alg by key, an HMAC secret ([]byte) or a
+// private key.
+func signJWT(t *testing.T, alg string, key any, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}[alg[2:]]
+	h := hash.New()
+	h.Write([]byte(input))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	var err error
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(hash.New, k)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if alg[:2] == "PS" {
+			signature, err = rsa.SignPSS(rand.Reader, k, hash, digest, nil)
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// unsignedJWT returns a token of claims with alg none.
+func unsignedJWT(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "none"})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+func writePublicKey(t *testing.T, key any) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeFile(t, "key.pem", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+}
+
+func TestJWTSignatures(t *testing.T) {
+	secret := []byte("change-me")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecKey384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	otherSecret := []byte("other")
+
+	hmacAuth, err := newJWT(service.Auth{Type: "jwt", Secret: string(secret)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaAuth, err := newJWT(service.Auth{Type: "jwt", PublicKey: writePublicKey(t, &rsaKey.PublicKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecAuth, err := newJWT(service.Auth{Type: "jwt", PublicKey: writePublicKey(t, &ecKey.PublicKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := map[string]any{"sub": "alice"}
+	cases := []struct {
+		name  string
+		auth  *jwtAuth
+		token string
+		ok    bool
+	}{
+		{"HS256", hmacAuth, signJWT(t, "HS256", secret, claims), true},
+		{"HS512", hmacAuth, signJWT(t, "HS512", secret, claims), true},
+		{"HS256 other secret", hmacAuth, signJWT(t, "HS256", otherSecret, claims), false},
+		{"none", hmacAuth, unsignedJWT(claims), false},
+		{"RS256", rsaAuth, signJWT(t, "RS256", rsaKey, claims), true},
+		{"PS384", rsaAuth, signJWT(t, "PS384", rsaKey, claims), true},
+		{"RS256 with the HMAC key", hmacAuth, signJWT(t, "RS256", rsaKey, claims), false},
+		// a token signed with the public key as an HMAC secret must not pass
+		{"HS256 with the public key", rsaAuth, signJWT(t, "HS256", []byte(writePublicKey(t, &rsaKey.PublicKey)), claims), false},
+		{"ES256", ecAuth, signJWT(t, "ES256", ecKey, claims), true},
+		{"ES384 on P-256", ecAuth, signJWT(t, "ES384", ecKey384, claims), false},
+		{"ES256 other key", ecAuth, signJWT(t, "ES256", ecKey384, claims), false},
+		{"malformed", hmacAuth, "abc.def", false},
+	}
+	for _, c := range cases {
+		err := c.auth.verify(c.token, time.Now())
+		if c.ok && err != nil {
+			t.Errorf("%s: expected the token to be valid, got %v", c.name, err)
+		}
+		if !c.ok && !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected an invalid token, got %v", c.name, err)
+		}
+	}
+}
+
+func TestJWTClaims(t *testing.T) {
+	secret := []byte("change-me")
+	j, err := newJWT(service.Auth{
+		Type:      "jwt",
+		Secret:    string(secret),
+		Issuer:    "https://auth.example.com",
+		Audience:  []string{"api", "admin"},
+		ClockSkew: 30,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	claims := func(extra map[string]any) map[string]any {
+		c := map[string]any{"iss": "https://auth.example.com", "aud": "api"}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	cases := []struct {
+		name   string
+		claims map[string]any
+		want   error
+	}{
+		{"valid", claims(map[string]any{"exp": now.Unix() + 60, "nbf": now.Unix() - 60}), nil},
+		{"audience array", claims(map[string]any{"aud": []string{"web", "admin"}}), nil},
+		{"expired within skew", claims(map[string]any{"exp": now.Unix() - 20}), nil},
+		{"expired", claims(map[string]any{"exp": now.Unix() - 30}), ErrTokenExpired},
+		{"not yet valid within skew", claims(map[string]any{"nbf": now.Unix() + 20}), nil},
+		{"not yet valid", claims(map[string]any{"nbf": now.Unix() + 60}), ErrTokenNotYetValid},
+		{"issuer", claims(map[string]any{"iss": "https://evil.example.com"}), ErrInvalidToken},
+		{"no audience", map[string]any{"iss": "https://auth.example.com"}, ErrInvalidToken},
+		{"audience", claims(map[string]any{"aud": []string{"web"}}), ErrInvalidToken},
+	}
+	for _, c := range cases {
+		if err := j.verify(signJWT(t, "HS256", secret, c.claims), now); !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	j, err := newJWT(service.Auth{Type: "jwt", Secret: "change-me"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://gateway/api", nil)
+	if err := j.authenticate(req); !errors.Is(err, ErrMissingCredentials) {
+		t.Errorf("expected missing credentials, got %v", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+signJWT(t, "HS256", []byte("change-me"), map[string]any{"exp": time.Now().Unix() - 1}))
+	err = j.authenticate(req)
+	if !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expected an expired token, got %v", err)
+	}
+	if challenge := j.challenge(err); challenge != `Bearer realm="api-gateway", error="invalid_token", error_description="token expired"` {
+		t.Errorf("unexpected challenge %s", challenge)
+	}
+}
+
+func TestJWTInvalid(t *testing.T) {
+	cases := map[string]service.Auth{
+		"no key":        {Type: "jwt"},
+		"negative skew": {Type: "jwt", Secret: "s", ClockSkew: -1},
+		"missing key":   {Type: "jwt", PublicKey: "/nonexistent/key.pem"},
+		"not PEM":       {Type: "jwt", PublicKey: writeFile(t, "key.pem", "not a key")},
+		"invalid PEM":   {Type: "jwt", PublicKey: writeFile(t, "key.pem", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("x")})))},
+	}
+	for name, a := range cases {
+		if _, err := newJWT(a); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package clientcert
+
+import (
+	"net/http"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/plugin"
+	"github.com/go-zoox/api-gateway/requestid"
+	"github.com/go-zoox/api-gateway/router"
+	"github.com/go-zoox/zoox"
+)
+
+// defaultHeader carries the client identity upstream when client_cert.header is unset.
+const defaultHeader = "X-Client-Cert-Subject"
+
+// ClientCert requires verified TLS client certificates on the global level or per route,
+// and forwards the client identity (and optionally the certificate) upstream.
+type ClientCert struct {
+	plugin.Plugin
+
+	cfg *config.Config
+	// headers carrying identities upstream; never accepted from clients
+	headers []string
+}
+
+// New creates the client certificate plugin.
+func New() *ClientCert {
+	return &ClientCert{}
+}
+
+// Prepare registers the middleware before the reverse proxy.
+func (p *ClientCert) Prepare(app *zoox.Application, cfg *config.Config) error {
+	app.Logger().Infof("[plugin:clientcert] prepare ...")
+	p.cfg = cfg
+
+	p.headers = nil
+	add := func(policy route.ClientCert) {
+		for _, h := range []string{identityHeader(policy), policy.PEMHeader} {
+			if h != "" {
+				p.headers = append(p.headers, h)
+			}
+		}
+	}
+	add(effectiveClientCert(&cfg.ClientCert, nil))
+	for i := range cfg.Routes {
+		add(effectiveClientCert(&cfg.ClientCert, &cfg.Routes[i]))
+	}
+
+	app.Use(p.handle)
+	app.Logger().Infof("[plugin:clientcert] initialized")
+	return nil
+}
+
+// effectiveClientCert returns the policy of a route: its own client_cert when enabled,
+// filled with the global values for unset fields, or else the global client_cert.
+func effectiveClientCert(global *config.ClientCert, rt *route.Route) route.ClientCert {
+	if rt == nil || !rt.ClientCert.Enable {
+		return *global
+	}
+
+	out := rt.ClientCert
+	if global.Enable {
+		if out.Identity == "" {
+			out.Identity = global.Identity
+		}
+		if out.Header == "" {
+			out.Header = global.Header
+		}
+		if out.PEMHeader == "" {
+			out.PEMHeader = global.PEMHeader
+		}
+		if out.Message == "" {
+			out.Message = global.Message
+		}
+	}
+	return out
+}
+
+// Describe returns the client certificate policy applied to rt (plugin.Describer).
+func (p *ClientCert) Describe(rt *route.Route) (string, any) {
+	if policy := effectiveClientCert(&p.cfg.ClientCert, rt); policy.Enable {
+		return "client_cert", policy
+	}
+	return "client_cert", nil
+}
+
+func identityHeader(policy route.ClientCert) string {
+	if policy.Header == "" {
+		return defaultHeader
+	}
+	return policy.Header
+}
+
+func (p *ClientCert) handle(ctx *zoox.Context) {
+	// identity headers only ever come from the gateway
+	for _, h := range p.headers {
+		ctx.Request.Header.Del(h)
+	}
+
+	policy := effectiveClientCert(&p.cfg.ClientCert, router.FromRequest(ctx.Request))
+	if !policy.Enable {
+		ctx.Next()
+		return
+	}
+
+	message := policy.Message
+	if message == "" {
+		message = "Client certificate required"
+	}
+
+	cert := Certificate(ctx.Request)
+	if cert == nil {
+		ctx.Logger.Warnf("[plugin:clientcert] no verified client certificate, path %s", ctx.Path)
+		ctx.String(http.StatusUnauthorized, "%s", requestid.Message(ctx.Request, message))
+		return
+	}
+
+	identity := MapIdentity(cert, policy.Identity)
+	if identity == "" {
+		ctx.Logger.Warnf("[plugin:clientcert] certificate %s has no %s identity, path %s", cert.Subject, policy.Identity, ctx.Path)
+		ctx.String(http.StatusUnauthorized, "%s", requestid.Message(ctx.Request, message))
+		return
+	}
+
+	ctx.Request.Header.Set(identityHeader(policy), identity)
+	if policy.PEMHeader != "" {
+		ctx.Request.Header.Set(policy.PEMHeader, EncodePEM(cert))
+	}
+	ctx.Request = withIdentity(ctx.Request, identity)
+
+	ctx.Next()
+}
+
+// OnRequest is a no-op; certificates are checked in the HTTP middleware.
+func (p *ClientCert) OnRequest(_ *zoox.Context, _ *http.Request) error { return nil }
+
+// OnResponse is a no-op.
+func (p *ClientCert) OnResponse(_ *zoox.Context, _ *http.Response) error { return nil }
@@ -0,0 +1,82 @@
+package clientcert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+)
+
+func TestMapIdentity(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/ns/default/sa/api")
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "api", Organization: []string{"example"}},
+		DNSNames:       []string{"api.internal", "api"},
+		EmailAddresses: []string{"ops@example.org"},
+		URIs:           []*url.URL{spiffe},
+	}
+
+	cases := map[string]string{
+		"":        "CN=api,O=example",
+		"subject": "CN=api,O=example",
+		"cn":      "api",
+		"dns":     "api.internal",
+		"email":   "ops@example.org",
+		"uri":     "spiffe://example.org/ns/default/sa/api",
+	}
+	for mapping, expected := range cases {
+		if got := MapIdentity(cert, mapping); got != expected {
+			t.Errorf("%q: expected %q, got %q", mapping, expected, got)
+		}
+	}
+
+	if got := MapIdentity(&x509.Certificate{}, "email"); got != "" {
+		t.Errorf("expected no identity without email SAN, got %q", got)
+	}
+	if got := MapIdentity(nil, "subject"); got != "" {
+		t.Errorf("expected no identity without certificate, got %q", got)
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if _, ok := Identity(req); ok {
+		t.Fatal("expected no identity without tls")
+	}
+
+	req.TLS = &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "api"}}}},
+	}
+	if identity, _ := Identity(req); identity != "CN=api" {
+		t.Fatalf("expected the subject by default, got %q", identity)
+	}
+
+	// the identity mapped by the route policy wins
+	req = withIdentity(req, "api")
+	if identity, _ := Identity(req); identity != "api" {
+		t.Fatalf("expected the mapped identity, got %q", identity)
+	}
+}
+
+func TestEffectiveClientCert(t *testing.T) {
+	global := &config.ClientCert{Enable: true, Identity: "subject", Header: "X-Client", PEMHeader: "X-Client-PEM"}
+
+	if got := effectiveClientCert(global, &route.Route{}); got != *global {
+		t.Fatalf("expected the global policy, got %+v", got)
+	}
+
+	got := effectiveClientCert(global, &route.Route{ClientCert: route.ClientCert{Enable: true, Identity: "cn"}})
+	want := route.ClientCert{Enable: true, Identity: "cn", Header: "X-Client", PEMHeader: "X-Client-PEM"}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	if got := effectiveClientCert(&config.ClientCert{}, nil); got.Enable {
+		t.Fatal("expected no policy")
+	}
+}
@@ -0,0 +1,80 @@
+package clientcert
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type identityKey struct{}
+
+// Supported identity mappings (client_cert.identity).
+const (
+	IdentitySubject = "subject"
+	IdentityCN      = "cn"
+	IdentityDNS     = "dns"
+	IdentityEmail   = "email"
+	IdentityURI     = "uri"
+)
+
+// Certificate returns the verified client certificate of the request, or nil when the
+// client presented none (or the listener did not verify it).
+func Certificate(req *http.Request) *x509.Certificate {
+	if req == nil || req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return req.TLS.VerifiedChains[0][0]
+}
+
+// MapIdentity maps a certificate to a client identity; empty when the certificate has
+// no value for the mapping.
+func MapIdentity(cert *x509.Certificate, mapping string) string {
+	if cert == nil {
+		return ""
+	}
+
+	switch strings.ToLower(mapping) {
+	case IdentityCN:
+		return cert.Subject.CommonName
+	case IdentityDNS:
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case IdentityEmail:
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	case IdentityURI:
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	default:
+		return cert.Subject.String()
+	}
+	return ""
+}
+
+// Identity returns the client identity of the request: the one mapped by the client_cert
+// policy of its route, or else the subject of its verified certificate.
+func Identity(req *http.Request) (string, bool) {
+	if identity, ok := req.Context().Value(identityKey{}).(string); ok {
+		return identity, true
+	}
+
+	identity := MapIdentity(Certificate(req), IdentitySubject)
+	return identity, identity != ""
+}
+
+func withIdentity(req *http.Request, identity string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), identityKey{}, identity))
+}
+
+// EncodePEM returns the URL-encoded PEM of the certificate, like nginx's $ssl_client_escaped_cert.
+// Spaces are encoded as %20, so both path and query unescaping decode it.
+func EncodePEM(cert *x509.Certificate) string {
+	escaped := url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+	return strings.ReplaceAll(escaped, "+", "%20")
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/go-zoox/api-gateway/config"
 	"github.com/go-zoox/api-gateway/core/route"
 	"github.com/go-zoox/api-gateway/plugin"
+	"github.com/go-zoox/api-gateway/requestid"
 	"github.com/go-zoox/api-gateway/router"
 	"github.com/go-zoox/zoox"
 )
@@ -111,13 +112,24 @@ func (p *Plugin) forRoute(rt *route.Route) *corsConfig {
 	return nil
 }
 
+// Describe returns the CORS settings applied to rt, before defaults (plugin.Describer).
+func (p *Plugin) Describe(rt *route.Route) (string, any) {
+	switch {
+	case rt.CORS.Enable:
+		return "cors", mergeCORSGlobalRoute(&p.cfg.CORS, &rt.CORS)
+	case p.cfg.CORS.Enable:
+		return "cors", p.cfg.CORS
+	}
+	return "cors", nil
+}
+
 func (p *Plugin) handle(ctx *zoox.Context) {
 	if p.cfg == nil {
 		ctx.Next()
 		return
 	}
-	rt, err := router.RouteForPath(p.cfg, ctx.Path)
-	if err != nil {
+	rt := router.FromRequest(ctx.Request)
+	if rt == nil {
 		ctx.Next()
 		return
 	}
@@ -132,12 +144,12 @@ func (p *Plugin) handle(ctx *zoox.Context) {
 	// preflight: respond without proxying
 	if reqMethod == http.MethodOptions && isPreflight(ctx.Request) {
 		if !originOK(cfgX, origin) {
-			ctx.String(http.StatusForbidden, "CORS origin not allowed")
+			ctx.String(http.StatusForbidden, "%s", requestid.Message(ctx.Request, "CORS origin not allowed"))
 			return
 		}
 		reqMethodHdr := ctx.Request.Header.Get("Access-Control-Request-Method")
 		if !methodOK(cfgX, reqMethodHdr) {
-			ctx.String(http.StatusForbidden, "CORS method not allowed")
+			ctx.String(http.StatusForbidden, "%s", requestid.Message(ctx.Request, "CORS method not allowed"))
 			return
 		}
 		applyCORSResponse(ctx.Writer.Header(), cfgX, allowOriginValue(cfgX, origin), true)
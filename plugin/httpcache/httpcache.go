@@ -9,9 +9,13 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/go-zoox/api-gateway/accesslog"
 	"github.com/go-zoox/api-gateway/config"
 	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/metrics"
 	"github.com/go-zoox/api-gateway/plugin"
+	"github.com/go-zoox/api-gateway/router"
+	"github.com/go-zoox/api-gateway/tracing"
 	zc "github.com/go-zoox/cache"
 	"github.com/go-zoox/zoox"
 )
@@ -57,17 +61,25 @@ func (h *HTTPCachePlugin) Prepare(app *zoox.Application, cfg *config.Config) err
 
 // OnRequest serves cache HITs before the upstream is contacted.
 func (h *HTTPCachePlugin) OnRequest(ctx *zoox.Context, req *http.Request) error {
-	cfg := h.getConfigForPath(ctx.Path)
+	cfg := h.configFor(router.FromRequest(ctx.Request), ctx.Path)
 	if cfg == nil || !cfg.Enable || h.store == nil {
 		return nil
 	}
 
 	if !h.methodAllowed(req.Method, cfg) {
+		record(req, "bypass")
+		return nil
+	}
+
+	// protocol upgrades (WebSocket) are never served from cache
+	if req.Header.Get("Upgrade") != "" {
+		record(req, "bypass")
 		return nil
 	}
 
 	if !cfg.CacheAuthorizedRequests {
 		if req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+			record(req, "bypass")
 			return nil
 		}
 	}
@@ -75,28 +87,35 @@ func (h *HTTPCachePlugin) OnRequest(ctx *zoox.Context, req *http.Request) error
 	key, err := h.buildCacheKey(cfg, req, ctx.Path)
 	if err != nil {
 		ctx.Logger.Warnf("[plugin:httpcache] cache key: %v", err)
+		record(req, "bypass")
 		return nil
 	}
 
 	var entry cachedEntry
-	if err := h.store.Get(key, &entry); err != nil {
-		return nil
-	}
-	if entry.StatusCode < http.StatusOK {
+	if err := h.store.Get(key, &entry); err != nil || entry.StatusCode < http.StatusOK {
+		record(req, "miss")
 		return nil
 	}
 
+	record(req, "hit")
 	h.writeHit(ctx, &entry)
 	return ErrTerminalResponse
 }
 
+// record counts the cache outcome of req, and records it on its span and access log line.
+func record(req *http.Request, status string) {
+	metrics.HTTPCache.WithLabelValues(metrics.RouteName(req), status).Inc()
+	tracing.SetAttributes(req, tracing.CacheStatusKey.String(status))
+	accesslog.SetCacheStatus(req, status)
+}
+
 // OnResponse stores cacheable responses after the upstream returns.
 func (h *HTTPCachePlugin) OnResponse(ctx *zoox.Context, res *http.Response) error {
 	if res == nil {
 		return nil
 	}
 
-	cfg := h.getConfigForPath(ctx.Path)
+	cfg := h.configFor(router.FromRequest(ctx.Request), ctx.Path)
 	if cfg == nil || !cfg.Enable || h.store == nil {
 		return nil
 	}
@@ -247,6 +266,29 @@ func hopByHopLookup() map[string]bool {
 	}
 }
 
+// Describe returns the HTTP cache settings applied to rt (plugin.Describer).
+func (h *HTTPCachePlugin) Describe(rt *route.Route) (string, any) {
+	if cfg := h.configFor(rt, rt.Path); cfg != nil {
+		return "http_cache", cfg
+	}
+	return "http_cache", nil
+}
+
+// configFor returns the http_cache config of rt, the route the gateway matched, or of the
+// route configured for path when the route is not known (route wins over global).
+func (h *HTTPCachePlugin) configFor(rt *route.Route, path string) *route.HTTPCache {
+	if rt == nil {
+		return h.getConfigForPath(path)
+	}
+	if rt.HTTPCache.Enable {
+		return &rt.HTTPCache
+	}
+	if h.global != nil && h.global.Enable {
+		return h.global
+	}
+	return nil
+}
+
 func (h *HTTPCachePlugin) getConfigForPath(path string) *route.HTTPCache {
 	routePaths := make([]string, 0, len(h.routes))
 	for p := range h.routes {
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/go-zoox/api-gateway/requestid"
 	"github.com/go-zoox/proxy"
 )
 
@@ -28,5 +29,5 @@ func DefaultProxyOnError(err error, rw http.ResponseWriter, req *http.Request) {
 	}
 
 	rw.WriteHeader(status)
-	_, _ = rw.Write([]byte(message))
+	_, _ = rw.Write([]byte(requestid.Message(req, message)))
 }
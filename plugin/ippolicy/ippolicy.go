@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/go-zoox/api-gateway/accesslog"
 	"github.com/go-zoox/api-gateway/config"
 	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/metrics"
 	"github.com/go-zoox/api-gateway/plugin"
+	"github.com/go-zoox/api-gateway/requestid"
 	"github.com/go-zoox/api-gateway/router"
 	"github.com/go-zoox/zoox"
 )
@@ -108,6 +111,14 @@ func effectiveIPPolicy(global *config.IPPolicy, rt *route.Route) *route.IPPolicy
 	return &out
 }
 
+// Describe returns the IP policy applied to rt (plugin.Describer).
+func (p *IPPolicy) Describe(rt *route.Route) (string, any) {
+	if eff := effectiveIPPolicy(&p.cfg.IPPolicy, rt); eff != nil {
+		return "ip_policy", eff
+	}
+	return "ip_policy", nil
+}
+
 func routeKey(rt *route.Route) string {
 	if rt == nil {
 		return ""
@@ -134,8 +145,8 @@ func (p *IPPolicy) compiledFor(rt *route.Route) *compiled {
 }
 
 func (p *IPPolicy) handle(ctx *zoox.Context) {
-	rt, err := router.RouteForPath(p.cfg, ctx.Path)
-	if err != nil {
+	rt := router.FromRequest(ctx.Request)
+	if rt == nil {
 		ctx.Next()
 		return
 	}
@@ -144,16 +155,22 @@ func (p *IPPolicy) handle(ctx *zoox.Context) {
 		ctx.Next()
 		return
 	}
+	// denied requests never reach the gateway's own route match
+	metrics.SetRoute(ctx.Request, rt.Name)
+	accesslog.SetRoute(ctx.Request, rt)
+
 	direct, err := DirectPeerIP(ctx.Request)
 	if err != nil {
 		ctx.Logger.Warnf("[plugin:ippolicy] direct peer: %v", err)
-		ctx.String(http.StatusForbidden, pol.message)
+		metrics.IPPolicyDenied.WithLabelValues(rt.Name).Inc()
+		ctx.String(http.StatusForbidden, "%s", requestid.Message(ctx.Request, pol.message))
 		return
 	}
 	client := ClientIP(ctx.Request, direct, pol.trusted)
 	if !pol.allows(client) {
 		ctx.Logger.Warnf("[plugin:ippolicy] blocked client %s (direct %s) path %s", client, direct, ctx.Path)
-		ctx.String(http.StatusForbidden, pol.message)
+		metrics.IPPolicyDenied.WithLabelValues(rt.Name).Inc()
+		ctx.String(http.StatusForbidden, "%s", requestid.Message(ctx.Request, pol.message))
 		return
 	}
 	ctx.Next()
@@ -19,6 +19,8 @@ import (
 	"github.com/go-zoox/api-gateway/config"
 	"github.com/go-zoox/api-gateway/core/route"
 	"github.com/go-zoox/api-gateway/plugin"
+	"github.com/go-zoox/api-gateway/requestid"
+	"github.com/go-zoox/api-gateway/router"
 	"github.com/go-zoox/zoox"
 	"gorm.io/gorm"
 )
@@ -63,6 +65,12 @@ type JSONAudit struct {
 
 	dbMu sync.Mutex
 	db   *gorm.DB
+
+	// pending counts audit lines being written, so Close can wait for them;
+	// closeMu guards pending.Add against Close, and closed drops lines after it
+	closeMu sync.Mutex
+	closed  bool
+	pending sync.WaitGroup
 }
 
 // New builds a JSON audit plugin (call Prepare after construction via core).
@@ -157,6 +165,29 @@ func isRedactEnabled(cfg *route.JSONAudit) bool {
 	return route.EffectiveJSONAuditProvider(cfg.Output) == "console"
 }
 
+// Describe returns the json_audit settings applied to rt (plugin.Describer).
+func (j *JSONAudit) Describe(rt *route.Route) (string, any) {
+	if cfg := j.configFor(rt, rt.Path); cfg != nil {
+		return "json_audit", cfg
+	}
+	return "json_audit", nil
+}
+
+// configFor returns the json_audit config of rt, the route the gateway matched, or of the
+// route configured for path when the route is not known (route wins over global).
+func (j *JSONAudit) configFor(rt *route.Route, path string) *route.JSONAudit {
+	if rt == nil {
+		return j.getJSONAuditConfig(path)
+	}
+	if rt.JSONAudit.Enable {
+		return &rt.JSONAudit
+	}
+	if j.globalConfig.Enable {
+		return &j.globalConfig
+	}
+	return nil
+}
+
 // getJSONAuditConfig returns the effective json_audit config for a request path (route wins over global).
 func (j *JSONAudit) getJSONAuditConfig(path string) *route.JSONAudit {
 	routePaths := make([]string, 0, len(j.routeConfigs))
@@ -189,7 +220,7 @@ func (j *JSONAudit) getJSONAuditConfig(path string) *route.JSONAudit {
 // OnRequest snapshots the client request body (bounded) for pairing with JSON responses.
 func (j *JSONAudit) OnRequest(ctx *zoox.Context, _ *http.Request) error {
 	path := ctx.Path
-	acfg := j.getJSONAuditConfig(path)
+	acfg := j.configFor(router.FromRequest(ctx.Request), path)
 	if acfg == nil || !acfg.Enable {
 		return nil
 	}
@@ -216,7 +247,7 @@ func (j *JSONAudit) OnRequest(ctx *zoox.Context, _ *http.Request) error {
 		method:     ctx.Request.Method,
 		path:       path,
 		remoteAddr: ctx.Request.RemoteAddr,
-		requestID:  firstHeader(ctx.Request, "X-Request-ID", "X-Correlation-ID", "X-Trace-ID"),
+		requestID:  requestID(ctx.Request),
 		userAgent:  ctx.Request.UserAgent(),
 		headers:    headers,
 		query:      query,
@@ -441,13 +472,17 @@ func snapshotParams(ctx *zoox.Context) map[string]any {
 	if ctx == nil {
 		return map[string]any{}
 	}
-	pm := ctx.Params()
-	if pm == nil {
-		return map[string]any{}
+	m := map[string]any{}
+	if pm := ctx.Params(); pm != nil {
+		for k, v := range pm.ToMap() {
+			m[k] = v
+		}
 	}
-	m := pm.ToMap()
-	if m == nil {
-		return map[string]any{}
+	// params captured by the route path, like id in /users/:id
+	if ctx.Request != nil {
+		for k, v := range router.ParamsFromRequest(ctx.Request) {
+			m[k] = v
+		}
 	}
 	return m
 }
@@ -523,6 +558,14 @@ func gunzipLimited(raw []byte, max int64) ([]byte, error) {
 	return out, nil
 }
 
+// requestID is the ID the gateway gave the request, or the first ID header sent by the client.
+func requestID(req *http.Request) string {
+	if id := requestid.FromRequest(req); id != "" {
+		return id
+	}
+	return firstHeader(req, "X-Request-ID", "X-Correlation-ID", "X-Trace-ID")
+}
+
 func firstHeader(req *http.Request, names ...string) string {
 	for _, n := range names {
 		if v := req.Header.Get(n); v != "" {
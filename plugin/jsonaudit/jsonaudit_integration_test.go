@@ -14,6 +14,7 @@ import (
 
 	"github.com/go-zoox/api-gateway/config"
 	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/router"
 	"github.com/go-zoox/logger"
 	"github.com/go-zoox/zoox"
 	"github.com/go-zoox/zoox/defaults"
@@ -525,6 +526,15 @@ func TestSnapshotParams_Zoox(t *testing.T) {
 	}
 }
 
+func TestSnapshotParams_Route(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req = router.WithRoute(req, &route.Route{Name: "user"}, router.Params{"id": "42"})
+	m := snapshotParams(testZCtx(req))
+	if m["id"] != "42" {
+		t.Fatalf("expected the route params, got %v", m)
+	}
+}
+
 func TestRedactJSONBytes_Invalid(t *testing.T) {
 	cfg := &route.JSONAudit{Enable: true}
 	keys := buildRedactKeySet(cfg)
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/requestid"
 )
 
 func TestJSONMIME(t *testing.T) {
@@ -98,3 +99,14 @@ func TestFirstHeader(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestRequestID(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Correlation-ID", "client")
+	if requestID(req) != "client" {
+		t.Fatal("expected the client header without a gateway ID")
+	}
+	if requestID(requestid.WithID(req, "gateway")) != "gateway" {
+		t.Fatal("expected the gateway ID to win")
+	}
+}
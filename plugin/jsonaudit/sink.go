@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/go-zoox/api-gateway/config"
 	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/metrics"
 	"github.com/go-zoox/gormx"
 	"github.com/go-zoox/zoox"
 )
@@ -54,6 +56,16 @@ func warnUnknownJSONAuditOutput(app *zoox.Application, raw string) {
 }
 
 func (j *JSONAudit) emitAuditLine(ctx *zoox.Context, cfg *route.JSONAudit, line []byte) {
+	j.closeMu.Lock()
+	if j.closed {
+		j.closeMu.Unlock()
+		ctx.Logger.Warnf("[plugin:jsonaudit] plugin closed, audit line dropped")
+		return
+	}
+	j.pending.Add(1)
+	j.closeMu.Unlock()
+	defer j.pending.Done()
+
 	switch route.EffectiveJSONAuditProvider(cfg.Output) {
 	case "file":
 		path := strings.TrimSpace(cfg.Output.File.Path)
@@ -64,16 +76,19 @@ func (j *JSONAudit) emitAuditLine(ctx *zoox.Context, cfg *route.JSONAudit, line
 		}
 		if err := j.appendFileLine(path, line); err != nil {
 			ctx.Logger.Warnf("[plugin:jsonaudit] write audit file %s: %v", path, err)
+			metrics.JSONAuditSinkFailures.WithLabelValues("file").Inc()
 			ctx.Logger.Infof("%s", string(line))
 		}
 	case "http":
 		if err := j.postAuditHTTP(cfg, line); err != nil {
 			ctx.Logger.Warnf("[plugin:jsonaudit] http audit sink: %v", err)
+			metrics.JSONAuditSinkFailures.WithLabelValues("http").Inc()
 			ctx.Logger.Infof("%s", string(line))
 		}
 	case "database":
 		if err := j.insertAuditRecord(line); err != nil {
 			ctx.Logger.Warnf("[plugin:jsonaudit] database audit sink: %v", err)
+			metrics.JSONAuditSinkFailures.WithLabelValues("database").Inc()
 			ctx.Logger.Infof("%s", string(line))
 		}
 	default:
@@ -81,6 +96,41 @@ func (j *JSONAudit) emitAuditLine(ctx *zoox.Context, cfg *route.JSONAudit, line
 	}
 }
 
+// Close stops accepting audit lines, waits for the ones being written, then closes the
+// audit files and the database connection. Lines emitted after Close are dropped.
+func (j *JSONAudit) Close() error {
+	j.closeMu.Lock()
+	j.closed = true
+	j.closeMu.Unlock()
+	j.pending.Wait()
+
+	var errs []error
+	j.fileMu.Lock()
+	for path, f := range j.fileHandles {
+		if err := f.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("json_audit: close %s: %w", path, err))
+		}
+	}
+	j.fileHandles = nil
+	j.fileMu.Unlock()
+
+	j.dbMu.Lock()
+	db := j.db
+	j.db = nil
+	j.dbMu.Unlock()
+	if db != nil {
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("json_audit: close database: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 func (j *JSONAudit) prepareDatabaseSink(app *zoox.Application, cfg *config.Config) error {
 	dbSinks := collectDatabaseSinks(cfg)
 	if len(dbSinks) == 0 {
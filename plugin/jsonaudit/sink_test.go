@@ -508,3 +508,70 @@ func TestDatabaseSQLiteFileCreated(t *testing.T) {
 		t.Fatalf("sqlite db file not created: %v", err)
 	}
 }
+
+func TestClose_ReleasesSinks(t *testing.T) {
+	dir := t.TempDir()
+	filePath := dir + string(os.PathSeparator) + "audit.ndjson"
+	j := New()
+	err := j.Prepare(defaults.Default(), &config.Config{
+		JSONAudit: config.JSONAudit{
+			Enable: true,
+			Output: route.JSONAuditOutput{
+				Provider: "database",
+				Database: route.JSONAuditOutputDatabase{
+					Engine: "sqlite",
+					DSN:    dir + string(os.PathSeparator) + "audit.sqlite",
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("prepare database sink failed: %v", err)
+	}
+
+	ctx := &zoox.Context{Logger: logger.New()}
+	fileCfg := &route.JSONAudit{
+		Enable: true,
+		Output: route.JSONAuditOutput{
+			Provider: "file",
+			File:     route.JSONAuditOutputFile{Path: filePath},
+		},
+	}
+	j.emitAuditLine(ctx, fileCfg, []byte(`{"a":1}`))
+
+	sqlDB, err := j.db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if len(j.fileHandles) != 0 || j.db != nil {
+		t.Fatalf("expected sinks to be released, files=%d db=%v", len(j.fileHandles), j.db)
+	}
+	if err := sqlDB.Ping(); err == nil {
+		t.Fatal("expected the database connection to be closed")
+	}
+}
+
+func TestEmitAuditLine_DroppedAfterClose(t *testing.T) {
+	path := t.TempDir() + string(os.PathSeparator) + "audit.ndjson"
+	j := New()
+	cfg := &route.JSONAudit{
+		Enable: true,
+		Output: route.JSONAuditOutput{
+			Provider: "file",
+			File:     route.JSONAuditOutputFile{Path: path},
+		},
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	j.emitAuditLine(&zoox.Context{Logger: logger.New()}, cfg, []byte(`{"a":1}`))
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no audit file after close, stat err=%v", err)
+	}
+	if len(j.fileHandles) != 0 {
+		t.Fatalf("expected no file handle to be opened after close, got %d", len(j.fileHandles))
+	}
+}
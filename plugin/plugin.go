@@ -4,9 +4,12 @@ import (
 	"net/http"
 
 	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
 	"github.com/go-zoox/zoox"
 )
 
+// Plugin hooks into the gateway. Plugins holding resources (files, database handles, ...)
+// may also implement io.Closer; Close is called on shutdown, once no request is in flight.
 type Plugin interface {
 	// prepare
 	Prepare(app *zoox.Application, cfg *config.Config) (err error)
@@ -17,3 +20,11 @@ type Plugin interface {
 	// response
 	OnResponse(ctx *zoox.Context, res *http.Response) (err error)
 }
+
+// Describer is implemented by plugins whose configuration depends on the route; the
+// admin API lists the configuration they resolve for each route.
+type Describer interface {
+	// Describe returns the name of the plugin and its effective configuration for rt,
+	// or a nil configuration when the plugin does not apply to rt.
+	Describe(rt *route.Route) (name string, cfg any)
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/go-zoox/api-gateway/plugin/clientcert"
+	"github.com/go-zoox/api-gateway/router"
 	"github.com/go-zoox/zoox"
 )
 
@@ -16,8 +18,9 @@ type KeyExtractor interface {
 // ExtractorFactory creates key extractors based on configuration
 type ExtractorFactory struct{}
 
-// NewExtractor creates a key extractor based on key type
-func (f *ExtractorFactory) NewExtractor(keyType, keyHeader string) KeyExtractor {
+// NewExtractor creates a key extractor based on key type; keyName is the header of the header
+// key type, or the route param of the param key type
+func (f *ExtractorFactory) NewExtractor(keyType, keyName string) KeyExtractor {
 	switch keyType {
 	case "ip":
 		return &IPExtractor{}
@@ -27,11 +30,18 @@ func (f *ExtractorFactory) NewExtractor(keyType, keyHeader string) KeyExtractor
 		return &APIKeyExtractor{}
 	case "clientid":
 		return &ClientIDExtractor{}
+	case "clientcert":
+		return &ClientCertExtractor{}
 	case "header":
-		if keyHeader == "" {
+		if keyName == "" {
 			return &IPExtractor{} // fallback to IP if header not specified
 		}
-		return &HeaderExtractor{HeaderName: keyHeader}
+		return &HeaderExtractor{HeaderName: keyName}
+	case "param":
+		if keyName == "" {
+			return &IPExtractor{} // fallback to IP if param not specified
+		}
+		return &ParamExtractor{ParamName: keyName}
 	default:
 		return &IPExtractor{} // default to IP
 	}
@@ -145,6 +155,20 @@ func (e *ClientIDExtractor) Extract(ctx *zoox.Context, req *http.Request) (strin
 	return ipExtractor.Extract(ctx, req)
 }
 
+// ClientCertExtractor extracts the client identity of a verified TLS client certificate,
+// as mapped by the route's client_cert policy (the certificate subject by default).
+type ClientCertExtractor struct{}
+
+func (e *ClientCertExtractor) Extract(ctx *zoox.Context, req *http.Request) (string, error) {
+	if identity, ok := clientcert.Identity(req); ok {
+		return "clientcert:" + identity, nil
+	}
+
+	// Fallback to IP if the client presented no certificate
+	ipExtractor := &IPExtractor{}
+	return ipExtractor.Extract(ctx, req)
+}
+
 // HeaderExtractor extracts value from a custom header
 type HeaderExtractor struct {
 	HeaderName string
@@ -160,3 +184,19 @@ func (e *HeaderExtractor) Extract(ctx *zoox.Context, req *http.Request) (string,
 	ipExtractor := &IPExtractor{}
 	return ipExtractor.Extract(ctx, req)
 }
+
+// ParamExtractor extracts the value of a named parameter of the route path, like tenant in
+// /tenants/:tenant/orders
+type ParamExtractor struct {
+	ParamName string
+}
+
+func (e *ParamExtractor) Extract(ctx *zoox.Context, req *http.Request) (string, error) {
+	if value := router.ParamsFromRequest(req)[e.ParamName]; value != "" {
+		return "param:" + e.ParamName + ":" + value, nil
+	}
+
+	// Fallback to IP if the route captured no such param
+	ipExtractor := &IPExtractor{}
+	return ipExtractor.Extract(ctx, req)
+}
@@ -1,11 +1,16 @@
 package ratelimit
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
 
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/router"
 	"github.com/go-zoox/zoox"
 )
 
@@ -137,6 +142,36 @@ func TestHeaderExtractor(t *testing.T) {
 	}
 }
 
+func TestParamExtractor(t *testing.T) {
+	extractor := &ParamExtractor{ParamName: "tenant"}
+
+	req := httptest.NewRequest("GET", "/tenants/acme/orders", nil)
+	req = router.WithRoute(req, &route.Route{Name: "orders"}, router.Params{"tenant": "acme"})
+	ctx := &zoox.Context{
+		Request: req,
+		Path:    req.URL.Path,
+	}
+
+	key, err := extractor.Extract(ctx, req)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if expected := "param:tenant:acme"; key != expected {
+		t.Errorf("Extract() = %v, want %v", key, expected)
+	}
+
+	// Fallback to IP without the param
+	req = httptest.NewRequest("GET", "/orders", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	key, err = extractor.Extract(&zoox.Context{Request: req}, req)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if expected := "ip:192.0.2.1"; key != expected {
+		t.Errorf("Extract() = %v, want %v", key, expected)
+	}
+}
+
 func TestExtractorFactory(t *testing.T) {
 	factory := &ExtractorFactory{}
 
@@ -150,7 +185,9 @@ func TestExtractorFactory(t *testing.T) {
 		{"User extractor", "user", "", "UserExtractor"},
 		{"API Key extractor", "apikey", "", "APIKeyExtractor"},
 		{"Client ID extractor", "clientid", "", "ClientIDExtractor"},
+		{"Client certificate extractor", "clientcert", "", "ClientCertExtractor"},
 		{"Header extractor", "header", "X-Custom", "HeaderExtractor"},
+		{"Param extractor", "param", "tenant", "ParamExtractor"},
 		{"Default to IP", "unknown", "", "IPExtractor"},
 	}
 
@@ -206,6 +243,36 @@ func TestClientIDExtractor(t *testing.T) {
 	})
 }
 
+func TestClientCertExtractor(t *testing.T) {
+	e := &ClientCertExtractor{}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.9:1234"
+	req.TLS = &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{
+			{Subject: pkix.Name{CommonName: "svc-a", Organization: []string{"acme"}}},
+		}},
+	}
+	key, err := e.Extract(&zoox.Context{Request: req}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "clientcert:CN=svc-a,O=acme" {
+		t.Fatalf("got %q", key)
+	}
+
+	// no certificate
+	req2 := httptest.NewRequest("GET", "/", nil)
+	req2.RemoteAddr = "10.0.0.9:1234"
+	key2, err := e.Extract(&zoox.Context{Request: req2}, req2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key2 != "ip:10.0.0.9" {
+		t.Fatalf("got %q", key2)
+	}
+}
+
 func TestExtractorFactory_HeaderWithoutNameUsesIPExtractor(t *testing.T) {
 	f := &ExtractorFactory{}
 	ext := f.NewExtractor("header", "")
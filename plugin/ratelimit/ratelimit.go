@@ -9,7 +9,10 @@ import (
 
 	"github.com/go-zoox/api-gateway/config"
 	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/api-gateway/metrics"
 	"github.com/go-zoox/api-gateway/plugin"
+	"github.com/go-zoox/api-gateway/router"
+	"github.com/go-zoox/api-gateway/tracing"
 	"github.com/go-zoox/proxy"
 	"github.com/go-zoox/zoox"
 )
@@ -69,7 +72,7 @@ func (r *RateLimit) attachApplicationCache(app *zoox.Application) {
 
 // OnRequest checks rate limit before forwarding request
 func (r *RateLimit) OnRequest(ctx *zoox.Context, req *http.Request) error {
-	rateLimitConfig := r.getRateLimitConfig(ctx.Path)
+	rateLimitConfig := r.configFor(router.FromRequest(req), ctx.Path)
 	if rateLimitConfig == nil || !rateLimitConfig.Enable {
 		return nil
 	}
@@ -84,7 +87,11 @@ func (r *RateLimit) OnRequest(ctx *zoox.Context, req *http.Request) error {
 		return nil
 	}
 
-	extractor := r.extractorFactory.NewExtractor(rateLimitConfig.KeyType, rateLimitConfig.KeyHeader)
+	keyName := rateLimitConfig.KeyHeader
+	if rateLimitConfig.KeyType == "param" {
+		keyName = rateLimitConfig.KeyParam
+	}
+	extractor := r.extractorFactory.NewExtractor(rateLimitConfig.KeyType, keyName)
 	key, err := extractor.Extract(ctx, req)
 	if err != nil {
 		ctx.Logger.Warnf("[plugin:ratelimit] failed to extract key: %s", err)
@@ -120,6 +127,9 @@ func (r *RateLimit) OnRequest(ctx *zoox.Context, req *http.Request) error {
 	*req = *req.WithContext(reqCtx)
 
 	if !allowed {
+		metrics.RateLimit.WithLabelValues(metrics.RouteName(req), "denied").Inc()
+		tracing.SetAttributes(req, tracing.RateLimitKey.String("denied"))
+
 		message := rateLimitConfig.Message
 		if message == "" {
 			message = "Too Many Requests"
@@ -148,6 +158,8 @@ func (r *RateLimit) OnRequest(ctx *zoox.Context, req *http.Request) error {
 		return proxy.NewHTTPError(429, message)
 	}
 
+	metrics.RateLimit.WithLabelValues(metrics.RouteName(req), "allowed").Inc()
+	tracing.SetAttributes(req, tracing.RateLimitKey.String("allowed"))
 	return nil
 }
 
@@ -169,6 +181,29 @@ func (r *RateLimit) OnResponse(ctx *zoox.Context, res *http.Response) error {
 	return nil
 }
 
+// Describe returns the rate limit applied to rt (plugin.Describer).
+func (r *RateLimit) Describe(rt *route.Route) (string, any) {
+	if cfg := r.configFor(rt, rt.Path); cfg != nil {
+		return "rate_limit", cfg
+	}
+	return "rate_limit", nil
+}
+
+// configFor returns the rate limit of rt, the route the gateway matched, or of the route
+// configured for path when the route is not known (route wins over global).
+func (r *RateLimit) configFor(rt *route.Route, path string) *route.RateLimit {
+	if rt == nil {
+		return r.getRateLimitConfig(path)
+	}
+	if rt.RateLimit.Enable {
+		return &rt.RateLimit
+	}
+	if r.globalConfig.Enable {
+		return &r.globalConfig
+	}
+	return nil
+}
+
 func (r *RateLimit) getRateLimitConfig(path string) *route.RateLimit {
 	routePaths := make([]string, 0, len(r.routeConfigs))
 	for routePath := range r.routeConfigs {
@@ -0,0 +1,168 @@
+// Package requestid identifies every request served by the gateway with an ID, sent by
+// the client or generated, and carries it in the request context.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-zoox/logger"
+	cst "github.com/go-zoox/logger/components/transport"
+	"github.com/go-zoox/logger/transport/console"
+	"github.com/google/uuid"
+)
+
+// Header is the header zoox reads the request ID from, set besides a custom header so
+// that ctx.RequestID() returns the same ID.
+const Header = "X-Request-Id"
+
+// MaxLength is the longest ID accepted from a client.
+const MaxLength = 128
+
+// Generators lists how request IDs can be generated.
+var Generators = []string{"uuidv7", "ulid"}
+
+type contextKey struct{}
+
+// state is the ID of a request and its logger, created on first use.
+type state struct {
+	id string
+
+	once   sync.Once
+	logger *logger.Logger
+}
+
+// Generate returns a new ID: a UUIDv7 or a ULID, both sorted by creation time.
+func Generate(generator string) string {
+	if generator == "ulid" {
+		return newULID(time.Now())
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+// Valid reports whether an ID sent by a client is kept: up to MaxLength printable ASCII
+// characters, without spaces.
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// WithID returns a shallow copy of req carrying id in its context.
+func WithID(req *http.Request, id string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), contextKey{}, &state{id: id}))
+}
+
+// FromContext returns the request ID carried by ctx, or an empty string.
+func FromContext(ctx context.Context) string {
+	if s, ok := ctx.Value(contextKey{}).(*state); ok {
+		return s.id
+	}
+	return ""
+}
+
+// FromRequest returns the ID of req, or an empty string.
+func FromRequest(req *http.Request) string {
+	return FromContext(req.Context())
+}
+
+// Message appends the ID of req to an error message sent to the client, so that the
+// client can report it.
+func Message(req *http.Request, message string) string {
+	id := FromRequest(req)
+	if id == "" {
+		return message
+	}
+	return fmt.Sprintf("%s (request_id: %s)", message, id)
+}
+
+// output is the console transport shared by the loggers of all requests, which only add
+// their request ID to each line.
+var output = console.New()
+
+// plain is the logger of requests without an ID.
+var plain = sync.OnceValue(func() *logger.Logger {
+	return newLogger(output)
+})
+
+// Logger returns the logger of req, which ends every line with the request ID. Requests
+// without an ID share a plain logger.
+func Logger(req *http.Request) *logger.Logger {
+	s, ok := req.Context().Value(contextKey{}).(*state)
+	if !ok {
+		return plain()
+	}
+
+	s.once.Do(func() {
+		s.logger = newLogger(&transport{suffix: []byte(" request_id=" + s.id), next: output})
+	})
+	return s.logger
+}
+
+func newLogger(t cst.Transport) *logger.Logger {
+	return logger.New(func(opt *logger.Option) {
+		opt.Level = logger.GetLevel()
+		opt.Transports = map[string]cst.Transport{"console": t}
+	})
+}
+
+// transport ends every line with the request ID before writing it to next.
+type transport struct {
+	suffix []byte
+	next   cst.Transport
+}
+
+func (t *transport) Write(p []byte) (int, error) {
+	return t.next.Write(append(p[:len(p):len(p)], t.suffix...))
+}
+
+func (t *transport) WriteWithLevel(p []byte, level string) (int, error) {
+	return t.next.WriteWithLevel(append(p[:len(p):len(p)], t.suffix...), level)
+}
+
+// crockford is the alphabet of ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID: a 48-bit millisecond timestamp and 80 random bits, encoded in
+// 26 Crockford base32 characters.
+func newULID(now time.Time) string {
+	var id [16]byte
+	ms := uint64(now.UnixMilli())
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> (40 - 8*i))
+	}
+	// crypto/rand.Read never returns an error since Go 1.24: it crashes the program if
+	// the system random source fails
+	rand.Read(id[6:])
+
+	// 130 bits, the first 2 being zero
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		bit := 128 - 5*(26-i)
+		var v byte
+		for b := 0; b < 5; b++ {
+			pos := bit + b
+			if pos < 0 {
+				continue
+			}
+			v |= (id[pos/8] >> (7 - pos%8) & 1) << (4 - b)
+		}
+		out[i] = crockford[v]
+	}
+	return string(out)
+}
@@ -0,0 +1,101 @@
+package requestid
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestGenerate(t *testing.T) {
+	id, err := uuid.Parse(Generate("uuidv7"))
+	if err != nil || id.Version() != 7 {
+		t.Errorf("expected a UUIDv7, got %s", id)
+	}
+
+	ulid := Generate("ulid")
+	if len(ulid) != 26 || strings.Trim(ulid, crockford) != "" {
+		t.Errorf("expected a ULID, got %s", ulid)
+	}
+}
+
+func TestULID(t *testing.T) {
+	// the timestamp of the ULID spec example
+	now := time.UnixMilli(1469918176385)
+	if id := newULID(now); !strings.HasPrefix(id, "01ARYZ6S41") {
+		t.Errorf("expected the timestamp to be encoded first, got %s", id)
+	}
+
+	ids := []string{newULID(now.Add(2 * time.Millisecond)), newULID(now), newULID(now.Add(time.Millisecond))}
+	sorted := append([]string{}, ids...)
+	sort.Strings(sorted)
+	if sorted[0] != ids[1] || sorted[1] != ids[2] || sorted[2] != ids[0] {
+		t.Errorf("expected ULIDs to sort by time, got %v", sorted)
+	}
+}
+
+func TestValid(t *testing.T) {
+	cases := map[string]bool{
+		"req-1":                   true,
+		"0190b8a2-7c1e-7f6a-9f1d": true,
+		"":                        false,
+		"with space":              false,
+		"line\nbreak":             false,
+		"café":                    false,
+		strings.Repeat("x", 128):  true,
+		strings.Repeat("x", 129):  false,
+	}
+	for id, want := range cases {
+		if Valid(id) != want {
+			t.Errorf("expected Valid(%q) to be %v", id, want)
+		}
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if FromRequest(req) != "" {
+		t.Error("expected no ID")
+	}
+	if Logger(req) != Logger(httptest.NewRequest("GET", "/", nil)) {
+		t.Error("expected requests without an ID to share a logger")
+	}
+	if Message(req, "Bad Gateway") != "Bad Gateway" {
+		t.Error("expected the message to be left alone without an ID")
+	}
+
+	req = WithID(req, "req-1")
+	if FromRequest(req) != "req-1" {
+		t.Errorf("expected req-1, got %s", FromRequest(req))
+	}
+	if msg := Message(req, "Bad Gateway"); msg != "Bad Gateway (request_id: req-1)" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if Logger(req) != Logger(req) {
+		t.Error("expected a single logger per request")
+	}
+}
+
+type recorder struct{ bytes.Buffer }
+
+func (r *recorder) WriteWithLevel(p []byte, level string) (int, error) {
+	return r.Write(p)
+}
+
+func TestTransport(t *testing.T) {
+	out := &recorder{}
+	tr := &transport{suffix: []byte(" request_id=req-1"), next: out}
+
+	line := []byte("2024/01/02 15:04:05 INFO [route: api] GET /api")
+	tr.WriteWithLevel(line, "info")
+	if out.String() != "2024/01/02 15:04:05 INFO [route: api] GET /api request_id=req-1" {
+		t.Errorf("unexpected line %q", out.String())
+	}
+	if string(line) != "2024/01/02 15:04:05 INFO [route: api] GET /api" {
+		t.Errorf("expected the line to be left alone, got %q", line)
+	}
+}
@@ -0,0 +1,115 @@
+package router
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/core-utils/fmt"
+)
+
+// Methods lists the methods accepted in match.methods.
+var Methods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// ValidateMatch reports the first invalid condition of m.
+func ValidateMatch(m route.Match) error {
+	for _, method := range m.Methods {
+		if !slices.Contains(Methods, strings.ToUpper(method)) {
+			return fmt.Errorf("unsupported method: %s", method)
+		}
+	}
+
+	groups := map[string][]route.MatchCondition{"header": m.Headers, "query": m.Query, "cookie": m.Cookies}
+	for kind, conditions := range groups {
+		for _, c := range conditions {
+			if c.Name == "" {
+				return fmt.Errorf("%s condition without a name", kind)
+			}
+			switch c.EffectiveType() {
+			case "exact", "present":
+			case "regex":
+				if _, err := compile(c.Value); err != nil {
+					return fmt.Errorf("%s %s: invalid regex: %s", kind, c.Name, err)
+				}
+			default:
+				return fmt.Errorf("%s %s: unsupported condition type: %s", kind, c.Name, c.Type)
+			}
+		}
+	}
+
+	return nil
+}
+
+// matchConditions reports whether every condition of m holds for req.
+func matchConditions(m route.Match, req *http.Request) (bool, error) {
+	if len(m.Methods) > 0 && !containsFold(m.Methods, req.Method) {
+		return false, nil
+	}
+
+	for _, c := range m.Headers {
+		values, present := req.Header[http.CanonicalHeaderKey(c.Name)]
+		if ok, err := matchCondition(c, values, present); err != nil || !ok {
+			return false, err
+		}
+	}
+
+	if len(m.Query) > 0 {
+		query := req.URL.Query()
+		for _, c := range m.Query {
+			values, present := query[c.Name]
+			if ok, err := matchCondition(c, values, present); err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+
+	for _, c := range m.Cookies {
+		var values []string
+		for _, cookie := range req.Cookies() {
+			if cookie.Name == c.Name {
+				values = append(values, cookie.Value)
+			}
+		}
+		if ok, err := matchCondition(c, values, len(values) > 0); err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// matchCondition reports whether one of values satisfies c.
+func matchCondition(c route.MatchCondition, values []string, present bool) (bool, error) {
+	switch c.EffectiveType() {
+	case "present":
+		return present, nil
+	case "exact":
+		return slices.Contains(values, c.Value), nil
+	case "regex":
+		re, err := compile(c.Value)
+		if err != nil {
+			return false, err
+		}
+		for _, v := range values {
+			if re.MatchString(v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported condition type: %s", c.Type)
+	}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
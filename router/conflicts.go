@@ -0,0 +1,166 @@
+package router
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-zoox/core-utils/fmt"
+)
+
+const (
+	// ConflictsWarn logs the conflicts of the routes.
+	ConflictsWarn = "warn"
+	// ConflictsReject refuses a configuration with conflicting routes.
+	ConflictsReject = "reject"
+)
+
+// ConflictPolicies lists the values accepted in router.conflicts.
+var ConflictPolicies = []string{ConflictsWarn, ConflictsReject}
+
+// Conflict is a route that can never match, because a route before it matches every request
+// it would, or a route whose name is already used by another route.
+type Conflict struct {
+	// Route is the name of the route and Index its position in the configuration
+	Route string
+	Index int
+	// By is the name of the route shadowing it, or of the first route with the same name,
+	// and ByIndex its position
+	By      string
+	ByIndex int
+	// Duplicate is set when the conflict is the name of the route
+	Duplicate bool
+}
+
+func (c Conflict) String() string {
+	if c.Duplicate {
+		return fmt.Sprintf("duplicate route name: %s (routes #%d and #%d)", c.Route, c.ByIndex+1, c.Index+1)
+	}
+	return fmt.Sprintf("route %s (#%d) can never match, route %s (#%d) matches its requests first", c.Route, c.Index+1, c.By, c.ByIndex+1)
+}
+
+// Conflicts returns the routes with a name used by an earlier route, and the routes shadowed
+// by another one: a route that is tried first for every request the route matches, and matches
+// all of them. The analysis is conservative, a conflict is certain but not every shadowed route
+// is found (a regex is only compared to exact paths and to the same regex).
+func (m *Matcher) Conflicts() []Conflict {
+	var conflicts []Conflict
+
+	names := map[string]int{}
+	for i, r := range m.routes {
+		if r.route.Name == "" {
+			continue
+		}
+		if first, exists := names[r.route.Name]; exists {
+			conflicts = append(conflicts, Conflict{Route: r.route.Name, Index: i, By: r.route.Name, ByIndex: first, Duplicate: true})
+			continue
+		}
+		names[r.route.Name] = i
+	}
+
+	for b := range m.routes {
+		for a := range m.routes {
+			if a != b && m.precedes(a, b) && m.routes[a].covers(&m.routes[b]) {
+				conflicts = append(conflicts, Conflict{Route: m.routes[b].route.Name, Index: b, By: m.routes[a].route.Name, ByIndex: a})
+				break
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// precedes reports whether route a is tried before route b for every request that may match
+// both: by priority, then in the order of the mode.
+func (m *Matcher) precedes(a, b int) bool {
+	ra, rb := &m.routes[a], &m.routes[b]
+	if pa, pb := ra.route.Priority, rb.route.Priority; pa != pb {
+		return pa > pb
+	}
+
+	if m.mode == LongestPrefix {
+		// exact paths are tried first, then regexes, then prefixes from the longest
+		if ka, kb := ra.kind(), rb.kind(); ka != kb {
+			return ka < kb
+		}
+		if ra.kind() == kindPrefix {
+			if la, lb := len(ra.literal()), len(rb.literal()); la != lb {
+				return la > lb
+			}
+		}
+	}
+
+	return a < b
+}
+
+const (
+	kindExact = iota
+	kindRegex
+	kindPrefix
+)
+
+// kind returns the group of the route in the longest_prefix mode; param routes are prefixes
+// of their literal path.
+func (r *compiledRoute) kind() int {
+	switch r.route.PathType {
+	case "exact":
+		return kindExact
+	case "regex":
+		return kindRegex
+	default:
+		return kindPrefix
+	}
+}
+
+// literal returns the path every request path of a prefix or param route starts with.
+func (r *compiledRoute) literal() string {
+	if r.pattern != nil {
+		return r.pattern.literal
+	}
+	return r.route.Path
+}
+
+// covers reports whether r matches every request other matches.
+func (r *compiledRoute) covers(other *compiledRoute) bool {
+	if !r.route.Match.IsZero() && !reflect.DeepEqual(r.route.Match, other.route.Match) {
+		return false
+	}
+
+	if len(r.hosts) > 0 {
+		if len(other.hosts) == 0 {
+			return false
+		}
+		for _, host := range other.hosts {
+			if !slices.Contains(r.hosts, host) && (strings.HasPrefix(host, "*.") || !matchHosts(r.hosts, host)) {
+				return false
+			}
+		}
+	}
+
+	path := other.route.Path
+	switch r.route.PathType {
+	case "prefix", "":
+		if other.route.PathType == "regex" {
+			return false
+		}
+		return strings.HasPrefix(other.literal(), r.route.Path)
+	case "exact":
+		return other.route.PathType == "exact" && path == r.route.Path
+	case "param":
+		switch other.route.PathType {
+		case "exact":
+			_, ok := r.pattern.match(path)
+			return ok
+		case "param":
+			return path == r.route.Path
+		}
+	case "regex":
+		switch other.route.PathType {
+		case "exact":
+			return r.regex.MatchString(path)
+		case "regex":
+			return path == r.route.Path
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"net"
+	"strings"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/core-utils/fmt"
+)
+
+// Hostname returns the lowercase host of a Host header, without its port.
+func Hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// MatchHost reports whether host, as returned by Hostname, matches one of patterns;
+// a wildcard (*.example.com) matches a single label, like a.example.com but neither
+// example.com nor a.b.example.com.
+func MatchHost(patterns []string, host string) bool {
+	if host == "" {
+		return false
+	}
+
+	normalized := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		normalized[i] = normalizeHost(pattern)
+	}
+	return matchHosts(normalized, host)
+}
+
+// matchHosts is MatchHost for lowercase patterns.
+func matchHosts(patterns []string, host string) bool {
+	if host == "" {
+		return false
+	}
+
+	wildcard := Wildcard(host)
+	for _, pattern := range patterns {
+		if pattern == host || pattern == wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Wildcard returns the wildcard pattern matching host, as returned by Hostname:
+// *.example.com for a.example.com, or "" when host has no parent domain.
+func Wildcard(host string) string {
+	if i := strings.IndexByte(host, '.'); i > 0 {
+		return "*" + host[i:]
+	}
+	return ""
+}
+
+// ValidateHost reports whether pattern is a host name or a wildcard (*.example.com).
+func ValidateHost(pattern string) error {
+	name := normalizeHost(pattern)
+	if strings.HasPrefix(name, "*.") {
+		name = name[2:]
+	}
+	if name == "" || strings.ContainsAny(name, "*/:@ ") {
+		return fmt.Errorf("invalid host: %q", pattern)
+	}
+	return nil
+}
+
+// HostDefaults returns the default routes of every host in cfg.Hosts.
+func HostDefaults(cfg *config.Config) []*route.Route {
+	routes := make([]*route.Route, 0, len(cfg.Hosts))
+	for _, h := range cfg.Hosts {
+		routes = append(routes, hostDefaultRoute(h))
+	}
+	return routes
+}
+
+func hostDefaultRoute(h config.Host) *route.Route {
+	return &route.Route{
+		Name:    "default:" + normalizeHost(h.Host),
+		Hosts:   []string{h.Host},
+		Backend: h.Backend,
+	}
+}
+
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+}
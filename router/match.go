@@ -1,57 +1,106 @@
 package router
 
 import (
+	"context"
 	"errors"
+	"net/http"
 	"regexp"
+	"sync"
 
 	"github.com/go-zoox/api-gateway/config"
 	"github.com/go-zoox/api-gateway/core/route"
-	"github.com/go-zoox/core-utils/fmt"
-	"github.com/go-zoox/core-utils/strings"
 )
 
 // ErrRouteNotFound is returned when no route matches the path and no default backend is configured.
 var ErrRouteNotFound = errors.New("route not found")
 
-// MatchPath returns the first route in the slice (config order) whose path rule matches the request path.
-// For prefix routes, the first match wins; order matters in configuration.
+// MatchPath returns the first route in the slice (config order, the highest priority first) whose path
+// rule matches the request path. For prefix routes, the first match wins; order matters in configuration.
+// Routes with hosts or match conditions are skipped, as a path alone cannot satisfy them.
 func MatchPath(routes []route.Route, path string) (*route.Route, error) {
-	for _, r := range routes {
-		switch r.PathType {
-		case "prefix", "":
-			if strings.StartsWith(path, r.Path) {
-				return &r, nil
-			}
-		case "regex":
-			if ok, err := regexp.MatchString(r.Path, path); err != nil {
-				return nil, err
-			} else if ok {
-				return &r, nil
-			}
-		default:
-			return nil, fmt.Errorf("unsupport path type: %s", r.PathType)
-		}
+	return MatchHostPath(routes, "", path)
+}
+
+// MatchHostPath is MatchPath for a request to host, as returned by Hostname: routes with hosts
+// only match when one of them does. Like [RouteForPath], it compiles routes on every call.
+func MatchHostPath(routes []route.Route, host, path string) (*route.Route, error) {
+	m, err := newMatcher(routes, FirstMatch)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := m.find(host, path, nil)
+	return r, err
+}
+
+// MatchRequest returns the first route in the slice (config order, the highest priority first) whose hosts, path rule and
+// match conditions hold for req. It compiles routes on every call.
+func MatchRequest(routes []route.Route, req *http.Request) (*route.Route, error) {
+	m, err := newMatcher(routes, FirstMatch)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := m.find(Hostname(req.Host), req.URL.Path, req)
+	return r, err
+}
+
+// RouteForPath returns the service route for a request to host and path, using the matching
+// route, or the default backend of the host, or the default backend from cfg when no route matches
+// and a default backend is configured. It compiles cfg on every call; the gateway compiles it once
+// with [Compile].
+func RouteForPath(cfg *config.Config, host, path string) (*route.Route, error) {
+	m, err := Compile(cfg)
+	if err != nil {
+		return nil, err
 	}
-	return nil, ErrRouteNotFound
+	r, _, err := m.MatchPath(host, path)
+	return r, err
 }
 
-// RouteForPath returns the service route for a request path, using the first matching route, or
-// the default backend from cfg when no route matches and default backend is configured.
-func RouteForPath(cfg *config.Config, path string) (*route.Route, error) {
-	r, err := MatchPath(cfg.Routes, path)
+// RouteForRequest is RouteForPath with the match conditions of the routes evaluated on req.
+func RouteForRequest(cfg *config.Config, req *http.Request) (*route.Route, error) {
+	m, err := Compile(cfg)
 	if err != nil {
-		if !errors.Is(err, ErrRouteNotFound) {
-			return nil, err
-		}
-	} else {
-		return r, nil
+		return nil, err
+	}
+	r, _, err := m.Match(req)
+	return r, err
+}
+
+type routeKey struct{}
+
+// matched is the route the gateway matched for a request, with its parameters.
+type matched struct {
+	route  *route.Route
+	params Params
+}
+
+// WithRoute returns a shallow copy of req carrying r, the route the gateway matched, and the
+// parameters captured by its path.
+func WithRoute(req *http.Request, r *route.Route, params Params) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), routeKey{}, &matched{route: r, params: params}))
+}
+
+// FromRequest returns the route the gateway matched for req, or nil before the match.
+func FromRequest(req *http.Request) *route.Route {
+	m, _ := req.Context().Value(routeKey{}).(*matched)
+	if m == nil {
+		return nil
+	}
+	return m.route
+}
+
+// regexps caches the regular expressions of match conditions.
+var regexps sync.Map
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
 	}
 
-	if cfg.Backend.Service.Name != "" {
-		return &route.Route{
-			Name:    "default",
-			Backend: cfg.Backend,
-		}, nil
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
 	}
-	return nil, ErrRouteNotFound
+	regexps.Store(pattern, re)
+	return re, nil
 }
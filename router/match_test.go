@@ -1,8 +1,14 @@
 package router
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/go-zoox/api-gateway/config"
 	"github.com/go-zoox/api-gateway/core/route"
 	"github.com/go-zoox/api-gateway/core/service"
 )
@@ -85,3 +91,448 @@ func TestMatchPath(t *testing.T) {
 		t.Fatalf("expected ip3.httpbin.zcorky.com, got %s", s.Backend.Service.Name)
 	}
 }
+
+func TestMatchRequest(t *testing.T) {
+	backend := func(name string) route.Backend {
+		return route.Backend{Service: service.Service{Name: name, Port: 80}}
+	}
+	routes := []route.Route{
+		{
+			Name:    "canary",
+			Path:    "/api",
+			Backend: backend("canary"),
+			Match: route.Match{
+				Headers: []route.MatchCondition{{Name: "X-Canary", Value: "true"}},
+			},
+		},
+		{
+			Name:    "beta",
+			Path:    "/api",
+			Backend: backend("beta"),
+			Match: route.Match{
+				Cookies: []route.MatchCondition{{Name: "beta", Type: "present"}},
+			},
+		},
+		{
+			Name:    "v2",
+			Path:    "/api",
+			Backend: backend("v2"),
+			Match: route.Match{
+				Methods: []string{"post"},
+				Query:   []route.MatchCondition{{Name: "version", Type: "regex", Value: "^2(\\.\\d+)?$"}},
+			},
+		},
+		{
+			Name:    "stable",
+			Path:    "/api",
+			Backend: backend("stable"),
+		},
+	}
+
+	request := func(method, target string, header map[string]string) *http.Request {
+		req := httptest.NewRequest(method, target, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		return req
+	}
+
+	cases := []struct {
+		req  *http.Request
+		want string
+	}{
+		{request("GET", "/api/users", nil), "stable"},
+		{request("GET", "/api/users", map[string]string{"X-Canary": "true"}), "canary"},
+		{request("GET", "/api/users", map[string]string{"X-Canary": "false"}), "stable"},
+		{request("GET", "/api/users", map[string]string{"Cookie": "session=1; beta="}), "beta"},
+		{request("POST", "/api/users?version=2.1", nil), "v2"},
+		{request("GET", "/api/users?version=2.1", nil), "stable"},
+		{request("POST", "/api/users?version=12", nil), "stable"},
+	}
+	for _, c := range cases {
+		r, err := MatchRequest(routes, c.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Name != c.want {
+			t.Errorf("%s %s %v: expected %s, got %s", c.req.Method, c.req.URL, c.req.Header, c.want, r.Name)
+		}
+	}
+
+	// a path alone cannot satisfy conditions
+	r, err := MatchPath(routes, "/api/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "stable" {
+		t.Errorf("expected stable, got %s", r.Name)
+	}
+}
+
+func TestValidateMatch(t *testing.T) {
+	invalid := map[string]route.Match{
+		"method":  {Methods: []string{"FETCH"}},
+		"no name": {Headers: []route.MatchCondition{{Value: "x"}}},
+		"type":    {Query: []route.MatchCondition{{Name: "q", Type: "prefix"}}},
+		"regex":   {Cookies: []route.MatchCondition{{Name: "c", Type: "regex", Value: "("}}},
+	}
+	for name, m := range invalid {
+		if err := ValidateMatch(m); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	valid := route.Match{
+		Methods: []string{"get", "POST"},
+		Headers: []route.MatchCondition{{Name: "X-Canary"}, {Name: "X-Version", Type: "regex", Value: "^v2"}},
+	}
+	if err := ValidateMatch(valid); err != nil {
+		t.Errorf("expected a valid match, got %s", err)
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		host     string
+		want     bool
+	}{
+		{[]string{"api.example.com"}, "api.example.com", true},
+		{[]string{"API.example.com."}, "api.example.com", true},
+		{[]string{"api.example.com"}, "admin.example.com", false},
+		{[]string{"*.example.com"}, "admin.example.com", true},
+		{[]string{"*.example.com"}, "example.com", false},
+		{[]string{"*.example.com"}, "a.b.example.com", false},
+		{[]string{"api.example.com", "*.example.org"}, "www.example.org", true},
+		{[]string{"api.example.com"}, "", false},
+	}
+	for _, c := range cases {
+		if got := MatchHost(c.patterns, c.host); got != c.want {
+			t.Errorf("MatchHost(%v, %q): expected %v, got %v", c.patterns, c.host, c.want, got)
+		}
+	}
+
+	for host, want := range map[string]string{
+		"API.example.com:8080": "api.example.com",
+		"example.com.":         "example.com",
+		"[::1]:443":            "::1",
+		"localhost":            "localhost",
+	} {
+		if got := Hostname(host); got != want {
+			t.Errorf("Hostname(%q): expected %q, got %q", host, want, got)
+		}
+	}
+}
+
+func TestRouteForPathHosts(t *testing.T) {
+	backend := func(name string) route.Backend {
+		return route.Backend{Service: service.Service{Name: name, Port: 80}}
+	}
+	cfg := &config.Config{
+		Backend: backend("fallback"),
+		Hosts: []config.Host{
+			{Host: "*.example.com", Backend: backend("wildcard")},
+			{Host: "admin.example.com", Backend: backend("admin")},
+		},
+		Routes: []route.Route{
+			{Name: "api-users", Path: "/users", Hosts: []string{"api.example.com"}, Backend: backend("api-users")},
+			{Name: "admin-users", Path: "/users", Hosts: []string{"admin.example.com"}, Backend: backend("admin-users")},
+			{Name: "health", Path: "/health", Backend: backend("health")},
+		},
+	}
+
+	cases := []struct {
+		host, path string
+		want       string
+	}{
+		{"api.example.com", "/users/1", "api-users"},
+		{"admin.example.com:8080", "/users/1", "admin-users"},
+		{"www.example.com", "/users/1", "wildcard"},
+		{"admin.example.com", "/settings", "admin"},
+		{"api.example.com", "/health", "health"},
+		{"example.org", "/users/1", "fallback"},
+	}
+	for _, c := range cases {
+		r, err := RouteForPath(cfg, c.host, c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Backend.Service.Name != c.want {
+			t.Errorf("%s%s: expected %s, got %s", c.host, c.path, c.want, r.Backend.Service.Name)
+		}
+	}
+
+	// routes with hosts need the host
+	if _, err := MatchPath(cfg.Routes, "/users"); err != ErrRouteNotFound {
+		t.Errorf("expected no route without a host, got %v", err)
+	}
+}
+
+func TestMatcherModes(t *testing.T) {
+	backend := func(name string) route.Backend {
+		return route.Backend{Service: service.Service{Name: name, Port: 80}}
+	}
+	routes := []route.Route{
+		{Name: "api", Path: "/api", Backend: backend("api")},
+		{Name: "users", Path: "/api/users", Backend: backend("users")},
+		{Name: "user", Path: "^/api/users/\\d+$", PathType: "regex", Backend: backend("user")},
+		{Name: "me", Path: "/api/users/me", PathType: "exact", Backend: backend("me")},
+		{Name: "root", Path: "/", Backend: backend("root")},
+	}
+
+	cases := []struct {
+		path                string
+		firstMatch, longest string
+	}{
+		{"/api/users/me", "api", "me"},
+		{"/api/users/me/settings", "api", "users"},
+		{"/api/users/42", "api", "user"},
+		{"/api/users", "api", "users"},
+		{"/api/posts", "api", "api"},
+		{"/about", "root", "root"},
+	}
+	for mode, want := range map[string]func(i int) string{
+		FirstMatch:    func(i int) string { return cases[i].firstMatch },
+		LongestPrefix: func(i int) string { return cases[i].longest },
+	} {
+		m, err := Compile(&config.Config{Routes: routes, Router: config.Router{Mode: mode}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, c := range cases {
+			r, _, err := m.MatchPath("", c.path)
+			if err != nil {
+				t.Fatalf("%s %s: %s", mode, c.path, err)
+			}
+			if r.Name != want(i) {
+				t.Errorf("%s %s: expected %s, got %s", mode, c.path, want(i), r.Name)
+			}
+		}
+	}
+
+	if _, err := Compile(&config.Config{Router: config.Router{Mode: "random"}}); err == nil {
+		t.Error("expected an unsupported mode to be rejected")
+	}
+	if _, err := Compile(&config.Config{Routes: []route.Route{{Name: "bad", Path: "(", PathType: "regex"}}}); err == nil {
+		t.Error("expected an invalid regex to be rejected")
+	}
+}
+
+func TestMatcherRadix(t *testing.T) {
+	paths := []string{"/", "/a", "/ab", "/abc", "/abd", "/b", "/api", "/api/v1", "/api/v2", "/apis", "", "/api/v1/users", "/a"}
+	routes := make([]route.Route, len(paths))
+	for i, path := range paths {
+		routes[i] = route.Route{Name: fmt.Sprintf("%d", i), Path: path}
+	}
+
+	m, err := newMatcher(routes, FirstMatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same routes as a linear scan of the prefixes
+	for _, path := range []string{"", "/", "/a", "/ab", "/abc", "/abcd", "/abe", "/b/c", "/ap", "/api/v1/users/1", "/apis/x", "/api/v3", "x"} {
+		want := []int{}
+		for i, p := range paths {
+			if strings.HasPrefix(path, p) {
+				want = append(want, i)
+			}
+		}
+		if got := m.candidates(path); !slices.Equal(got, want) {
+			t.Errorf("%q: expected %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestMatchParams(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          Params
+		ok            bool
+	}{
+		{"/users/:id", "/users/42", Params{"id": "42"}, true},
+		{"/users/:id", "/users/42/orders", nil, false},
+		{"/users/:id", "/users/", nil, false},
+		{"/users/:id/orders/*rest", "/users/42/orders/2024/01", Params{"id": "42", "rest": "2024/01"}, true},
+		{"/users/:id/orders/*rest", "/users/42/orders", Params{"id": "42", "rest": ""}, true},
+		{"/users/:id/orders/*rest", "/users/42/items/1", nil, false},
+		{"/files/*path", "/files", Params{"path": ""}, true},
+		{"/:tenant/api", "/acme/api", Params{"tenant": "acme"}, true},
+	}
+	for _, c := range cases {
+		p, err := compilePattern(c.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params, ok := p.match(c.path)
+		if ok != c.ok || fmt.Sprint(params) != fmt.Sprint(c.want) {
+			t.Errorf("%s %s: expected %v %v, got %v %v", c.pattern, c.path, c.want, c.ok, params, ok)
+		}
+	}
+
+	for _, invalid := range []string{"users/:id", "/users/:", "/users/:id/:id", "/files/*path/x", "/users/x:id"} {
+		if err := ValidatePattern(invalid); err == nil {
+			t.Errorf("%s: expected an error", invalid)
+		}
+	}
+
+	m, err := Compile(&config.Config{
+		Routes: []route.Route{
+			{Name: "me", Path: "/users/me", PathType: "exact"},
+			{Name: "orders", Path: "/users/:id/orders/*rest", PathType: "param"},
+			{Name: "user", Path: "/users/:id", PathType: "param"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"/users/me":          "me",
+		"/users/42":          "user",
+		"/users/42/orders/7": "orders",
+	} {
+		r, params, err := m.MatchPath("", path)
+		if err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		if r.Name != want {
+			t.Errorf("%s: expected %s, got %s", path, want, r.Name)
+		}
+		if want != "me" && params["id"] != "42" {
+			t.Errorf("%s: expected the id param, got %v", path, params)
+		}
+	}
+
+	req := WithRoute(httptest.NewRequest("GET", "/users/42", nil), &route.Route{Name: "user"}, Params{"id": "42"})
+	if FromRequest(req).Name != "user" || ParamsFromRequest(req)["id"] != "42" {
+		t.Errorf("expected the route and params of the request")
+	}
+}
+
+func TestMatcherPriority(t *testing.T) {
+	routes := []route.Route{
+		{Name: "api", Path: "/api"},
+		{Name: "users", Path: "/api/users", Priority: 10},
+		{Name: "user", Path: "^/api/users/\\d+$", PathType: "regex", Priority: 10},
+		{Name: "me", Path: "/api/users/me", PathType: "exact"},
+	}
+
+	cases := []struct {
+		path                string
+		firstMatch, longest string
+	}{
+		{"/api/users/me", "users", "users"},
+		{"/api/users/42", "users", "user"},
+		{"/api/posts", "api", "api"},
+	}
+	for _, mode := range Modes {
+		m, err := newMatcher(routes, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range cases {
+			want := c.firstMatch
+			if mode == LongestPrefix {
+				want = c.longest
+			}
+			r, _, err := m.MatchPath("", c.path)
+			if err != nil {
+				t.Fatalf("%s %s: %s", mode, c.path, err)
+			}
+			if r.Name != want {
+				t.Errorf("%s %s: expected %s, got %s", mode, c.path, want, r.Name)
+			}
+		}
+	}
+}
+
+func TestMatcherConflicts(t *testing.T) {
+	conflicts := func(mode string, routes ...route.Route) []string {
+		m, err := newMatcher(routes, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, c := range m.Conflicts() {
+			names = append(names, c.Route+"<"+c.By)
+		}
+		return names
+	}
+
+	cases := []struct {
+		name   string
+		mode   string
+		routes []route.Route
+		want   []string
+	}{
+		{
+			"prefix shadows a longer prefix", FirstMatch,
+			[]route.Route{{Name: "v1", Path: "/v1"}, {Name: "user", Path: "/v1/user"}},
+			[]string{"user<v1"},
+		},
+		{
+			"longer prefix first", FirstMatch,
+			[]route.Route{{Name: "user", Path: "/v1/user"}, {Name: "v1", Path: "/v1"}},
+			[]string{},
+		},
+		{
+			"longest prefix mode", LongestPrefix,
+			[]route.Route{{Name: "v1", Path: "/v1"}, {Name: "user", Path: "/v1/user"}, {Name: "me", Path: "/v1/user/me", PathType: "exact"}},
+			[]string{},
+		},
+		{
+			"priority", LongestPrefix,
+			[]route.Route{{Name: "v1", Path: "/v1", Priority: 1}, {Name: "user", Path: "/v1/user"}},
+			[]string{"user<v1"},
+		},
+		{
+			"prefix shadows exact and param paths", FirstMatch,
+			[]route.Route{{Name: "v1", Path: "/v1"}, {Name: "me", Path: "/v1/me", PathType: "exact"}, {Name: "user", Path: "/v1/users/:id", PathType: "param"}},
+			[]string{"me<v1", "user<v1"},
+		},
+		{
+			"regex shadows an exact path", FirstMatch,
+			[]route.Route{{Name: "id", Path: "^/users/\\d+$", PathType: "regex"}, {Name: "one", Path: "/users/1", PathType: "exact"}, {Name: "me", Path: "/users/me", PathType: "exact"}},
+			[]string{"one<id"},
+		},
+		{
+			"param shadows an exact path", FirstMatch,
+			[]route.Route{{Name: "user", Path: "/users/:id", PathType: "param"}, {Name: "me", Path: "/users/me", PathType: "exact"}, {Name: "orders", Path: "/users/me/orders", PathType: "exact"}},
+			[]string{"me<user"},
+		},
+		{
+			"regex is not compared to prefixes", FirstMatch,
+			[]route.Route{{Name: "v1", Path: "/v1"}, {Name: "re", Path: "^/v1/.*", PathType: "regex"}},
+			[]string{},
+		},
+		{
+			"hosts", FirstMatch,
+			[]route.Route{
+				{Name: "wildcard", Path: "/", Hosts: []string{"*.example.com"}},
+				{Name: "api", Path: "/api", Hosts: []string{"api.example.com"}},
+				{Name: "other", Path: "/api", Hosts: []string{"api.example.com", "example.com"}},
+				{Name: "all", Path: "/api"},
+			},
+			[]string{"api<wildcard"},
+		},
+		{
+			"match conditions", FirstMatch,
+			[]route.Route{
+				{Name: "post", Path: "/api", Match: route.Match{Methods: []string{"POST"}}},
+				{Name: "all", Path: "/api"},
+				{Name: "post-users", Path: "/api/users", Match: route.Match{Methods: []string{"POST"}}},
+			},
+			[]string{"post-users<post"},
+		},
+		{
+			"duplicate names", FirstMatch,
+			[]route.Route{{Name: "a", Path: "/a"}, {Name: "b", Path: "/b"}, {Name: "a", Path: "/c"}},
+			[]string{"a<a"},
+		},
+	}
+	for _, c := range cases {
+		if got := conflicts(c.mode, c.routes...); !slices.Equal(got, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package router
+
+import (
+	"cmp"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/core/route"
+	"github.com/go-zoox/core-utils/fmt"
+)
+
+const (
+	// FirstMatch chooses the first matching route in configuration order.
+	FirstMatch = "first_match"
+	// LongestPrefix chooses exact paths first, then regexes in configuration order, then
+	// the longest matching prefix.
+	LongestPrefix = "longest_prefix"
+)
+
+// Modes lists the modes accepted in router.mode.
+var Modes = []string{FirstMatch, LongestPrefix}
+
+// Matcher matches requests to the routes of a configuration. Routes are compiled once:
+// prefix and exact paths into a radix tree, param paths into the tree by their literal
+// prefix, regex paths into regular expressions, so a match costs a walk of the request
+// path instead of a scan of every route.
+type Matcher struct {
+	mode   string
+	routes []compiledRoute
+	tree   *radix
+	// regexes are the indexes of the regex routes, in configuration order
+	regexes []int
+	// prioritized is set when a route has a priority, so candidates are ordered by it
+	prioritized bool
+
+	// hosts are the default routes of cfg.Hosts by host name or wildcard
+	hosts    map[string]*route.Route
+	fallback *route.Route
+}
+
+type compiledRoute struct {
+	route *route.Route
+	// hosts are the lowercase hosts of the route
+	hosts []string
+	regex *regexp.Regexp
+	// pattern is the compiled path of a param route
+	pattern *pattern
+}
+
+// Compile compiles the routes and default backends of cfg.
+func Compile(cfg *config.Config) (*Matcher, error) {
+	m, err := newMatcher(cfg.Routes, cfg.Router.EffectiveMode())
+	if err != nil {
+		return nil, err
+	}
+
+	m.hosts = make(map[string]*route.Route, len(cfg.Hosts))
+	for _, r := range HostDefaults(cfg) {
+		name := normalizeHost(r.Hosts[0])
+		if _, exists := m.hosts[name]; !exists {
+			m.hosts[name] = r
+		}
+	}
+
+	if cfg.Backend.Service.Name != "" {
+		m.fallback = &route.Route{
+			Name:    "default",
+			Backend: cfg.Backend,
+		}
+	}
+
+	return m, nil
+}
+
+func newMatcher(routes []route.Route, mode string) (*Matcher, error) {
+	if !slices.Contains(Modes, mode) {
+		return nil, fmt.Errorf("unsupported router mode: %s", mode)
+	}
+
+	m := &Matcher{
+		mode:   mode,
+		routes: make([]compiledRoute, len(routes)),
+		tree:   newRadix(),
+	}
+
+	// the routes are copied, so the matcher is not affected by later changes of the slice
+	routes = slices.Clone(routes)
+	for i := range routes {
+		r := &routes[i]
+		compiled := compiledRoute{route: r}
+		if r.Priority != 0 {
+			m.prioritized = true
+		}
+		for _, host := range r.Hosts {
+			compiled.hosts = append(compiled.hosts, normalizeHost(host))
+		}
+
+		switch r.PathType {
+		case "prefix", "":
+			m.tree.Insert(r.Path, i, false)
+		case "exact":
+			m.tree.Insert(r.Path, i, true)
+		case "param":
+			p, err := compilePattern(r.Path)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: invalid param path: %s", r.Name, err)
+			}
+			compiled.pattern = p
+			// the literal path up to the first parameter narrows the candidates
+			m.tree.Insert(p.literal, i, false)
+		case "regex":
+			re, err := regexp.Compile(r.Path)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: invalid path regex: %s", r.Name, err)
+			}
+			compiled.regex = re
+			m.regexes = append(m.regexes, i)
+		default:
+			return nil, fmt.Errorf("unsupport path type: %s", r.PathType)
+		}
+
+		m.routes[i] = compiled
+	}
+
+	return m, nil
+}
+
+// Match returns the route of req and the parameters of its path, or the default backend of
+// its host, or the default backend when no route matches.
+func (m *Matcher) Match(req *http.Request) (*route.Route, Params, error) {
+	host := Hostname(req.Host)
+	r, params, err := m.find(host, req.URL.Path, req)
+	r, err = m.orDefault(host, r, err)
+	return r, params, err
+}
+
+// MatchPath is Match for a request to host and path, without the routes that have match
+// conditions, as they depend on the rest of the request.
+func (m *Matcher) MatchPath(host, path string) (*route.Route, Params, error) {
+	host = Hostname(host)
+	r, params, err := m.find(host, path, nil)
+	r, err = m.orDefault(host, r, err)
+	return r, params, err
+}
+
+// find returns the first of the candidate routes of path whose hosts, path and match
+// conditions hold, with the parameters of its path; conditions are only evaluated with
+// a request.
+func (m *Matcher) find(host, path string, req *http.Request) (*route.Route, Params, error) {
+	for _, i := range m.candidates(path) {
+		r := &m.routes[i]
+		if len(r.hosts) > 0 && !matchHosts(r.hosts, host) {
+			continue
+		}
+		if r.regex != nil && !r.regex.MatchString(path) {
+			continue
+		}
+
+		var params Params
+		if r.pattern != nil {
+			var ok bool
+			if params, ok = r.pattern.match(path); !ok {
+				continue
+			}
+		}
+
+		if !r.route.Match.IsZero() {
+			if req == nil {
+				continue
+			}
+			if ok, err := matchConditions(r.route.Match, req); err != nil {
+				return nil, nil, err
+			} else if !ok {
+				continue
+			}
+		}
+
+		return r.route, params, nil
+	}
+	return nil, nil, ErrRouteNotFound
+}
+
+// candidates returns the indexes of the routes that may match path, the highest priority
+// first, then in the order of the mode: the prefix, exact and param routes along path, and
+// the regex routes.
+func (m *Matcher) candidates(path string) []int {
+	candidates := m.ordered(path)
+	if m.prioritized {
+		slices.SortStableFunc(candidates, func(a, b int) int {
+			return cmp.Compare(m.routes[b].route.Priority, m.routes[a].route.Priority)
+		})
+	}
+	return candidates
+}
+
+// ordered returns the candidates of path in the order of the mode.
+func (m *Matcher) ordered(path string) []int {
+	if m.mode == LongestPrefix {
+		var exacts []int
+		var prefixes [][]int
+		m.tree.Walk(path, func(indexes []int, exact bool) {
+			if exact {
+				exacts = indexes
+			} else {
+				prefixes = append(prefixes, indexes)
+			}
+		})
+
+		candidates := make([]int, 0, len(exacts)+len(m.regexes)+len(prefixes))
+		candidates = append(candidates, exacts...)
+		candidates = append(candidates, m.regexes...)
+		// the tree is walked from the shortest prefix to the longest
+		for i := len(prefixes) - 1; i >= 0; i-- {
+			candidates = append(candidates, prefixes[i]...)
+		}
+		return candidates
+	}
+
+	candidates := slices.Clone(m.regexes)
+	m.tree.Walk(path, func(indexes []int, exact bool) {
+		candidates = append(candidates, indexes...)
+	})
+	slices.Sort(candidates)
+	return candidates
+}
+
+// orDefault falls back to the default backend of host, then to the default backend, when no
+// route matched.
+func (m *Matcher) orDefault(host string, r *route.Route, err error) (*route.Route, error) {
+	if err == nil {
+		return r, nil
+	}
+	if err != ErrRouteNotFound {
+		return nil, err
+	}
+
+	if host != "" {
+		if r, ok := m.hosts[host]; ok {
+			return r, nil
+		}
+		if i := strings.IndexByte(host, '.'); i > 0 {
+			if r, ok := m.hosts["*"+host[i:]]; ok {
+				return r, nil
+			}
+		}
+	}
+
+	if m.fallback != nil {
+		return m.fallback, nil
+	}
+	return nil, ErrRouteNotFound
+}
@@ -0,0 +1,112 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-zoox/core-utils/fmt"
+)
+
+// Params are the values captured by the named parameters of a param route, like id in
+// /users/:id.
+type Params map[string]string
+
+// pattern is the compiled path of a param route: literal segments, named segments
+// (:name) and a trailing catch-all (*name).
+type pattern struct {
+	segments []string
+	// catchAll is the name of the trailing *name segment, if any
+	catchAll string
+	// literal is the path up to the first parameter
+	literal string
+}
+
+// ValidatePattern reports whether path is a valid param route path, like /users/:id/orders/*rest.
+func ValidatePattern(path string) error {
+	_, err := compilePattern(path)
+	return err
+}
+
+func compilePattern(path string) (*pattern, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("param path must start with /: %s", path)
+	}
+
+	p := &pattern{literal: path}
+	names := map[string]bool{}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if !strings.ContainsAny(segment, ":*") {
+			continue
+		}
+
+		name := segment[1:]
+		switch {
+		case segment[0] != ':' && segment[0] != '*':
+			return nil, fmt.Errorf("parameter must be a whole segment: %s", segment)
+		case name == "" || strings.ContainsAny(name, ":*"):
+			return nil, fmt.Errorf("invalid parameter name: %s", segment)
+		case names[name]:
+			return nil, fmt.Errorf("duplicate parameter: %s", name)
+		case segment[0] == '*' && i != len(segments)-1:
+			return nil, fmt.Errorf("catch-all parameter must be the last segment: %s", segment)
+		}
+		names[name] = true
+
+		if p.literal == path {
+			p.literal = strings.Join(segments[:i], "/")
+		}
+		if segment[0] == '*' {
+			p.catchAll = name
+			segments = segments[:i]
+		}
+	}
+	p.segments = segments
+
+	return p, nil
+}
+
+// match returns the parameters of path, reporting false when path does not match. Named
+// parameters match a non-empty segment; the catch-all matches the rest of the path,
+// possibly empty.
+func (p *pattern) match(path string) (Params, bool) {
+	segments := strings.Split(path, "/")
+	if len(segments) < len(p.segments) || (p.catchAll == "" && len(segments) != len(p.segments)) {
+		return nil, false
+	}
+
+	var params Params
+	for i, segment := range p.segments {
+		if segment != "" && segment[0] == ':' {
+			if segments[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = Params{}
+			}
+			params[segment[1:]] = segments[i]
+			continue
+		}
+		if segments[i] != segment {
+			return nil, false
+		}
+	}
+
+	if p.catchAll != "" {
+		if params == nil {
+			params = Params{}
+		}
+		params[p.catchAll] = strings.Join(segments[len(p.segments):], "/")
+	}
+
+	return params, true
+}
+
+// ParamsFromRequest returns the parameters captured by the route the gateway matched for req.
+func ParamsFromRequest(req *http.Request) Params {
+	m, _ := req.Context().Value(routeKey{}).(*matched)
+	if m == nil {
+		return nil
+	}
+	return m.params
+}
@@ -0,0 +1,107 @@
+package router
+
+import "strings"
+
+// radix is a compressed prefix tree of route paths. Every node keeps the routes whose
+// path ends there, so walking a request path collects every prefix and exact route
+// matching it in a single pass.
+type radix struct {
+	root *radixNode
+}
+
+type radixNode struct {
+	prefix   string
+	children []*radixNode
+	// prefixes and exacts are the indexes of the routes whose path ends at this node
+	prefixes []int
+	exacts   []int
+}
+
+func newRadix() *radix {
+	return &radix{root: &radixNode{}}
+}
+
+// Insert adds the route at index with path, matched as a prefix or exactly.
+func (t *radix) Insert(path string, index int, exact bool) {
+	n := t.root
+	for {
+		if path == "" {
+			if exact {
+				n.exacts = append(n.exacts, index)
+			} else {
+				n.prefixes = append(n.prefixes, index)
+			}
+			return
+		}
+
+		child := n.child(path[0])
+		if child == nil {
+			child = &radixNode{prefix: path}
+			n.children = append(n.children, child)
+			n = child
+			path = ""
+			continue
+		}
+
+		common := commonPrefix(child.prefix, path)
+		if common < len(child.prefix) {
+			// split the child at the end of the common prefix
+			split := &radixNode{prefix: child.prefix[:common], children: []*radixNode{child}}
+			n.replace(child, split)
+			child.prefix = child.prefix[common:]
+			child = split
+		}
+
+		n = child
+		path = path[common:]
+	}
+}
+
+// Walk calls fn with the prefix routes of every node on the way to path, from the
+// shortest path to the longest, then with the exact routes of path.
+func (t *radix) Walk(path string, fn func(indexes []int, exact bool)) {
+	n := t.root
+	for {
+		if len(n.prefixes) > 0 {
+			fn(n.prefixes, false)
+		}
+		if path == "" {
+			if len(n.exacts) > 0 {
+				fn(n.exacts, true)
+			}
+			return
+		}
+
+		n = n.child(path[0])
+		if n == nil || !strings.HasPrefix(path, n.prefix) {
+			return
+		}
+		path = path[len(n.prefix):]
+	}
+}
+
+func (n *radixNode) child(b byte) *radixNode {
+	for _, child := range n.children {
+		if child.prefix[0] == b {
+			return child
+		}
+	}
+	return nil
+}
+
+func (n *radixNode) replace(old, child *radixNode) {
+	for i, c := range n.children {
+		if c == old {
+			n.children[i] = child
+			return
+		}
+	}
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
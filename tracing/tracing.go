@@ -0,0 +1,298 @@
+// Package tracing creates the OpenTelemetry spans of the gateway: a server span for each
+// request and a client span for each call to an upstream server.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-zoox/api-gateway/config"
+	"github.com/go-zoox/api-gateway/requestid"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Attributes the gateway records on its spans, besides the OpenTelemetry semantic conventions.
+const (
+	// RouteKey is the name of the route the request matched.
+	RouteKey = attribute.Key("api_gateway.route")
+	// BackendKey is the backend a route split between several backends selected.
+	BackendKey = attribute.Key("api_gateway.backend")
+	// ServerKey is the upstream server (name:port) selected for the request.
+	ServerKey = attribute.Key("api_gateway.upstream.server")
+	// AlgorithmKey is the load balancing algorithm that selected the server.
+	AlgorithmKey = attribute.Key("api_gateway.loadbalancer.algorithm")
+	// CacheStatusKey is the HTTP cache outcome: hit, miss or bypass.
+	CacheStatusKey = attribute.Key("api_gateway.httpcache.status")
+	// RateLimitKey is the rate limit outcome: allowed or denied.
+	RateLimitKey = attribute.Key("api_gateway.ratelimit.result")
+	// AuthKey is the authentication outcome: allowed or denied.
+	AuthKey = attribute.Key("api_gateway.auth.result")
+	// RequestIDKey is the ID of the request, sent by the client or generated by the gateway.
+	RequestIDKey = attribute.Key("api_gateway.request_id")
+)
+
+const instrumentation = "github.com/go-zoox/api-gateway"
+
+// Tracer creates the spans of the gateway and exports them. A disabled Tracer creates
+// spans that are not recorded and leaves the trace headers of requests alone.
+type Tracer struct {
+	provider   *sdktrace.TracerProvider
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+
+	// file of the file exporter, closed on shutdown
+	file io.Closer
+}
+
+// New creates the tracer configured by cfg; version is reported as service.version.
+func New(cfg config.Tracing, version string) (*Tracer, error) {
+	if !cfg.Enable {
+		return &Tracer{
+			tracer:     noop.NewTracerProvider().Tracer(instrumentation),
+			propagator: propagation.NewCompositeTextMapPropagator(),
+		}, nil
+	}
+
+	propagator, err := newPropagator(cfg.Propagators)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tracer{propagator: propagator}
+	exporter, err := t.newExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	t.provider = newProvider(cfg, version, sdktrace.WithBatcher(exporter))
+	t.tracer = t.provider.Tracer(instrumentation)
+	return t, nil
+}
+
+func newProvider(cfg config.Tracing, version string, exporter sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		exporter,
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(cfg.EffectiveServiceName()),
+			semconv.ServiceVersion(version),
+		)),
+		// requests follow the sampling decision of the client that traces them
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.EffectiveSampleRatio()))),
+	)
+}
+
+func (t *Tracer) newExporter(cfg config.Tracing) (sdktrace.SpanExporter, error) {
+	ctx := context.Background()
+
+	switch cfg.EffectiveExporter() {
+	case "otlp-http":
+		opts := []otlptracehttp.Option{}
+		if strings.Contains(cfg.Endpoint, "://") {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case "otlp-grpc":
+		opts := []otlptracegrpc.Option{}
+		if strings.Contains(cfg.Endpoint, "://") {
+			opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(cfg.Headers))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case "file":
+		if cfg.File == "" {
+			return nil, fmt.Errorf("tracing: file exporter requires tracing.file")
+		}
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: failed to open %s: %s", cfg.File, err)
+		}
+		t.file = f
+		// one span per line
+		return stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("tracing: unsupported exporter: %s", cfg.Exporter)
+	}
+}
+
+// newPropagator combines the trace context formats read from requests and sent upstream.
+func newPropagator(names []string) (propagation.TextMapPropagator, error) {
+	if len(names) == 0 {
+		names = []string{"tracecontext", "baggage"}
+	}
+
+	propagators := make([]propagation.TextMapPropagator, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case "b3multi":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		default:
+			return nil, fmt.Errorf("tracing: unsupported propagator: %s", name)
+		}
+	}
+
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
+
+// Enabled reports whether spans are recorded and exported.
+func (t *Tracer) Enabled() bool {
+	return t.provider != nil
+}
+
+// Shutdown exports the spans not sent yet and stops the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.provider == nil {
+		return nil
+	}
+
+	err := t.provider.Shutdown(ctx)
+	if t.file != nil {
+		t.file.Close()
+	}
+	return err
+}
+
+// StartServer starts the server span of req, continuing the trace of the client, and
+// returns req with the span in its context.
+func (t *Tracer) StartServer(req *http.Request) (*http.Request, trace.Span) {
+	ctx := t.propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+	scheme := "http"
+	if req.TLS != nil || req.URL.Scheme == "https" {
+		scheme = "https"
+	}
+
+	ctx, span := t.tracer.Start(ctx, req.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLPath(req.URL.Path),
+			semconv.URLScheme(scheme),
+			semconv.ServerAddress(req.Host),
+			semconv.ClientAddress(clientAddress(req.RemoteAddr)),
+			semconv.UserAgentOriginal(req.UserAgent()),
+			semconv.NetworkProtocolVersion(fmt.Sprintf("%d.%d", req.ProtoMajor, req.ProtoMinor)),
+		),
+	)
+	if id := requestid.FromRequest(req); id != "" {
+		span.SetAttributes(RequestIDKey.String(id))
+	}
+
+	return req.WithContext(ctx), span
+}
+
+// EndServer ends the server span of a request answered with status.
+func EndServer(span trace.Span, status int) {
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	// client errors are not errors of the gateway
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// StartClient starts the client span of a call to server made for req, and writes its
+// trace context into header, which is sent upstream.
+func (t *Tracer) StartClient(req *http.Request, header http.Header, server string) trace.Span {
+	ctx, span := t.tracer.Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.String()),
+			semconv.ServerAddress(req.URL.Hostname()),
+			ServerKey.String(server),
+		),
+	)
+	if port, err := strconv.Atoi(req.URL.Port()); err == nil {
+		span.SetAttributes(semconv.ServerPort(port))
+	}
+	if id := requestid.FromRequest(req); id != "" {
+		span.SetAttributes(RequestIDKey.String(id))
+	}
+
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+	return span
+}
+
+// EndClient ends the client span of an upstream call that returned res or failed with err.
+func EndClient(span trace.Span, res *http.Response, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+	if res.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+	span.End()
+}
+
+// SetRoute records the route a request matched on its server span, which is named after
+// the route path.
+func SetRoute(req *http.Request, name, path string) {
+	span := trace.SpanFromContext(req.Context())
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(RouteKey.String(name))
+	if path != "" {
+		span.SetName(req.Method + " " + path)
+		span.SetAttributes(semconv.HTTPRoute(path))
+	}
+}
+
+// SetAttributes records attributes on the span of req, if it is traced.
+func SetAttributes(req *http.Request, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(req.Context()).SetAttributes(attrs...)
+}
+
+func clientAddress(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-zoox/api-gateway/config"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestTracer returns a tracer that keeps its spans in memory.
+func newTestTracer(t *testing.T, cfg config.Tracing) (*Tracer, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	propagator, err := newPropagator(cfg.Propagators)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := &Tracer{propagator: propagator}
+	tracer.provider = newProvider(cfg, "test", sdktrace.WithSyncer(exporter))
+	tracer.tracer = tracer.provider.Tracer(instrumentation)
+	return tracer, exporter
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(config.Tracing{Enable: true, Exporter: "zipkin"}, "test"); err == nil {
+		t.Error("expected an unsupported exporter to fail")
+	}
+	if _, err := New(config.Tracing{Enable: true, Exporter: "file"}, "test"); err == nil {
+		t.Error("expected the file exporter without a file to fail")
+	}
+	if _, err := New(config.Tracing{Enable: true, Exporter: "stdout", Propagators: []string{"jaeger"}}, "test"); err == nil {
+		t.Error("expected an unsupported propagator to fail")
+	}
+
+	tracer, err := New(config.Tracing{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracer.Enabled() {
+		t.Error("expected tracing to be disabled")
+	}
+}
+
+func TestClientSpan(t *testing.T) {
+	tracer, exporter := newTestTracer(t, config.Tracing{Propagators: []string{"tracecontext", "b3multi"}})
+
+	in := httptest.NewRequest("GET", "http://gateway/api", nil)
+	in, server := tracer.StartServer(in)
+	SetRoute(in, "api", "/api")
+
+	out := in.Clone(in.Context())
+	out.URL.Host = "backend:8080"
+	client := tracer.StartClient(out, out.Header, "backend:8080")
+	EndClient(client, &http.Response{StatusCode: http.StatusBadGateway}, nil)
+	EndServer(server, http.StatusBadGateway)
+
+	traceID := server.SpanContext().TraceID().String()
+	if out.Header.Get("X-B3-TraceId") != traceID {
+		t.Errorf("expected B3 headers, got %v", out.Header)
+	}
+	if out.Header.Get("traceparent") == "" {
+		t.Errorf("expected a traceparent header, got %v", out.Header)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Error("expected the client span to be a child of the server span")
+	}
+	if spans[1].Name != "GET /api" {
+		t.Errorf("expected the server span to be named after the route, got %s", spans[1].Name)
+	}
+	if spans[0].Status.Code.String() != "Error" {
+		t.Errorf("expected a 502 to fail the client span, got %s", spans[0].Status.Code)
+	}
+}
+
+func TestSampleRatio(t *testing.T) {
+	tracer, exporter := newTestTracer(t, config.Tracing{SampleRatio: 0.000001})
+
+	// a trace sampled by the client is recorded whatever the ratio
+	sampled := httptest.NewRequest("GET", "/", nil)
+	sampled.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, span := tracer.StartServer(sampled)
+	EndServer(span, http.StatusOK)
+
+	for i := 0; i < 10; i++ {
+		_, span := tracer.StartServer(httptest.NewRequest("GET", "/", nil))
+		EndServer(span, http.StatusOK)
+	}
+
+	if spans := exporter.GetSpans(); len(spans) != 1 {
+		t.Fatalf("expected only the trace sampled by the client to be recorded, got %d spans", len(spans))
+	}
+}